@@ -0,0 +1,480 @@
+package notesService
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/auth"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"strings"
+	"time"
+)
+
+// defaultTokenTTL - срок действия API-токенов по умолчанию
+const defaultTokenTTL = 30 * 24 * time.Hour
+
+// publicPaths - маршруты, доступные без API-токена
+var publicPaths = map[string]bool{
+	"/register": true,
+	"/login":    true,
+}
+
+// identityContextKey - ключ контекста запроса, под которым middleware authenticate сохраняет пользователя
+type identityContextKey struct{}
+
+// WithUsers задает учетные записи пользователей. По умолчанию они хранятся только в памяти.
+func WithUsers(users *auth.Users) Option {
+	return func(ns *NotesService) {
+		ns.users = users
+	}
+}
+
+// authenticate пропускает к next только запросы с действующим API-токеном в заголовке Authorization: Bearer <token>
+// и сохраняет пользователя в контексте запроса (см. identityFromRequest).
+// Маршруты из publicPaths, чтение заметок по публичным ссылкам (/public/notes/...) и предварительные
+// CORS-запросы (OPTIONS) пропускаются без токена.
+// Без токена или с недействительным токеном клиент получает ответ со статусом 401.
+func (ns *NotesService) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if publicPaths[req.URL.Path] || strings.HasPrefix(req.URL.Path, publicNotesPrefix) || req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if _, ok := identityFromRequest(req); ok {
+			next.ServeHTTP(w, req) // пользователь уже определен по JWT (см. authenticateJWT)
+			return
+		}
+
+		token, ok := bearerToken(req)
+		if !ok {
+			respondUnauthorized(w, `Bearer realm="notes"`, "authentication required: missing bearer token")
+			return
+		}
+		identity, err := ns.users.Authenticate(token)
+		if err != nil {
+			respondUnauthorized(w, `Bearer realm="notes", error="invalid_token"`, err.Error())
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), identityContextKey{}, identity)))
+	})
+}
+
+// identityFromRequest возвращает пользователя, выполняющего запрос. Для публичных маршрутов возвращается false.
+func identityFromRequest(req *http.Request) (auth.Identity, bool) {
+	identity, ok := req.Context().Value(identityContextKey{}).(auth.Identity)
+	return identity, ok
+}
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer <token>
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// respondUnauthorized отвечает клиенту статусом 401 с заголовком WWW-Authenticate
+func respondUnauthorized(w http.ResponseWriter, challenge string, messageString string) {
+	setHttpHeaders(w)
+	w.Header().Set("WWW-Authenticate", challenge)
+
+	wErr := pkg.NewWrappedErrorWithFile("respondUnauthorized()")
+
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	resp.UpdateWithStatus(http.StatusUnauthorized, "ERROR", nil, messageString)
+	wErr.LogMsg(messageString)
+}
+
+// handleRegister обрабатывает запрос на регистрацию пользователя
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"login": "ivanov", "password": "не менее 8 символов"}
+
+Логин - от 3 до 64 латинских букв, цифр, '.', '_' и '-', без учета регистра.
+Пароль хранится в виде хеша PBKDF2-HMAC-SHA256 со случайной солью.
+Пользователь создается без роли администратора: первый администратор задается при запуске
+сервера (флаг -admin или переменная окружения NOTES_ADMIN), остальных назначает он (см. handleSetRole).
+
+Возвращает клиенту ответ со статусом 201 и с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"id": 1, "login": "ivanov", "admin": false}, "error": ""}
+
+В случае ошибки (400, 405, 409 - логин занят, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleRegister(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleRegister()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода и парсинг запроса
+	credentials := &dto.Credentials{}
+	status, err := parseJsonPost(w, req, credentials)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Регистрация
+	identity, err := ns.users.Register(credentials.Login, credentials.Password)
+	switch {
+	case errors.Is(err, auth.ErrInvalidLogin), errors.Is(err, auth.ErrWeakPassword):
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	case errors.Is(err, auth.ErrLoginTaken):
+		resp.UpdateWithStatus(http.StatusConflict, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), credentials.Login))
+		return
+	case err != nil:
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.users.Register(credentials.Login, credentials.Password)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	userJson, err := json.Marshal(userToDto(identity))
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(user)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusCreated, "OK", userJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - register: {id: %d, login: %q}", identity.ID, identity.Login))
+}
+
+// handleLogin обрабатывает запрос на вход пользователя
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"login": "ivanov", "password": "пароль"}
+
+Возвращает клиенту ответ со статусом 200 и новым API-токеном, который нужно передавать
+во всех остальных запросах в заголовке Authorization: Bearer <token>:
+  {"result": "OK", "data": {"token": "nt_...", "expires_at": "2024-01-01T12:00:00Z"}, "error": ""}
+
+В случае ошибки (400, 401 - неверный логин или пароль, 405, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleLogin(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleLogin()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода и парсинг запроса
+	credentials := &dto.Credentials{}
+	status, err := parseJsonPost(w, req, credentials)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Проверка пароля и выдача токена
+	token, expiresAt, err := ns.users.Login(credentials.Login, credentials.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		resp.UpdateWithStatus(http.StatusUnauthorized, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), credentials.Login))
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.users.Login(credentials.Login, credentials.Password)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	tokenJson, err := json.Marshal(dto.Token{Token: token, ExpiresAt: expiresAt.UTC()})
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(token)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", tokenJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - login: {login: %q}", strings.ToLower(credentials.Login)))
+}
+
+// handleLogout обрабатывает запрос на отзыв API-токенов
+/*
+Запрос должен быть с методом POST, с заголовком Authorization: Bearer <token>
+и с содержимым в формате JSON следующего вида (тело может быть пустым):
+  {"all": true}
+
+Отзывает токен, с которым выполнен запрос, а при "all": true - все токены пользователя.
+JWT отозвать нельзя: он действует до истечения срока (exp), поэтому запрос с JWT без "all": true отклоняется (400).
+
+Возвращает клиенту ответ со статусом 200 и количеством отозванных токенов:
+  {"result": "OK", "data": {"revoked": 1}, "error": ""}
+
+В случае ошибки (400, 401, 405, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleLogout(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleLogout()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода и парсинг запроса
+	logoutRequest := &dto.LogoutRequest{}
+	status, err := parseJsonPost(w, req, logoutRequest)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	identity, _ := identityFromRequest(req)
+	token, _ := bearerToken(req)
+	if !logoutRequest.All && auth.LooksLikeJWT(token) {
+		messageString := "jwt cannot be revoked: it is valid until it expires"
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Отзыв токенов
+	revoked := 1
+	if logoutRequest.All {
+		revoked, err = ns.users.RevokeAll(identity.ID)
+	} else {
+		err = ns.users.Revoke(token)
+	}
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.users.Revoke(token)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	revokedJson, err := json.Marshal(map[string]int{"revoked": revoked})
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(revokedMap)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", revokedJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - logout: {login: %q, revoked: %d}", identity.Login, revoked))
+}
+
+// handleMe обрабатывает запрос на получение данных текущего пользователя
+/*
+Запрос должен быть с методом GET и с заголовком Authorization: Bearer <token>. Тело запроса игнорируется.
+
+Возвращает клиенту ответ со статусом 200 и с содержимым в формате JSON следующего вида
+(поле "admin" есть только у администраторов, "groups" - только у пользователей, состоящих в группах):
+  {"result": "OK", "data": {"id": 1, "login": "ivanov", "admin": true, "groups": ["editors"]}, "error": ""}
+
+В случае ошибки (401, 405, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleMe(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleMe()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodGet)
+		resp.UpdateWithStatus(http.StatusMethodNotAllowed, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Формирование содержимого для ответа
+	identity, _ := identityFromRequest(req)
+	userJson, err := json.Marshal(userToDto(identity))
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(user)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", userJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - me: {id: %d, login: %q}", identity.ID, identity.Login))
+}
+
+// handleSetRole обрабатывает запрос на назначение или снятие роли администратора
+/*
+Доступен только администраторам (см. requireAdmin). Запрос должен быть с методом POST
+и с содержимым в формате JSON следующего вида:
+  {"login": "petrov", "admin": true}
+
+Администратор видит и изменяет заметки всех пользователей. Снять роль с самого себя нельзя,
+чтобы не остаться без администраторов.
+
+Возвращает клиенту ответ со статусом 200 и данными пользователя:
+  {"result": "OK", "data": {"id": 2, "login": "petrov", "admin": true}, "error": ""}
+
+В случае ошибки (400, 403, 404 - пользователь не найден, 405, 409 - снятие роли с себя, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleSetRole(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleSetRole()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода и парсинг запроса
+	roleRequest := &dto.RoleRequest{}
+	status, err := parseJsonPost(w, req, roleRequest)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	if roleRequest.Login == "" {
+		messageString := "required data is missing: login"
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	identity, _ := identityFromRequest(req)
+	if strings.EqualFold(roleRequest.Login, identity.Login) && !roleRequest.Admin {
+		messageString := "cannot remove administrator role from yourself"
+		resp.UpdateWithStatus(http.StatusConflict, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Изменение роли
+	user, err := ns.users.SetAdmin(roleRequest.Login, roleRequest.Admin)
+	if errors.Is(err, auth.ErrUnknownUser) {
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), roleRequest.Login))
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.users.SetAdmin(roleRequest.Login, roleRequest.Admin)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	userJson, err := json.Marshal(userToDto(user))
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(user)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", userJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - set role: {login: %q, admin: %t, by: %q}", user.Login, user.Admin, identity.Login))
+}
+
+// handleSetGroups обрабатывает запрос на назначение групп пользователя
+/*
+Доступен только администраторам (см. requireAdmin). Запрос должен быть с методом POST
+и с содержимым в формате JSON следующего вида:
+  {"login": "petrov", "groups": ["editors", "qa"]}
+
+Группы заменяют прежние группы пользователя; пустой список исключает его из всех групп.
+Названия групп подчиняются тем же правилам, что и логины. Владельцы заметок выдают группам права
+доступа к заметкам (см. handleGrantShare), и их получают все пользователи группы.
+
+Возвращает клиенту ответ со статусом 200 и данными пользователя:
+  {"result": "OK", "data": {"id": 2, "login": "petrov", "groups": ["editors", "qa"]}, "error": ""}
+
+В случае ошибки (400, 403, 404 - пользователь не найден, 405, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleSetGroups(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleSetGroups()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода и парсинг запроса
+	groupsRequest := &dto.GroupsRequest{}
+	status, err := parseJsonPost(w, req, groupsRequest)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	if groupsRequest.Login == "" {
+		messageString := "required data is missing: login"
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Изменение групп
+	user, err := ns.users.SetGroups(groupsRequest.Login, groupsRequest.Groups)
+	switch {
+	case errors.Is(err, auth.ErrInvalidGroup):
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), groupsRequest.Groups))
+		return
+	case errors.Is(err, auth.ErrUnknownUser):
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), groupsRequest.Login))
+		return
+	case err != nil:
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.users.SetGroups(groupsRequest.Login, groupsRequest.Groups)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	userJson, err := json.Marshal(userToDto(user))
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(user)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", userJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - set groups: {login: %q, groups: %q}", user.Login, user.Groups))
+}
+
+// userToDto возвращает dto.User для пользователя identity
+func userToDto(identity auth.Identity) dto.User {
+	return dto.User{ID: identity.ID, Login: identity.Login, Admin: identity.Admin, Groups: identity.Groups}
+}
+
+// parseJsonPost проверяет, что запрос выполнен методом POST, и разбирает его JSON-содержимое в value.
+// Пустое тело оставляет value без изменений. В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту.
+func parseJsonPost(w http.ResponseWriter, req *http.Request, value any) (int, error) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return http.StatusMethodNotAllowed, fmt.Errorf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+	}
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("cannot read request bytes: %s", err)
+	}
+	if len(requestBytes) == 0 {
+		return http.StatusOK, nil
+	}
+	err = json.Unmarshal(requestBytes, value)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("cannot unmarshal request json: %s", err.Error())
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,148 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"notesServer/gates/search"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+	"notesServer/pkg/analysis"
+	"sort"
+	"strconv"
+)
+
+// maxAuthorDistance - максимальное допустимое количество опечаток в слове запроса ?distance=
+const maxAuthorDistance = 3
+
+// handleSearchAuthors обрабатывает запрос на нечеткий поиск авторов заметок по имени и фамилии
+/*
+Запрос должен быть с методом GET: GET /search-authors?q=Ivnov&limit=20&distance=2. Тело запроса игнорируется.
+
+Каждое слово запроса должно совпасть с именем или фамилией автора с точностью до опечаток
+(расстояние Левенштейна). Регистр не учитывается, кириллица транслитерируется латиницей,
+поэтому "Ivanov" находит "Иванов". Параметр distance задает допустимое количество опечаток в слове
+(от 0 до 3); по умолчанию оно зависит от длины слова: 0 для слов до 2 букв, 1 до 5, 2 до 10, иначе 3.
+Учитываются только заметки, доступные пользователю, выполняющему запрос (см. accessTo).
+
+Возвращает клиенту ответ со статусом 200 и авторами по возрастанию количества опечаток:
+  {"result": "OK", "data": [
+  {"name": "Иван", "last_name": "Иванов", "distance": 1, "similarity": 0.56, "note_ids": [1, 4]}
+  ], "error": "", "total": 1}
+
+В случае ошибки (400, 405, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleSearchAuthors(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleSearchAuthors()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodGet)
+		resp.UpdateWithStatus(http.StatusMethodNotAllowed, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Парсинг параметров запроса
+	queryText := req.URL.Query().Get("q")
+	limit := defaultSearchLimit
+	var err error
+	if req.URL.Query().Has("limit") {
+		limit, err = strconv.Atoi(req.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			messageString := fmt.Sprintf("invalid 'limit': need an integer from 1 to %d", maxSearchLimit)
+			resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+			wErr.LogMsg(messageString)
+			return
+		}
+	}
+	maxDistance := -1 // по длине слова
+	if req.URL.Query().Has("distance") {
+		maxDistance, err = strconv.Atoi(req.URL.Query().Get("distance"))
+		if err != nil || maxDistance < 0 || maxDistance > maxAuthorDistance {
+			messageString := fmt.Sprintf("invalid 'distance': need an integer from 0 to %d", maxAuthorDistance)
+			resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+			wErr.LogMsg(messageString)
+			return
+		}
+	}
+
+	// Поиск
+	hits, err := ns.authors.Search(queryText, maxDistance)
+	if errors.Is(err, search.ErrInvalidQuery) {
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), queryText))
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authors.Search(queryText, maxDistance)").LogError()
+		return
+	}
+
+	// Объединение найденных заметок по авторам. Авторы упорядочены по лучшей из своих заметок,
+	// имя автора берется из нее же.
+	var authors []*dto.AuthorMatch
+	authorsByKey := make(map[string]*dto.AuthorMatch)
+	for _, hit := range hits {
+		pureNoteAny, ok := ns.getStorage().GetByID(hit.ID)
+		if !ok {
+			continue // запись удалена после поиска
+		}
+		pureNote, ok := pureNoteAny.(entity.PureNote)
+		if !ok {
+			err = errors.New("cannot convert interface{} to PureNote")
+			resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+			wErr.Specify(err, "pureNote, ok := pureNoteAny.(PureNote)").LogError()
+			return
+		}
+		if !ns.canAccessNote(req, hit.ID, pureNote, accessRead) {
+			continue
+		}
+		key := analysis.Normalize(pureNote.Name()) + "\x00" + analysis.Normalize(pureNote.LastName())
+		author, ok := authorsByKey[key]
+		if !ok {
+			author = &dto.AuthorMatch{
+				Name:       pureNote.Name(),
+				LastName:   pureNote.LastName(),
+				Distance:   hit.Distance,
+				Similarity: hit.Similarity,
+			}
+			authorsByKey[key] = author
+			authors = append(authors, author)
+		}
+		author.NoteIDs = append(author.NoteIDs, hit.ID)
+	}
+	total := len(authors)
+	if len(authors) > limit {
+		authors = authors[:limit]
+	}
+	for _, author := range authors {
+		sort.Slice(author.NoteIDs, func(i, j int) bool { return author.NoteIDs[i] < author.NoteIDs[j] })
+	}
+
+	// Формирование содержимого ответа в формате JSON
+	if authors == nil {
+		authors = []*dto.AuthorMatch{}
+	}
+	authorsJson, err := json.Marshal(authors)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(authors)").LogError()
+		return
+	}
+
+	resp.UpdateWithStatus(http.StatusOK, "OK", authorsJson, "")
+	resp.SetPage(int64(total), "")
+	wErr.LogMsg(fmt.Sprintf("OK - search authors: {q: %q, count: %d, total: %d}", queryText, len(authors), total))
+}
@@ -0,0 +1,313 @@
+package notesService
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+)
+
+// handleCreateNotes обрабатывает запрос на создание нескольких записей
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  [{"name": "Имя", "last_name": "Фамилия", "note": "Первая заметка"},
+   {"name": "Имя", "last_name": "Фамилия", "note": "Вторая заметка"}]
+
+Владельцем записей становится пользователь, выполняющий запрос.
+Повтор запроса с тем же заголовком Idempotency-Key не создает новых записей, а возвращает исходный ответ (см. idempotent).
+
+Записи добавляются в хранилище за одну операцию. Ошибка в одной записи не отменяет добавление остальных.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида (в порядке записей в запросе):
+  {"result": "OK", "data": [{"id": 1}, {"id": 0, "error": "required data is missing"}], "error": ""}
+
+В случае ошибки всего запроса:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleCreateNotes(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleCreateNotes()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	var creatableNotes []*dto.Note
+	err = json.Unmarshal(requestBytes, &creatableNotes)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка наличия необходимых данных в каждой записи. Владелец записей - пользователь, выполняющий запрос.
+	identity, _ := identityFromRequest(req)
+	results := make([]*dto.BatchResult, len(creatableNotes))
+	values := make([]any, 0, len(creatableNotes))
+	positions := make([]int, 0, len(creatableNotes)) // позиции в запросе записей, переданных в хранилище
+	for i, creatableNote := range creatableNotes {
+		results[i] = &dto.BatchResult{}
+		if creatableNote == nil || creatableNote.Name == "" || creatableNote.LastName == "" || creatableNote.Content == "" {
+			results[i].Error = "required data is missing"
+			continue
+		}
+		creatableNote.OwnerID = identity.ID
+		values = append(values, entity.GetPureNote(creatableNote))
+		positions = append(positions, i)
+	}
+
+	// Вставка записей в хранилище
+	added := 0
+	for j, result := range ns.getStorage().AddMany(values) {
+		i := positions[j]
+		if result.Err != nil {
+			results[i].Error = "cannot add note: " + result.Err.Error()
+			wErr.Specify(result.Err, "ns.storage.AddMany(values)").LogError()
+			continue
+		}
+		results[i].ID = result.ID
+		ns.noteSaved(result.ID, values[j].(entity.PureNote), requestAuthor(req, creatableNotes[i]))
+		added++
+	}
+
+	// Формирование содержимого для ответа
+	resultsJson, err := json.Marshal(results)
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(results)").LogError()
+		return
+	}
+
+	resp.Update("OK", resultsJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - create-many: {added: %d, total: %d}", added, len(creatableNotes)))
+}
+
+// handleUpdateNotes обрабатывает запрос на обновление нескольких записей
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  [{"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Первая заметка"},
+   {"id": 2, "name": "Имя", "last_name": "Фамилия", "note": "Вторая заметка"}]
+
+Записи обновляются за одну операцию. Ошибка в одной записи не отменяет обновление остальных.
+Записи других пользователей может обновить администратор или пользователь с правом "edit" (см. handleGrantShare).
+Владелец записей при обновлении не меняется.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида (в порядке записей в запросе):
+  {"result": "OK", "data": [{"id": 1}, {"id": 2, "error": "cannot update non-existing note"},
+   {"id": 3, "error": "access denied: note belongs to another user"}], "error": ""}
+
+В случае ошибки всего запроса:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleUpdateNotes(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleUpdateNotes()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	var updatableNotes []*dto.Note
+	err = json.Unmarshal(requestBytes, &updatableNotes)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка наличия необходимых данных в каждой записи и доступа к ней.
+	// Несуществующие записи передаются в хранилище, которое сообщит об их отсутствии.
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	results := make([]*dto.BatchResult, len(updatableNotes))
+	items := make([]storage.Item, 0, len(updatableNotes))
+	positions := make([]int, 0, len(updatableNotes)) // позиции в запросе записей, переданных в хранилище
+	for i, updatableNote := range updatableNotes {
+		results[i] = &dto.BatchResult{}
+		if updatableNote == nil || updatableNote.Name == "" || updatableNote.LastName == "" || updatableNote.Content == "" || updatableNote.ID < 1 {
+			results[i].Error = "required data is missing"
+			continue
+		}
+		results[i].ID = updatableNote.ID
+		foundPureNote, status, err := ns.authorizeNote(req, updatableNote.ID, accessEdit)
+		switch status {
+		case http.StatusForbidden:
+			results[i].Error = err.Error()
+			continue
+		case http.StatusInternalServerError:
+			results[i].Error = "internal server error"
+			wErr.Specify(err, "ns.authorizeNote(req, updatableNote.ID, accessEdit)").LogError()
+			continue
+		}
+		updatableNote.OwnerID = foundPureNote.Owner()
+		items = append(items, storage.Item{ID: updatableNote.ID, Value: entity.GetPureNote(updatableNote)})
+		positions = append(positions, i)
+	}
+
+	// Обновление записей
+	updated := 0
+	for j, result := range ns.getStorage().UpdateMany(items) {
+		i := positions[j]
+		if result.Err != nil {
+			results[i].Error = "internal server error"
+			wErr.Specify(result.Err, "ns.storage.UpdateMany(items)").LogError()
+			continue
+		}
+		if !result.OK {
+			results[i].Error = "cannot update non-existing note"
+			continue
+		}
+		ns.noteSaved(result.ID, items[j].Value.(entity.PureNote), requestAuthor(req, updatableNotes[i]))
+		updated++
+	}
+
+	// Формирование содержимого для ответа
+	resultsJson, err := json.Marshal(results)
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(results)").LogError()
+		return
+	}
+
+	resp.Update("OK", resultsJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - update-many: {updated: %d, total: %d}", updated, len(updatableNotes)))
+}
+
+// handleDeleteNotes обрабатывает запрос на удаление нескольких записей
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  [{"id": 1}, {"id": 2}]
+
+Записи удаляются за одну операцию. Ошибка в одной записи не отменяет удаление остальных.
+Записи других пользователей может удалить только администратор.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида (в порядке записей в запросе):
+  {"result": "OK", "data": [{"id": 1}, {"id": 2, "error": "note with this ID doesn't exist"}], "error": ""}
+
+В случае ошибки всего запроса:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleDeleteNotes(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleDeleteNotes()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	var deletableNotes []*dto.Note
+	err = json.Unmarshal(requestBytes, &deletableNotes)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка наличия необходимых данных в каждой записи и доступа к ней
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	results := make([]*dto.BatchResult, len(deletableNotes))
+	ids := make([]int64, 0, len(deletableNotes))
+	positions := make([]int, 0, len(deletableNotes)) // позиции в запросе записей, переданных в хранилище
+	for i, deletableNote := range deletableNotes {
+		results[i] = &dto.BatchResult{}
+		if deletableNote == nil || deletableNote.ID < 1 {
+			results[i].Error = "invalid note id"
+			continue
+		}
+		results[i].ID = deletableNote.ID
+		_, status, err := ns.authorizeNote(req, deletableNote.ID, accessOwner)
+		switch status {
+		case http.StatusForbidden:
+			results[i].Error = err.Error()
+			continue
+		case http.StatusInternalServerError:
+			results[i].Error = "internal server error"
+			wErr.Specify(err, "ns.authorizeNote(req, deletableNote.ID, accessOwner)").LogError()
+			continue
+		}
+		ids = append(ids, deletableNote.ID)
+		positions = append(positions, i)
+	}
+
+	// Удаление записей
+	deleted := 0
+	for j, result := range ns.getStorage().RemoveMany(ids) {
+		i := positions[j]
+		if !result.OK {
+			results[i].Error = "note with this ID doesn't exist"
+			continue
+		}
+		ns.noteDeleted(result.ID)
+		deleted++
+	}
+
+	// Формирование содержимого для ответа
+	resultsJson, err := json.Marshal(results)
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(results)").LogError()
+		return
+	}
+
+	resp.Update("OK", resultsJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - delete-many: {deleted: %d, total: %d}", deleted, len(deletableNotes)))
+}
@@ -0,0 +1,120 @@
+package notesService
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+	"strings"
+)
+
+// Условные запросы:
+//
+//	GET /notes/{id}, GET /notes, GET /get-all - ответ содержит заголовок ETag; если тег совпадает
+//	                                            с одним из тегов If-None-Match, возвращается 304 без тела
+//	PUT, PATCH, DELETE /notes/{id}, /update, /delete - если заголовок If-Match не содержит текущий тег
+//	                                            заметки (или "*"), изменение не выполняется и возвращается 412
+//
+// ETag заметки вычисляется по ее JSON-представлению (полю data ответа), ETag списка - по всему ответу,
+// включая total и next_cursor. Теги сильные: они меняются при любом изменении содержимого.
+
+// errPreconditionFailed ошибка, возвращаемая, если заметка изменилась после получения клиентом ее ETag
+var errPreconditionFailed = errors.New("precondition failed: note has been modified")
+
+// etagOf возвращает сильный ETag содержимого: первые 16 байт SHA-256 в шестнадцатеричном виде в кавычках
+func etagOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// responseETag возвращает ETag всего содержимого ответа
+func responseETag(resp *dto.Response) (string, error) {
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	return etagOf(respJson), nil
+}
+
+// notModified устанавливает заголовок ETag ответа и проверяет условие If-None-Match запроса.
+// Если у клиента уже есть эта версия содержимого, ответ заменяется на 304 без тела и возвращается true.
+func notModified(w http.ResponseWriter, req *http.Request, resp *dto.Response, etag string) bool {
+	w.Header().Set("ETag", etag)
+	ifNoneMatch := req.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !etagListContains(ifNoneMatch, etag, false) {
+		return false
+	}
+	resp.UpdateWithStatus(http.StatusNotModified, "OK", nil, "")
+	return true
+}
+
+// checkIfMatch проверяет условие If-Match запроса для текущей версии заметки id.
+// Вызывается под ns.writeMu, чтобы заметка не изменилась между проверкой и записью.
+// В случае ошибки возвращается HTTP-статус: 404 - заметки нет, 412 - заметка изменилась.
+func (ns *NotesService) checkIfMatch(req *http.Request, id int64) (int, error) {
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" {
+		return http.StatusOK, nil
+	}
+
+	pureNoteAny, ok := ns.getStorage().GetByID(id)
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("cannot find note with id %d", id)
+	}
+	pureNote, ok := pureNoteAny.(entity.PureNote)
+	if !ok {
+		return http.StatusInternalServerError, errors.New("cannot convert interface{} to PureNote")
+	}
+	noteJson, err := json.Marshal(pureNote.ToNoteWithID(id))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !etagListContains(ifMatch, etagOf(noteJson), true) {
+		return http.StatusPreconditionFailed, errPreconditionFailed
+	}
+	return http.StatusOK, nil
+}
+
+// etagListContains проверяет, содержит ли значение заголовка If-Match или If-None-Match тег etag.
+// "*" соответствует любому тегу. При сильном сравнении (strong) слабые теги W/"..." не совпадают ни с чем,
+// при слабом префикс W/ не учитывается.
+func etagListContains(header string, etag string, strong bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if strong {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondCheckError формирует ответ маршрутов /update, /delete и /revert на ошибку authorizeNote или checkIfMatch:
+// отсутствие доступа к заметке возвращается со статусом 403, несовпадение версии - со статусом 412,
+// остальные ошибки - как прочие ошибки этих маршрутов
+func respondCheckError(resp *dto.Response, wErr *pkg.WrappedError, status int, err error) {
+	switch status {
+	case http.StatusForbidden, http.StatusPreconditionFailed:
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+	case http.StatusInternalServerError:
+		resp.Update("ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authorizeNote(req, id), ns.checkIfMatch(req, id)").LogError()
+	default:
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+	}
+}
@@ -0,0 +1,145 @@
+package notesService
+
+import (
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/faulty"
+	"strings"
+	"testing"
+)
+
+// Поведение обработчиков при отказах хранилища: сбои внедряются декоратором faulty.Faulty
+
+// newFaultyService возвращает сервис над хранилищем dsn, обернутым в faulty.Faulty, и токен его пользователя
+func newFaultyService(t *testing.T, dsn string) (*testService, *faulty.Faulty, string) {
+	t.Helper()
+	f := faulty.NewFaulty(openStorage(t, dsn), 1)
+	ts := newTestService(t, f.Storage())
+	return ts, f, ts.register("alice")
+}
+
+// setFault задает правило сбоя метода method
+func setFault(t *testing.T, f *faulty.Faulty, method string, fault faulty.Fault) {
+	t.Helper()
+	if err := f.SetFault(method, fault); err != nil {
+		t.Fatalf("SetFault(%s): %v", method, err)
+	}
+}
+
+func TestFaultyStorageForwardsOptionalInterfaces(t *testing.T) {
+	tests := []struct {
+		dsn       string
+		ordered   bool
+		versioned bool
+	}{
+		{"map://?init_id=1", false, false},
+		{"list://?init_id=1", true, false},
+		{"mvcc://?init_id=1", false, true},
+	}
+	for _, tt := range tests {
+		st := faulty.NewFaulty(openStorage(t, tt.dsn), 1).Storage()
+		if _, ok := st.(storage.Ordered); ok != tt.ordered {
+			t.Errorf("%s: storage.Ordered = %t, want %t", tt.dsn, ok, tt.ordered)
+		}
+		if _, ok := st.(storage.Versioned); ok != tt.versioned {
+			t.Errorf("%s: storage.Versioned = %t, want %t", tt.dsn, ok, tt.versioned)
+		}
+		if _, ok := st.(storage.Migratable); !ok {
+			t.Errorf("%s: storage.Migratable is not forwarded", tt.dsn)
+		}
+	}
+}
+
+func TestCreateNoteWhenAddFails(t *testing.T) {
+	ts, f, token := newFaultyService(t, "list://?init_id=1")
+	setFault(t, f, faulty.MethodAdd, faulty.Fault{Schedule: []int{1}, Fail: true})
+
+	_, resp := ts.do(token, http.MethodPost, "/create", `{"name": "Иван", "last_name": "Иванов", "note": "первая"}`)
+	if resp.Result != "ERROR" || !strings.Contains(resp.Error, faulty.ErrInjected.Error()) {
+		t.Fatalf("/create with failing Add: %+v", resp)
+	}
+	if n := f.Len(); n != 0 {
+		t.Fatalf("storage has %d notes after failed Add", n)
+	}
+
+	// Следующий вызов Add проходит: сбой был разовым
+	id := ts.createNote(token, "вторая")
+	if note, status := ts.getNote(token, id); status != http.StatusOK || note.Content != "вторая" {
+		t.Fatalf("GET /notes/%d = %d %+v", id, status, note)
+	}
+}
+
+func TestPutNoteWhenUpdateFails(t *testing.T) {
+	ts, f, token := newFaultyService(t, "map://?init_id=1")
+	id := ts.createNote(token, "исходная")
+	setFault(t, f, faulty.MethodUpdateByID, faulty.Fault{EveryN: 1, Fail: true})
+
+	recorder, resp := ts.do(token, http.MethodPut, "/notes/"+itoa(id), `{"name": "Иван", "last_name": "Иванов", "note": "новая"}`)
+	if recorder.Code != http.StatusInternalServerError || resp.Result != "ERROR" {
+		t.Fatalf("PUT with failing UpdateByID = %d %+v", recorder.Code, resp)
+	}
+	if note, _ := ts.getNote(token, id); note.Content != "исходная" {
+		t.Fatalf("note changed after failed update: %+v", note)
+	}
+
+	// Ревизия не добавляется, если запись не удалась
+	_, resp = ts.do(token, http.MethodPost, "/revisions", `{"id": `+itoa(id)+`}`)
+	if resp.Result != "OK" || strings.Contains(string(resp.Data), "новая") {
+		t.Fatalf("/revisions after failed update: %+v", resp)
+	}
+}
+
+func TestStoragePanicAnswersInternalError(t *testing.T) {
+	ts, f, token := newFaultyService(t, "map://?init_id=1")
+	id := ts.createNote(token, "заметка")
+	setFault(t, f, faulty.MethodGetByID, faulty.Fault{Schedule: []int{f.Calls(faulty.MethodGetByID) + 1}, Panic: true})
+
+	recorder, resp := ts.do(token, http.MethodGet, "/notes/"+itoa(id), "", "X-Request-ID: panic-1")
+	if recorder.Code != http.StatusInternalServerError || resp.RequestID != "panic-1" {
+		t.Fatalf("GET with panicking GetByID = %d %+v", recorder.Code, resp)
+	}
+	if note, status := ts.getNote(token, id); status != http.StatusOK || note.Content != "заметка" {
+		t.Fatalf("GET after panic = %d %+v", status, note)
+	}
+}
+
+func TestReorderThroughFaultyStorage(t *testing.T) {
+	ts, f, token := newFaultyService(t, "list://?init_id=1")
+	first := ts.createNote(token, "первая")
+	second := ts.createNote(token, "вторая")
+
+	_, resp := ts.do(token, http.MethodPost, "/reorder", `{"id": `+itoa(second)+`, "before": `+itoa(first)+`}`)
+	if resp.Result != "OK" {
+		t.Fatalf("/reorder over faulty list: %+v", resp)
+	}
+
+	setFault(t, f, faulty.MethodMoveToBack, faulty.Fault{EveryN: 1, Fail: true})
+	_, resp = ts.do(token, http.MethodPost, "/reorder", `{"id": `+itoa(second)+`, "position": "back"}`)
+	if resp.Result != "ERROR" {
+		t.Fatalf("/reorder with failing MoveToBack: %+v", resp)
+	}
+	_, resp = ts.do(token, http.MethodGet, "/get-all?sort=manual", "")
+	if resp.Result != "OK" || strings.Index(string(resp.Data), "вторая") > strings.Index(string(resp.Data), "первая") {
+		t.Fatalf("manual order changed after failed move: %s", resp.Data)
+	}
+}
+
+// vanishingStorage - хранилище, из которого заметка исчезает между проверкой доступа и обновлением
+type vanishingStorage struct {
+	storage.Storage
+}
+
+func (v vanishingStorage) UpdateByID(int64, any) (bool, error) {
+	return false, nil
+}
+
+func TestUpdateVanishedNote(t *testing.T) {
+	ts := newTestService(t, vanishingStorage{openStorage(t, "map://?init_id=1")})
+	token := ts.register("alice")
+	id := ts.createNote(token, "заметка")
+
+	_, resp := ts.do(token, http.MethodPost, "/update", `{"id": `+itoa(id)+`, "name": "Иван", "last_name": "Иванов", "note": "новая"}`)
+	if resp.Result != "ERROR" || resp.Error != "cannot update non-existing note: "+itoa(id) {
+		t.Fatalf("/update of vanished note: %+v", resp)
+	}
+}
@@ -0,0 +1,132 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+	"notesServer/pkg/analysis"
+	"sort"
+	"strings"
+)
+
+// noteFilterPredicate возвращает предикат для storage.Storage.Find, отбирающий заметки по условиям filter.
+// Строки сравниваются без учета регистра и различия ё/е.
+func noteFilterPredicate(filter *dto.NoteFilter) func(id int64, v any) bool {
+	name := analysis.Normalize(filter.Name)
+	lastName := analysis.Normalize(filter.LastName)
+	content := analysis.Normalize(filter.Content)
+	return func(id int64, v any) bool {
+		pureNote, ok := v.(entity.PureNote)
+		if !ok {
+			return false
+		}
+		if name != "" && analysis.Normalize(pureNote.Name()) != name {
+			return false
+		}
+		if lastName != "" && analysis.Normalize(pureNote.LastName()) != lastName {
+			return false
+		}
+		if content != "" && !strings.Contains(analysis.Normalize(pureNote.Content()), content) {
+			return false
+		}
+		return true
+	}
+}
+
+// handleFindNotes обрабатывает запрос на поиск записей по автору и содержимому
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"name": "Имя", "last_name": "Фамилия", "note": "подстрока", "limit": 10}
+Все поля необязательны: строки сравниваются без учета регистра и различия ё/е, "note" ищется как подстрока.
+Ищутся только записи, доступные пользователю, выполняющему запрос (см. accessTo).
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": [
+  {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержит подстроку"}
+  ], "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleFindNotes(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleFindNotes()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	filter := dto.NewNoteFilter()
+	err = json.Unmarshal(requestBytes, &filter)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка валидности ограничения
+	if filter.Limit < 0 {
+		err = errors.New("invalid limit")
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s %d", err.Error(), filter.Limit))
+		return
+	}
+
+	// Поиск записей
+	matches, accessible := noteFilterPredicate(filter), ns.accessibleNotes(req)
+	foundNotesMap, status := ns.getStorage().Find(func(id int64, v any) bool {
+		return accessible(id, v) && matches(id, v)
+	}, filter.Limit)
+	if !status {
+		messageString := "no records found"
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Преобразование из map[int64]interface{} в []*dto.Note
+	foundNotes := make([]*dto.Note, 0, len(foundNotesMap))
+	for id, pureNoteAny := range foundNotesMap {
+		foundNotes = append(foundNotes, pureNoteAny.(entity.PureNote).ToNoteWithID(id))
+	}
+
+	// Сортировка []*dto.Note по возрастанию ID
+	sort.Slice(foundNotes, func(i, j int) bool {
+		return foundNotes[i].ID < foundNotes[j].ID
+	})
+
+	// Формирование содержимого ответа в формате JSON
+	foundNotesJson, err := json.Marshal(foundNotes)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot marshal found notes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "json.Marshal(foundNotes)").LogError()
+		return
+	}
+
+	resp.Update("OK", foundNotesJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - find: {count: %d}", len(foundNotes)))
+}
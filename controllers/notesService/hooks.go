@@ -0,0 +1,37 @@
+package notesService
+
+import (
+	"notesServer/models/entity"
+)
+
+// noteSaved обновляет данные, производные от заметок, после создания или изменения заметки:
+// историю ревизий и поисковые индексы. Возвращает номер новой ревизии.
+func (ns *NotesService) noteSaved(id int64, note entity.PureNote, author string) int64 {
+	ns.index.Put(id, noteSearchFields(note))
+	ns.authors.Put(id, note.Name()+" "+note.LastName())
+	return ns.history.Add(id, note, author)
+}
+
+// noteDeleted обновляет данные, производные от заметок, после удаления заметки, и отзывает права доступа к ней
+func (ns *NotesService) noteDeleted(id int64) {
+	ns.index.Remove(id)
+	ns.authors.Remove(id)
+	ns.history.Remove(id)
+	ns.shares.RemoveNote(id)
+	ns.links.RemoveNote(id)
+}
+
+// seedNoteState заполняет историю ревизий и поисковые индексы заметками, уже находящимися в хранилище
+func (ns *NotesService) seedNoteState() {
+	allNotesMap, status := ns.getStorage().GetAll()
+	if !status {
+		return
+	}
+	for id, pureNoteAny := range allNotesMap {
+		pureNote, ok := pureNoteAny.(entity.PureNote)
+		if !ok {
+			continue
+		}
+		ns.noteSaved(id, pureNote, noteAuthor(pureNote.ToNoteWithID(id)))
+	}
+}
@@ -0,0 +1,168 @@
+package notesService
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"notesServer/gates/idempotency"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"time"
+)
+
+// Заголовки и параметры ключей идемпотентности
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyKeysTTL = 24 * time.Hour
+)
+
+// WithIdempotencyTTL задает время, в течение которого хранятся ответы на запросы создания заметок
+// с заголовком Idempotency-Key. Если ttl <= 0, заголовок игнорируется.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(ns *NotesService) {
+		ns.idempotencyKeys = nil
+		if ttl > 0 {
+			ns.idempotencyKeys = idempotency.NewStore(ttl)
+		}
+	}
+}
+
+// idempotent возвращает обработчик запросов создания заметок, поддерживающий заголовок Idempotency-Key
+/*
+Если клиент повторяет запрос (например, после истечения таймаута) с тем же заголовком Idempotency-Key
+и тем же содержимым, запрос не выполняется повторно: клиент получает исходный ответ с заголовком
+Idempotent-Replayed: true. Ответы хранятся в течение времени, заданного WithIdempotencyTTL.
+Ответы с ошибками не сохраняются, поэтому после ошибки запрос с тем же ключом выполняется заново.
+
+Ошибки:
+  400 - ключ длиннее 255 символов или содержит недопустимые символы
+  409 - запрос с этим ключом еще выполняется
+  422 - ключ уже использован для запроса с другим методом, путем или телом
+*/
+func (ns *NotesService) idempotent(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotencyKeyHeader)
+		if ns.idempotencyKeys == nil || key == "" {
+			handler(w, req)
+			return
+		}
+
+		// Проверка ключа и вычисление отпечатка запроса
+		status, err := validateIdempotencyKey(key)
+		var requestBytes []byte
+		if err == nil {
+			requestBytes, err = io.ReadAll(req.Body)
+			status = http.StatusBadRequest
+		}
+		if err != nil {
+			respondIdempotencyError(w, status, err)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBytes))
+		fingerprint := sha256.Sum256([]byte(req.Method + " " + req.URL.RequestURI() + "\n" + string(requestBytes)))
+
+		// Повтор уже выполненного запроса. Ключи разных пользователей не пересекаются.
+		identity, _ := identityFromRequest(req)
+		key = fmt.Sprintf("%d:%s", identity.ID, key)
+		saved, err := ns.idempotencyKeys.Begin(key, hex.EncodeToString(fingerprint[:]))
+		switch {
+		case errors.Is(err, idempotency.ErrInProgress):
+			respondIdempotencyError(w, http.StatusConflict, err)
+			return
+		case errors.Is(err, idempotency.ErrKeyReused):
+			respondIdempotencyError(w, http.StatusUnprocessableEntity, err)
+			return
+		case saved != nil:
+			for name, values := range saved.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(saved.Status)
+			_, _ = w.Write(saved.Body)
+			log.Printf("idempotent: replayed response to %s %s {key: %q}", req.Method, req.URL.Path, key)
+			return
+		}
+
+		// Первое выполнение запроса с сохранением ответа
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				ns.idempotencyKeys.Abort(key)
+			}
+		}()
+		handler(recorder, req)
+		if recorder.succeeded() {
+			ns.idempotencyKeys.Complete(key, &idempotency.Response{
+				Status: recorder.status,
+				Header: w.Header().Clone(),
+				Body:   recorder.body.Bytes(),
+			})
+			completed = true
+		}
+	}
+}
+
+// validateIdempotencyKey проверяет, что ключ состоит из видимых символов ASCII и не длиннее maxIdempotencyKeyLength
+func validateIdempotencyKey(key string) (int, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return http.StatusBadRequest, fmt.Errorf("invalid %s: longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return http.StatusBadRequest, fmt.Errorf("invalid %s: only visible ASCII characters are allowed", idempotencyKeyHeader)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// respondIdempotencyError отвечает клиенту ошибкой обработки ключа идемпотентности
+func respondIdempotencyError(w http.ResponseWriter, status int, err error) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("respondIdempotencyError()")
+
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+	wErr.LogMsg(err.Error())
+}
+
+// responseRecorder передает ответ клиенту, одновременно запоминая его статус и тело
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// succeeded проверяет, что запрос выполнен успешно: статус 2xx и "result": "OK"
+// (маршруты /create и /create-many сообщают об ошибках со статусом 200)
+func (r *responseRecorder) succeeded() bool {
+	if r.status < 200 || r.status > 299 {
+		return false
+	}
+	result := struct {
+		Result string `json:"result"`
+	}{}
+	err := json.Unmarshal(r.body.Bytes(), &result)
+	return err == nil && result.Result == "OK"
+}
@@ -0,0 +1,59 @@
+package notesService
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"notesServer/gates/auth"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"strings"
+)
+
+// WithJWT включает вход по JWT, выданным внешним сервером авторизации. По умолчанию принимаются только API-токены.
+func WithJWT(verifier *auth.JWTVerifier) Option {
+	return func(ns *NotesService) {
+		ns.jwt = verifier
+	}
+}
+
+// authenticateJWT проверяет JWT из заголовка Authorization: Bearer <jwt> и сохраняет пользователя,
+// которому он выдан, в контексте запроса (см. auth.JWTVerifier.Identify). Владельцем заметок, созданных с JWT,
+// становится учетная запись пользователя с iss и sub из токена: она создается при первом запросе. Если логин
+// из токена уже занят учетной записью, зарегистрированной с паролем, или другим пользователем JWT, возвращается 401.
+// Запросы с API-токенами и без токена передаются в authenticate без изменений.
+// С недействительным JWT (подпись, exp, nbf, iss, aud) клиент получает ответ со статусом 401.
+func (ns *NotesService) authenticateJWT(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if ns.jwt == nil || !ok || !auth.LooksLikeJWT(token) ||
+			publicPaths[req.URL.Path] || strings.HasPrefix(req.URL.Path, publicNotesPrefix) || req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		identity, err := ns.jwt.Identify(ns.users, token)
+		if errors.Is(err, auth.ErrInvalidJWT) {
+			respondUnauthorized(w, `Bearer realm="notes", error="invalid_token"`, err.Error())
+			return
+		}
+		if err != nil {
+			respondInternalError(w, err)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), identityContextKey{}, identity)))
+	})
+}
+
+// respondInternalError отвечает клиенту статусом 500, записывая ошибку в лог
+func respondInternalError(w http.ResponseWriter, err error) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("respondInternalError()")
+
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+	wErr.Specify(err, "respondInternalError()").LogError()
+}
@@ -0,0 +1,297 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/sharing"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+	"strings"
+	"time"
+)
+
+// Параметры публичных ссылок на заметки
+const (
+	publicNotesPrefix = "/public/notes/" // маршрут чтения заметки по ссылке, доступный без API-токена
+	defaultLinkTTL    = 24 * time.Hour
+	maxLinkTTL        = 30 * 24 * time.Hour
+)
+
+// WithLinks задает секреты публичных ссылок на заметки. По умолчанию они хранятся только в памяти.
+func WithLinks(links *sharing.Links) Option {
+	return func(ns *NotesService) {
+		ns.links = links
+	}
+}
+
+// isLinksPath проверяет, что путь относится к публичным ссылкам на заметку: /notes/{id}/links
+func isLinksPath(path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/notes/"), "/")
+	return len(parts) == 2 && parts[1] == "links"
+}
+
+// routeNoteLinks направляет запрос к /notes/{id}/links обработчику его метода
+func (ns *NotesService) routeNoteLinks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		ns.handleCreateLink(w, req)
+	case http.MethodDelete:
+		ns.handleRevokeLinks(w, req)
+	default:
+		handleMethodNotAllowed(w, req, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// handleCreateLink обрабатывает запрос на создание публичной ссылки на запись
+/*
+Запрос должен быть с методом POST: POST /notes/{id}/links, с содержимым в формате JSON следующего вида
+(содержимое можно не передавать):
+  {"ttl": "72h", "once": true}
+
+ttl - срок действия ссылки (по умолчанию 24h, не более 720h), once - ссылка открывается только один раз.
+По ссылке запись можно прочитать без учетной записи (см. handlePublicNote). Ссылка подписана секретом записи,
+поэтому ее нельзя подделать или изменить. Создавать ссылки может только владелец записи или администратор.
+
+Возвращает клиенту ответ со статусом 201 и ссылкой:
+  {"result": "OK", "data": {"url": "http://host/public/notes/1?expires=1696161600&nonce=...&sig=...",
+   "expires_at": "2023-10-01T12:00:00Z", "once": true}, "error": ""}
+
+В случае ошибки (400, 403, 404, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleCreateLink(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleCreateLink()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Получение ID из пути и парсинг запроса
+	id, status, err := noteIDFromPath(strings.TrimSuffix(req.URL.Path, "/links"))
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	linkRequest := &dto.LinkRequest{}
+	if len(requestBytes) != 0 {
+		err = json.Unmarshal(requestBytes, linkRequest)
+		if err != nil {
+			messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+			resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+			wErr.LogMsg(messageString)
+			return
+		}
+	}
+	ttl := defaultLinkTTL
+	if linkRequest.TTL != "" {
+		ttl, err = time.ParseDuration(linkRequest.TTL)
+		if err != nil || ttl <= 0 || ttl > maxLinkTTL {
+			messageString := fmt.Sprintf("invalid ttl: %q (need a positive duration up to %s)", linkRequest.TTL, maxLinkTTL)
+			resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+			wErr.LogMsg(messageString)
+			return
+		}
+	}
+
+	// Проверка доступа
+	_, status, err = ns.authorizeNote(req, id, accessOwner)
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authorizeNote(req, id, accessOwner)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Создание ссылки
+	link, err := ns.links.Create(id, ttl, linkRequest.Once)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.links.Create(id, ttl, linkRequest.Once)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	linkJson, err := json.Marshal(&dto.Link{
+		URL:       publicLinkURL(req, link),
+		ExpiresAt: link.ExpiresAt.UTC(),
+		Once:      link.Nonce != "",
+	})
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(link)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusCreated, "OK", linkJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - POST /notes/%d/links: {ttl: %s, once: %t}", id, ttl, linkRequest.Once))
+}
+
+// handleRevokeLinks обрабатывает запрос на отзыв всех публичных ссылок на запись
+/*
+Запрос должен быть с методом DELETE: DELETE /notes/{id}/links. Тело запроса игнорируется.
+
+Секрет записи заменяется новым, поэтому все выданные ранее ссылки перестают действовать.
+Отзывать ссылки может только владелец записи или администратор.
+
+Возвращает клиенту ответ со статусом 204 без содержимого.
+
+В случае ошибки (400, 403, 404, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleRevokeLinks(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleRevokeLinks()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Получение ID из пути и проверка доступа
+	id, status, err := noteIDFromPath(strings.TrimSuffix(req.URL.Path, "/links"))
+	if err == nil {
+		_, status, err = ns.authorizeNote(req, id, accessOwner)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authorizeNote(req, id, accessOwner)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Смена секрета
+	err = ns.links.Rotate(id)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.links.Rotate(id)").LogError()
+		return
+	}
+
+	resp.UpdateWithStatus(http.StatusNoContent, "OK", nil, "")
+	wErr.LogMsg(fmt.Sprintf("OK - DELETE /notes/%d/links", id))
+}
+
+// handlePublicNote обрабатывает запрос на чтение записи по публичной ссылке
+/*
+Запрос должен быть с методом GET: GET /public/notes/{id}?expires=...&sig=... (ссылка из POST /notes/{id}/links).
+API-токен не требуется. Тело запроса игнорируется.
+
+Возвращает клиенту ответ со статусом 200 и записью без сведений о ее владельце:
+  {"result": "OK", "data": {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки"}, "error": ""}
+
+В случае ошибки (400 - неполная ссылка, 403 - неверная подпись или ссылки отозваны, 404, 405,
+410 - срок действия истек или одноразовая ссылка уже открыта, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handlePublicNote(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handlePublicNote()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodGet)
+		resp.UpdateWithStatus(http.StatusMethodNotAllowed, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Получение ID из пути и проверка ссылки
+	id, status, err := noteIDFromPath(strings.TrimPrefix(req.URL.Path, "/public"))
+	var link sharing.Link
+	if err == nil {
+		link, err = sharing.ParseLink(id, req.URL.Query())
+		status = http.StatusBadRequest
+	}
+	if err == nil {
+		err = ns.links.Use(link)
+		status = statusForLinkError(err)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.links.Use(link)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), id))
+		return
+	}
+
+	// Получение записи по ID
+	pureNoteAny, ok := ns.getStorage().GetByID(id)
+	if !ok {
+		messageString := fmt.Sprintf("cannot find note with id %d", id)
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	pureNote, ok := pureNoteAny.(entity.PureNote)
+	if !ok {
+		err = errors.New("cannot convert interface{} to PureNote")
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "pureNote, ok := pureNoteAny.(PureNote)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа. Владелец записи не раскрывается.
+	note := pureNote.ToNoteWithID(id)
+	note.OwnerID = 0
+	noteJson, err := json.Marshal(note)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(note)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", noteJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - GET /public/notes/%d: {once: %t}", id, link.Nonce != ""))
+}
+
+// statusForLinkError возвращает HTTP-статус для ошибки проверки публичной ссылки
+func statusForLinkError(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, sharing.ErrLinkInvalid):
+		return http.StatusForbidden
+	case errors.Is(err, sharing.ErrLinkExpired), errors.Is(err, sharing.ErrLinkUsed):
+		return http.StatusGone
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// publicLinkURL возвращает полный URL публичной ссылки на сервер, которому отправлен запрос
+func publicLinkURL(req *http.Request, link sharing.Link) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s%d?%s", scheme, req.Host, publicNotesPrefix, link.NoteID, link.Query().Encode())
+}
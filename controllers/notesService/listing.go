@@ -0,0 +1,277 @@
+package notesService
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg/analysis"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxListLimit - максимальный размер страницы списка заметок
+const maxListLimit = 1000
+
+// Поля сортировки списка заметок
+const (
+	sortByID            = "id"
+	sortByName          = "name"
+	sortByLastName      = "last_name"
+	sortByContentLength = "content_length"
+	sortManually        = "manual" // пользовательский порядок, заданный через /reorder
+)
+
+// listParams - параметры URL списка заметок:
+//
+//	?limit=20                - размер страницы (по умолчанию без ограничения, не больше maxListLimit)
+//	?offset=40               - количество пропускаемых записей
+//	?cursor=...              - курсор из поля next_cursor предыдущей страницы (нельзя совмещать с offset)
+//	?sort=name               - поле сортировки: id (по умолчанию), name, last_name, content_length, manual
+//	?order=desc              - направление сортировки: asc (по умолчанию) или desc
+//	?name=Имя&last_name=...  - фильтры по имени и фамилии автора (без учета регистра и различия ё/е)
+//	?author=подстрока        - фильтр по подстроке "Имя Фамилия" автора (без учета регистра и различия ё/е)
+//	?owner=5                 - фильтр по ID пользователя-владельца
+//
+// Для совместимости ?order=manual равносильно ?sort=manual.
+type listParams struct {
+	limit      int
+	offset     int
+	cursor     *listCursor
+	sort       string
+	descending bool
+	filter     *dto.NoteFilter
+	author     string
+	owner      int64
+	visible    func(id int64, v any) bool // отбирает заметки, доступные пользователю запроса (см. NotesService.accessibleNotes)
+}
+
+// listCursor - позиция в списке заметок, после которой начинается следующая страница.
+// При сортировке по полю хранится ключ последней записи страницы, поэтому добавление и удаление записей
+// не сдвигают страницы. В пользовательском порядке хранится смещение следующей страницы.
+type listCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Key        string `json:"k,omitempty"`
+	Length     int    `json:"l,omitempty"`
+	ID         int64  `json:"i,omitempty"`
+	Offset     int    `json:"o,omitempty"`
+}
+
+// noteSortKey - ключ сортировки заметки: сравниваются Key, затем Length, затем ID
+type noteSortKey struct {
+	key    string
+	length int
+	id     int64
+}
+
+func (a noteSortKey) less(b noteSortKey) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	if a.length != b.length {
+		return a.length < b.length
+	}
+	return a.id < b.id
+}
+
+// parseListParams разбирает параметры URL списка заметок
+func parseListParams(query url.Values) (*listParams, error) {
+	params := &listParams{sort: sortByID, filter: dto.NewNoteFilter()}
+
+	var err error
+	if query.Has("limit") {
+		params.limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || params.limit < 1 || params.limit > maxListLimit {
+			return nil, fmt.Errorf("invalid 'limit': need an integer from 1 to %d", maxListLimit)
+		}
+	}
+	if query.Has("offset") {
+		params.offset, err = strconv.Atoi(query.Get("offset"))
+		if err != nil || params.offset < 0 {
+			return nil, errors.New("invalid 'offset': need a non-negative integer")
+		}
+	}
+
+	// Сортировка
+	order := query.Get("order")
+	if order == sortManually {
+		order = ""
+		params.sort = sortManually
+	}
+	if query.Has("sort") {
+		sortField := query.Get("sort")
+		if params.sort == sortManually && sortField != sortManually {
+			return nil, errors.New("'order=manual' cannot be combined with another 'sort'")
+		}
+		switch sortField {
+		case sortByID, sortByName, sortByLastName, sortByContentLength, sortManually:
+			params.sort = sortField
+		default:
+			return nil, fmt.Errorf("invalid 'sort': %q (need id, name, last_name, content_length or manual)", sortField)
+		}
+	}
+	switch order {
+	case "", "asc":
+	case "desc":
+		if params.sort == sortManually {
+			return nil, errors.New("'order=desc' is not supported for manual order")
+		}
+		params.descending = true
+	default:
+		return nil, fmt.Errorf("invalid 'order': %q (need asc or desc)", order)
+	}
+
+	// Курсор
+	if query.Has("cursor") {
+		if query.Has("offset") {
+			return nil, errors.New("'cursor' and 'offset' cannot be used together")
+		}
+		params.cursor, err = decodeListCursor(query.Get("cursor"))
+		if err != nil {
+			return nil, err
+		}
+		if params.cursor.Sort != params.sort || params.cursor.Descending != params.descending {
+			return nil, errors.New("'cursor' was issued for another 'sort' or 'order'")
+		}
+	}
+
+	// Фильтры
+	params.filter.Name = query.Get("name")
+	params.filter.LastName = query.Get("last_name")
+	params.author = analysis.Normalize(query.Get("author"))
+	if query.Has("owner") {
+		params.owner, err = strconv.ParseInt(query.Get("owner"), 10, 64)
+		if err != nil || params.owner < 1 {
+			return nil, errors.New("invalid 'owner': need a positive integer")
+		}
+	}
+	return params, nil
+}
+
+// listNotes возвращает страницу заметок reader по параметрам params, общее количество заметок,
+// удовлетворяющих фильтрам, и курсор следующей страницы.
+// В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту.
+func (ns *NotesService) listNotes(reader noteReader, params *listParams) (page []*dto.Note, total int, nextCursor string, status int, err error) {
+	// Отбор записей по фильтрам и преобразование из map[int64]interface{} в []*dto.Note
+	allNotesMap, _ := reader.GetAll()
+	if err = snapshotErr(reader); err != nil {
+		return nil, 0, "", http.StatusGone, err
+	}
+	matches := noteFilterPredicate(params.filter)
+	notes := make([]*dto.Note, 0, len(allNotesMap))
+	for id, pureNoteAny := range allNotesMap {
+		pureNote, ok := pureNoteAny.(entity.PureNote)
+		if !ok {
+			return nil, 0, "", http.StatusInternalServerError, errors.New("cannot convert interface{} to PureNote")
+		}
+		if !matches(id, pureNote) || (params.visible != nil && !params.visible(id, pureNote)) {
+			continue
+		}
+		if params.owner != 0 && pureNote.Owner() != params.owner {
+			continue
+		}
+		if params.author != "" && !strings.Contains(analysis.Normalize(pureNote.Name()+" "+pureNote.LastName()), params.author) {
+			continue
+		}
+		notes = append(notes, pureNote.ToNoteWithID(id))
+	}
+
+	// Сортировка
+	sort.Slice(notes, func(i, j int) bool {
+		iKey, jKey := sortKeyOf(notes[i], params.sort), sortKeyOf(notes[j], params.sort)
+		if params.descending {
+			return jKey.less(iKey)
+		}
+		return iKey.less(jKey)
+	})
+	if params.sort == sortManually {
+		err = ns.sortNotesManually(notes)
+		if err != nil {
+			return nil, 0, "", http.StatusBadRequest, err
+		}
+	}
+
+	// Определение начала страницы
+	start := min(params.offset, len(notes))
+	if params.cursor != nil {
+		start = params.cursor.start(notes)
+	}
+	end := len(notes)
+	if params.limit > 0 {
+		end = min(start+params.limit, len(notes))
+	}
+	page = notes[start:end]
+
+	// Курсор следующей страницы
+	if end < len(notes) && len(page) != 0 {
+		nextCursor, err = encodeListCursor(page[len(page)-1], params, end)
+		if err != nil {
+			return nil, 0, "", http.StatusInternalServerError, err
+		}
+	}
+	return page, len(notes), nextCursor, http.StatusOK, nil
+}
+
+// sortKeyOf возвращает ключ сортировки заметки по полю sortField
+func sortKeyOf(note *dto.Note, sortField string) noteSortKey {
+	switch sortField {
+	case sortByName:
+		return noteSortKey{key: strings.ToLower(note.Name), id: note.ID}
+	case sortByLastName:
+		return noteSortKey{key: strings.ToLower(note.LastName), id: note.ID}
+	case sortByContentLength:
+		return noteSortKey{length: utf8.RuneCountInString(note.Content), id: note.ID}
+	default:
+		return noteSortKey{id: note.ID}
+	}
+}
+
+// start возвращает индекс первой заметки следующей страницы в отсортированном списке notes
+func (c *listCursor) start(notes []*dto.Note) int {
+	if c.Sort == sortManually {
+		return min(c.Offset, len(notes))
+	}
+	last := noteSortKey{key: c.Key, length: c.Length, id: c.ID}
+	return sort.Search(len(notes), func(i int) bool {
+		key := sortKeyOf(notes[i], c.Sort)
+		if c.Descending {
+			return key.less(last)
+		}
+		return last.less(key)
+	})
+}
+
+// encodeListCursor возвращает курсор страницы, следующей за заметкой last, которая стоит перед позицией next
+func encodeListCursor(last *dto.Note, params *listParams, next int) (string, error) {
+	key := sortKeyOf(last, params.sort)
+	cursor := listCursor{Sort: params.sort, Descending: params.descending, Key: key.key, Length: key.length, ID: key.id}
+	if params.sort == sortManually {
+		cursor = listCursor{Sort: sortManually, Offset: next}
+	}
+	cursorJson, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJson), nil
+}
+
+// decodeListCursor разбирает курсор, полученный из encodeListCursor
+func decodeListCursor(cursorString string) (*listCursor, error) {
+	cursorJson, err := base64.RawURLEncoding.DecodeString(cursorString)
+	if err != nil {
+		return nil, errors.New("invalid 'cursor'")
+	}
+	cursor := &listCursor{}
+	err = json.Unmarshal(cursorJson, cursor)
+	if err != nil || cursor.Offset < 0 {
+		return nil, errors.New("invalid 'cursor'")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,66 @@
+package notesService
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+)
+
+// handleGetMetrics обрабатывает запрос на получение состояния сервиса
+/*
+Запрос должен быть с методом GET. Тело запроса игнорируется. Доступен только администраторам (см. requireAdmin).
+
+Возвращает клиенту ответ со статусом 200 и с содержимым в формате JSON следующего вида
+(rate_limits - состояние лимитеров маршрутов, заданных WithRateLimits):
+  {"result": "OK", "data": {"notes": 10, "users": 2, "rate_limits": {
+   "/create": {"rate": 5, "burst": 20, "clients": 1, "allowed": 42, "limited": 3}}}, "error": ""}
+
+В случае ошибки (403, 405, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleGetMetrics(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetMetrics()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodGet)
+		resp.UpdateWithStatus(http.StatusMethodNotAllowed, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Сбор состояния
+	metrics := &dto.Metrics{
+		Notes:      ns.getStorage().Len(),
+		Users:      ns.users.Len(),
+		RateLimits: make(map[string]dto.RateLimitStats),
+	}
+	for route, stats := range ns.rateLimits.Stats() {
+		metrics.RateLimits[route] = dto.RateLimitStats{
+			Rate:    stats.Rate,
+			Burst:   stats.Burst,
+			Clients: stats.Clients,
+			Allowed: stats.Allowed,
+			Limited: stats.Limited,
+		}
+	}
+
+	// Формирование содержимого для ответа
+	metricsJson, err := json.Marshal(metrics)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(metrics)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", metricsJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - GET /admin/metrics: {rate_limits: %d}", len(metrics.RateLimits)))
+}
@@ -0,0 +1,201 @@
+package notesService
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"runtime/debug"
+	"time"
+)
+
+// Параметры идентификаторов запросов
+const (
+	requestIDHeader       = "X-Request-ID"
+	maxRequestIDLength    = 128
+	generatedRequestBytes = 16
+)
+
+// requestIDContextKey - ключ контекста запроса, под которым middleware requestID сохраняет его идентификатор
+type requestIDContextKey struct{}
+
+// WithAccessLog задает, куда записываются строки журнала запросов (см. accessLog). По умолчанию - в os.Stderr.
+func WithAccessLog(w io.Writer) Option {
+	return func(ns *NotesService) {
+		ns.accessLogger = slog.New(slog.NewJSONHandler(w, nil))
+	}
+}
+
+// chain оборачивает handler в middleware: первая из них получает запрос первой
+func chain(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// requestID назначает запросу идентификатор и возвращает его клиенту в заголовке X-Request-ID.
+// Если клиент (или прокси перед сервером) уже передал X-Request-ID из видимых символов ASCII
+// не длиннее 128 символов, используется он, иначе создается новый.
+func (ns *NotesService) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requestIDContextKey{}, id)))
+	})
+}
+
+// requestIDFromRequest возвращает идентификатор запроса, назначенный middleware requestID
+func requestIDFromRequest(req *http.Request) string {
+	id, _ := req.Context().Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// accessLog записывает в журнал запросов по одной строке в формате JSON на каждый запрос:
+/*
+  {"time": "2023-10-01T12:00:00Z", "level": "INFO", "msg": "request", "request_id": "...", "method": "POST",
+   "path": "/create", "status": 200, "latency_ms": 1.25, "bytes": 41, "remote": "127.0.0.1:51234"}
+
+Строка записывается после завершения обработки, в том числе после паники в обработчике (см. recoverPanic).
+*/
+func (ns *NotesService) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		meter := meterOf(w)
+		defer func() {
+			ns.accessLogger.LogAttrs(req.Context(), slog.LevelInfo, "request",
+				slog.String("request_id", requestIDFromRequest(req)),
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", meter.status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes", meter.bytes),
+				slog.String("remote", req.RemoteAddr),
+			)
+		}()
+		next.ServeHTTP(meter, req)
+	})
+}
+
+// recoverPanic перехватывает панику в обработчике запроса, записывает ее вместе со стеком в лог
+// и отвечает клиенту статусом 500 с идентификатором запроса:
+/*
+  {"result": "ERROR", "data": null, "error": "internal server error", "request_id": "..."}
+
+Если обработчик уже начал отправлять ответ, изменить его нельзя: соединение закрывается без ответа 500.
+Паника http.ErrAbortHandler, которой обработчики прерывают ответ намеренно, не перехватывается.
+*/
+func (ns *NotesService) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		meter := meterOf(w)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+
+			wErr := pkg.NewWrappedError("(ns *NotesService) recoverPanic()")
+			requestID := requestIDFromRequest(req)
+			err := fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())
+			wErr.Specify(err, fmt.Sprintf("%s %s {request_id: %q}", req.Method, req.URL.Path, requestID)).LogError()
+			if meter.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			respondPanic(meter, requestID)
+		}()
+		next.ServeHTTP(meter, req)
+	})
+}
+
+// respondPanic отвечает клиенту статусом 500 после паники в обработчике запроса
+func respondPanic(w http.ResponseWriter, requestID string) {
+	setHttpHeaders(w)
+
+	// Заголовки, которые обработчик успел установить до паники, к ответу 500 не относятся
+	for _, name := range []string{"ETag", "Location", "Allow", "WWW-Authenticate", "Retry-After", idempotentReplayedHeader} {
+		w.Header().Del(name)
+	}
+
+	resp := &dto.Response{RequestID: requestID}
+	resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+	w.WriteHeader(resp.Status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// responseMeter передает ответ клиенту, подсчитывая его статус и размер тела
+type responseMeter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// meterOf возвращает w, если это уже responseMeter, иначе оборачивает w в новый responseMeter
+func meterOf(w http.ResponseWriter) *responseMeter {
+	if meter, ok := w.(*responseMeter); ok {
+		return meter
+	}
+	return &responseMeter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (m *responseMeter) WriteHeader(status int) {
+	if !m.wroteHeader {
+		m.status = status
+		m.wroteHeader = true
+	}
+	m.ResponseWriter.WriteHeader(status)
+}
+
+func (m *responseMeter) Write(p []byte) (int, error) {
+	m.wroteHeader = true
+	n, err := m.ResponseWriter.Write(p)
+	m.bytes += int64(n)
+	return n, err
+}
+
+// Flush отправляет клиенту накопленные данные, если это поддерживает исходный http.ResponseWriter
+func (m *responseMeter) Flush() {
+	if flusher, ok := m.ResponseWriter.(http.Flusher); ok {
+		m.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Unwrap возвращает исходный http.ResponseWriter: через него http.ResponseController находит
+// Hijack, SetReadDeadline и другие возможности, которые responseMeter не передает сам
+func (m *responseMeter) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
+
+// validRequestID проверяет идентификатор запроса, переданный клиентом
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID возвращает новый случайный идентификатор запроса
+func newRequestID() string {
+	random := make([]byte, generatedRequestBytes)
+	_, _ = rand.Read(random)
+	return hex.EncodeToString(random)
+}
@@ -0,0 +1,44 @@
+package notesService
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// errHijacked возвращает hijackRecorder, чтобы проверить, что вызов Hijack дошел до него
+var errHijacked = errors.New("hijack reached the underlying writer")
+
+// hijackRecorder - httptest.ResponseRecorder, поддерживающий http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errHijacked
+}
+
+// Журнал запросов оборачивает ответ, но обработчики по-прежнему могут отправлять данные частями и перехватывать соединение
+func TestAccessLogKeepsResponseWriterFeatures(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	handler := ts.ns.accessLog(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		controller := http.NewResponseController(w)
+		if _, _, err := controller.Hijack(); !errors.Is(err, errHijacked) {
+			t.Errorf("Hijack() through the access log: %v", err)
+		}
+		_, _ = io.WriteString(w, "part")
+		if err := controller.Flush(); err != nil {
+			t.Errorf("Flush() through the access log: %v", err)
+		}
+	}))
+
+	recorder := hijackRecorder{httptest.NewRecorder()}
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/get-all", nil))
+	if !recorder.Flushed || recorder.Body.String() != "part" {
+		t.Fatalf("response flushed = %t, body = %q", recorder.Flushed, recorder.Body.String())
+	}
+}
@@ -0,0 +1,184 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/migration"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+)
+
+// unguardedPaths - маршруты, обработчики которых сами заменяют хранилище и поэтому не должны удерживать storageMu:
+// замена ждет завершения запросов, удерживающих storageMu, и не дождалась бы запроса, который ее выполняет
+var unguardedPaths = map[string]bool{
+	"/admin/migrate": true,
+}
+
+// guardStorage удерживает storageMu на чтение на время обработки запроса,
+// чтобы хранилище не было заменено посреди запроса
+func (ns *NotesService) guardStorage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if unguardedPaths[req.URL.Path] {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ns.storageMu.RLock()
+		defer ns.storageMu.RUnlock()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// errMigrationInProgress ошибка, возвращаемая MigrateStorage, если другая миграция еще не завершена
+var errMigrationInProgress = errors.New("migration is already in progress")
+
+// getStorage возвращает текущее хранилище заметок.
+// Вызывается из обработчиков запросов, которые уже удерживают storageMu через guardStorage.
+func (ns *NotesService) getStorage() storage.Storage {
+	return ns.storage
+}
+
+// getStorageSafely возвращает текущее хранилище заметок вне обработчиков, защищенных guardStorage
+func (ns *NotesService) getStorageSafely() storage.Storage {
+	ns.storageMu.RLock()
+	defer ns.storageMu.RUnlock()
+
+	return ns.storage
+}
+
+// setStorage заменяет хранилище заметок, дожидаясь завершения обрабатываемых запросов
+func (ns *NotesService) setStorage(st storage.Storage) {
+	ns.storageMu.Lock()
+	defer ns.storageMu.Unlock()
+
+	ns.storage = st
+}
+
+// CloseStorage закрывает текущее хранилище заметок, если оно этого требует (реализует io.Closer),
+// дождавшись завершения обрабатываемых запросов. Вызывается при остановке сервера.
+func (ns *NotesService) CloseStorage() error {
+	ns.storageMu.Lock()
+	defer ns.storageMu.Unlock()
+
+	if closer, ok := ns.storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// MigrateStorage переносит заметки в хранилище target с сохранением идентификаторов, не останавливая сервис.
+// На время копирования изменения записываются в оба хранилища. Если после копирования хранилища совпадают,
+// сервис переключается на target, открытые снимки прежнего хранилища освобождаются, а само оно закрывается.
+// Иначе сервис продолжает работать с прежним хранилищем и возвращает ошибку; target при этом не закрывается.
+// Ошибка закрытия прежнего хранилища записывается в лог: миграция к этому моменту уже завершена.
+//
+// Не должен вызываться из обработчика, удерживающего storageMu (см. guardStorage и unguardedPaths).
+func (ns *NotesService) MigrateStorage(target storage.Storage) (migration.Report, error) {
+	if !ns.migrationMu.TryLock() {
+		return migration.Report{}, errMigrationInProgress
+	}
+	defer ns.migrationMu.Unlock()
+
+	source := ns.getStorageSafely()
+
+	migrator, err := migration.NewMigrator(source, target)
+	if err != nil {
+		return migration.Report{}, err
+	}
+
+	ns.setStorage(migrator.DualWriter().Storage())
+	report, err := migrator.Run()
+	if err != nil {
+		ns.setStorage(source)
+		return report, err
+	}
+	ns.setStorage(target)
+
+	// Снимки и токены снимков относятся к прежнему хранилищу
+	ns.releaseSnapshots()
+	if closer, ok := source.(io.Closer); ok {
+		err = closer.Close()
+		if err != nil {
+			// Заметки уже перенесены и сервис работает с новым хранилищем: это не ошибка миграции
+			pkg.NewWrappedError("(ns *NotesService) MigrateStorage()").Specify(err, "source.Close()").LogError()
+		}
+	}
+	return report, nil
+}
+
+// handleMigrateStorage обрабатывает запрос на перенос заметок в другое хранилище без остановки сервиса
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"target": "file:///var/lib/notes"}
+
+target - строка подключения к пустому хранилищу (см. storage.Open). Во время переноса запросы к заметкам
+обрабатываются: изменения записываются и в прежнее, и в новое хранилище. После проверки совпадения хранилищ
+сервис переключается на новое хранилище, а прежнее закрывается. Снимки прежнего хранилища (/snapshot) освобождаются.
+Доступен только администраторам (см. requireAdmin).
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"copied": 10, "source_count": 10, "target_count": 10, "source_next_id": 11,
+   "target_next_id": 11, "target_errors": 0}, "error": ""}
+
+В случае ошибки (400 - неверная строка подключения, 409 - миграция уже идет или хранилища различаются, 500):
+  {"result": "ERROR", "data": {...отчет о проверке, если она выполнялась...}, "error": "error description"}
+*/
+func (ns *NotesService) handleMigrateStorage(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleMigrateStorage()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Парсинг запроса
+	migrateRequest := struct {
+		Target string `json:"target"`
+	}{}
+	status, err := parseJsonPost(w, req, &migrateRequest)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	if migrateRequest.Target == "" {
+		messageString := "required data is missing: target"
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Открытие нового хранилища и перенос заметок
+	target, err := storage.Open(migrateRequest.Target)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, "cannot open target storage: "+err.Error())
+		wErr.LogMsg("cannot open target storage: " + err.Error())
+		return
+	}
+	report, err := ns.MigrateStorage(target)
+	reportJson, marshalErr := json.Marshal(report)
+	if marshalErr != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, marshalErr.Error())
+		wErr.Specify(marshalErr, "json.Marshal(report)").LogError()
+		return
+	}
+	if err != nil {
+		// Сервис продолжает работать с прежним хранилищем, новое больше не нужно
+		if closer, ok := target.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, errMigrationInProgress) || errors.Is(err, migration.ErrVerificationFailed) {
+			status = http.StatusConflict
+		}
+		resp.UpdateWithStatus(status, "ERROR", reportJson, "cannot migrate storage: "+err.Error())
+		wErr.Specify(err, "ns.MigrateStorage(target)").LogError()
+		return
+	}
+	resp.Update("OK", reportJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - migrate: {copied: %d, next_id: %d}", report.Copied, report.TargetNextID))
+}
@@ -0,0 +1,172 @@
+package notesService
+
+import (
+	"encoding/json"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/migration"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeTracking - хранилище, запоминающее вызов Close
+type closeTracking struct {
+	storage.Migratable
+	closed atomic.Bool
+}
+
+func (c *closeTracking) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// migrate выполняет POST /admin/migrate и возвращает статус и отчет. Если запрос не завершился
+// за несколько секунд (например, из-за взаимной блокировки с guardStorage), тест завершается ошибкой.
+func (ts *testService) migrate(token string, target string) (int, migration.Report) {
+	ts.t.Helper()
+	type result struct {
+		status int
+		report migration.Report
+	}
+	done := make(chan result, 1)
+	go func() {
+		recorder, resp := ts.do(token, http.MethodPost, "/admin/migrate", `{"target": "`+target+`"}`)
+		report := migration.Report{}
+		_ = json.Unmarshal(resp.Data, &report)
+		done <- result{recorder.Code, report}
+	}()
+	select {
+	case r := <-done:
+		return r.status, r.report
+	case <-time.After(10 * time.Second):
+		ts.t.Fatal("/admin/migrate did not finish: deadlock?")
+		return 0, migration.Report{}
+	}
+}
+
+func TestMigrateStorageEndpoint(t *testing.T) {
+	source := &closeTracking{Migratable: openStorage(t, "map://?init_id=1").(storage.Migratable)}
+	ts := newTestService(t, source)
+	admin := ts.registerAdmin("admin")
+	first := ts.createNote(admin, "первая")
+	second := ts.createNote(admin, "вторая")
+	ts.do(admin, http.MethodDelete, "/notes/"+itoa(second), "")
+	third := ts.createNote(admin, "третья")
+
+	status, report := ts.migrate(admin, "list://?init_id=1")
+	if status != http.StatusOK || report.Copied != 2 || report.TargetNextID != third+1 {
+		t.Fatalf("/admin/migrate = %d %+v", status, report)
+	}
+	if !source.closed.Load() {
+		t.Fatal("source storage is not closed after migration")
+	}
+	if _, ok := ts.ns.getStorageSafely().(storage.Ordered); !ok {
+		t.Fatal("service does not use the target list storage after migration")
+	}
+
+	// Заметки и идентификаторы сохранились, новые заметки продолжают нумерацию
+	for id, content := range map[int64]string{first: "первая", third: "третья"} {
+		if note, status := ts.getNote(admin, id); status != http.StatusOK || note.Content != content {
+			t.Fatalf("GET /notes/%d after migration = %d %+v", id, status, note)
+		}
+	}
+	if _, status := ts.getNote(admin, second); status != http.StatusNotFound {
+		t.Fatalf("deleted note %d is back after migration: %d", second, status)
+	}
+	if id := ts.createNote(admin, "четвертая"); id != third+1 {
+		t.Fatalf("new note id after migration = %d, want %d", id, third+1)
+	}
+}
+
+func TestMigrateStorageRejectsRequests(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	admin := ts.registerAdmin("admin")
+	user := ts.register("alice")
+	ts.createNote(admin, "заметка")
+
+	if status, _ := ts.migrate(user, "list://"); status != http.StatusForbidden {
+		t.Fatalf("/admin/migrate by non-admin = %d", status)
+	}
+	if status, _ := ts.migrate(admin, "unknown://"); status != http.StatusBadRequest {
+		t.Fatalf("/admin/migrate to unknown scheme = %d", status)
+	}
+	if _, ok := ts.ns.getStorageSafely().(storage.Ordered); ok {
+		t.Fatal("storage changed after rejected migrations")
+	}
+}
+
+func TestMigrateStorageUnderConcurrentWrites(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	admin := ts.registerAdmin("admin")
+	for i := 0; i < 200; i++ {
+		ts.createNote(admin, "заметка")
+	}
+
+	// Заметки создаются и до, и во время, и после миграции
+	stop := make(chan struct{})
+	created := make(chan int64, 10000)
+	wg := sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					created <- ts.createNote(admin, "во время миграции")
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	status, report := ts.migrate(admin, "mvcc://?init_id=1")
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+	close(created)
+
+	if status != http.StatusOK || report.TargetErrors != 0 {
+		t.Fatalf("/admin/migrate = %d %+v", status, report)
+	}
+	if _, ok := ts.ns.getStorageSafely().(storage.Versioned); !ok {
+		t.Fatal("service does not use the target mvcc storage after migration")
+	}
+	count := int64(200)
+	for id := range created {
+		count++
+		if _, ok := ts.ns.getStorageSafely().GetByID(id); !ok {
+			t.Fatalf("note %d created during migration is missing", id)
+		}
+	}
+	if n := ts.ns.getStorageSafely().Len(); n != count {
+		t.Fatalf("storage has %d notes after migration, want %d", n, count)
+	}
+}
+
+func TestMigrateStoragePreservesManualOrder(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "list://?init_id=1"))
+	admin := ts.registerAdmin("admin")
+	first := ts.createNote(admin, "первая")
+	second := ts.createNote(admin, "вторая")
+	third := ts.createNote(admin, "третья")
+	ts.do(admin, http.MethodPost, "/reorder", `{"id": `+itoa(third)+`, "position": "front"}`)
+
+	if status, report := ts.migrate(admin, "list://?init_id=1"); status != http.StatusOK {
+		t.Fatalf("/admin/migrate = %d %+v", status, report)
+	}
+
+	items, _ := ts.ns.getStorageSafely().(storage.Ordered).GetAllOrdered()
+	want := []int64{third, first, second}
+	if len(items) != len(want) {
+		t.Fatalf("got %d notes after migration, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.ID != want[i] {
+			t.Fatalf("manual order after migration: item %d is note %d, want %d", i, item.ID, want[i])
+		}
+	}
+}
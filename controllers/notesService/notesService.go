@@ -5,32 +5,106 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"notesServer/gates/auth"
+	"notesServer/gates/history"
+	"notesServer/gates/idempotency"
+	"notesServer/gates/ratelimit"
+	"notesServer/gates/search"
+	"notesServer/gates/sharing"
 	"notesServer/gates/storage"
 	"notesServer/models/dto"
 	"notesServer/models/entity"
 	"notesServer/pkg"
-	"sort"
+	"notesServer/pkg/analysis"
+	"os"
+	"sync"
 )
 
 type NotesService struct {
-	server  http.Server
-	storage storage.Storage
+	server      http.Server
+	storage     storage.Storage
+	storageMu   sync.RWMutex // удерживается на чтение на время каждого запроса и на запись при замене хранилища
+	migrationMu sync.Mutex   // не дает запустить две миграции одновременно
+	writeMu     sync.Mutex   // удерживается от проверки If-Match до изменения заметки
+	history     *history.History
+	index       *search.Index           // полнотекстовый индекс заметок
+	authors     *search.FuzzyIndex      // нечеткий индекс имен и фамилий авторов заметок
+	snapshots   map[string]openSnapshot // снимки, созданные через /snapshot, по их токенам
+	snapshotsMu sync.Mutex
+
+	idempotencyKeys *idempotency.Store // ответы на запросы создания заметок по ключам Idempotency-Key (nil - отключено)
+	users           *auth.Users        // учетные записи и API-токены пользователей
+	shares          *sharing.Shares    // права доступа к заметкам, выданные их владельцами
+	links           *sharing.Links     // секреты публичных ссылок на заметки
+	jwt             *auth.JWTVerifier  // проверка JWT внешнего сервера авторизации (nil - вход только по API-токенам)
+	rateLimits      *ratelimit.Routes  // ограничения частоты запросов по маршрутам
+	accessLogger    *slog.Logger       // журнал запросов, см. accessLog
 }
 
-func NewNotesService(addr string, storage storage.Storage) (service *NotesService) {
+// Option - дополнительная настройка сервиса заметок, передаваемая в NewNotesService
+type Option func(ns *NotesService)
+
+func NewNotesService(addr string, st storage.Storage, opts ...Option) (service *NotesService) {
 	service = new(NotesService)
 	service.server = http.Server{}
 	router := http.NewServeMux()
-	router.HandleFunc("/create", service.handleCreateNote)
+	router.HandleFunc("/create", service.idempotent(service.handleCreateNote))
 	router.HandleFunc("/get", service.handleGetNote)
 	router.HandleFunc("/update", service.handleUpdateNote)
 	router.HandleFunc("/delete", service.handleDeleteNoteByID)
 	router.HandleFunc("/get-all", service.handleGetAllNotes)
-	service.server.Handler = router
+	router.HandleFunc("/create-many", service.idempotent(service.handleCreateNotes))
+	router.HandleFunc("/update-many", service.handleUpdateNotes)
+	router.HandleFunc("/delete-many", service.handleDeleteNotes)
+	router.HandleFunc("/find", service.handleFindNotes)
+	router.HandleFunc("/reorder", service.handleReorderNote)
+	router.HandleFunc("/revisions", service.handleGetRevisions)
+	router.HandleFunc("/revision", service.handleGetRevision)
+	router.HandleFunc("/diff", service.handleDiffRevisions)
+	router.HandleFunc("/revert", service.handleRevertNote)
+	router.HandleFunc("/snapshot", service.handleCreateSnapshot)
+	router.HandleFunc("/release-snapshot", service.handleReleaseSnapshot)
+	router.HandleFunc("/admin/scrub", requireAdmin(service.handleScrubStorage))
+	router.HandleFunc("/admin/role", requireAdmin(service.handleSetRole))
+	router.HandleFunc("/admin/groups", requireAdmin(service.handleSetGroups))
+	router.HandleFunc("/admin/metrics", requireAdmin(service.handleGetMetrics))
+	router.HandleFunc("/admin/migrate", requireAdmin(service.handleMigrateStorage))
+	router.HandleFunc("/notes", service.routeNotes)
+	router.HandleFunc("/notes/", service.routeNote)
+	router.HandleFunc(publicNotesPrefix, service.handlePublicNote)
+	router.HandleFunc("/search", service.handleSearchNotes)
+	router.HandleFunc("/search-authors", service.handleSearchAuthors)
+	router.HandleFunc("/register", service.handleRegister)
+	router.HandleFunc("/login", service.handleLogin)
+	router.HandleFunc("/logout", service.handleLogout)
+	router.HandleFunc("/me", service.handleMe)
+	service.server.Handler = chain(router,
+		service.requestID,       // X-Request-ID
+		service.accessLog,       // строка журнала на каждый запрос
+		service.recoverPanic,    // паника -> 500
+		service.rateLimit,       // 429 сверх ограничения частоты запросов, в том числе с неверным токеном
+		service.authenticateJWT, // вход по JWT
+		service.authenticate,    // вход по API-токену
+		service.guardStorage,    // замена хранилища не прерывает запросы
+	)
 	service.server.Addr = addr
-	service.storage = storage
+	service.storage = st
+	service.history = history.NewHistory(defaultMaxRevisions)
+	service.index = search.NewIndex(analysis.Multilingual().Analyze)
+	service.authors = search.NewFuzzyIndex(analysis.Names().Analyze)
+	service.snapshots = make(map[string]openSnapshot)
+	service.idempotencyKeys = idempotency.NewStore(defaultIdempotencyKeysTTL)
+	service.users = auth.NewMemoryUsers(defaultTokenTTL)
+	service.shares = sharing.NewMemoryShares()
+	service.links = sharing.NewMemoryLinks()
+	service.rateLimits = ratelimit.NewRoutes(nil)
+	service.accessLogger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	for _, opt := range opts {
+		opt(service)
+	}
+	service.seedNoteState()
 	return service
 }
 
@@ -56,6 +130,9 @@ func (ns *NotesService) Close() error {
 Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
   {"name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки"}
 
+Владельцем записи становится пользователь, выполняющий запрос.
+Повтор запроса с тем же заголовком Idempotency-Key не создает новых записей, а возвращает исходный ответ (см. idempotent).
+
 Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
   {"result": "OK", "data": {"id": 1}, "error": ""}
 
@@ -65,10 +142,7 @@ func (ns *NotesService) Close() error {
 func (ns *NotesService) handleCreateNote(w http.ResponseWriter, req *http.Request) {
 	setHttpHeaders(w)
 
-	wErr, err := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleCreateNote()")
-	if err != nil {
-		log.Println("(ns *NotesService) handleCreateNote: NewWrappedErrorWithFile()", err)
-	}
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleCreateNote()")
 
 	// Создание ответа
 	resp := &dto.Response{}
@@ -108,14 +182,17 @@ func (ns *NotesService) handleCreateNote(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	// Вставка записи в хранилище
-	id, err := ns.storage.Add(entity.GetPureNote(creatableNote))
+	// Вставка записи в хранилище. Владелец заметки - пользователь, выполняющий запрос.
+	identity, _ := identityFromRequest(req)
+	creatableNote.OwnerID = identity.ID
+	id, err := ns.getStorage().Add(entity.GetPureNote(creatableNote))
 	creatableNote.ID = id
 	if err != nil {
 		resp.Update("ERROR", nil, errors.New("cannot add note: "+err.Error()).Error())
 		wErr.Specify(err, "ns.storage.Add(creatableNote)").LogError()
 		return
 	}
+	ns.noteSaved(id, entity.GetPureNote(creatableNote), requestAuthor(req, creatableNote))
 
 	// Формирование содержимого для ответа
 	idMap := map[string]int64{
@@ -137,8 +214,15 @@ func (ns *NotesService) handleCreateNote(w http.ResponseWriter, req *http.Reques
 Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
   {"id": 1}
 
+Для многоверсионного хранилища запись можно получить в прошлом состоянии
+параметром URL ?as_of=2023-10-01T12:00:00Z или ?snapshot=<токен из /snapshot>.
+Если версии этого состояния уже удалены сборщиком мусора, возвращается ошибка со статусом 410.
+
+Запись другого пользователя доступна администратору и пользователям, которым она открыта (см. handleGrantShare),
+остальным возвращается ошибка со статусом 403.
+
 Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
-  {"result": "OK", "data": {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки"}, "error": ""}
+  {"result": "OK", "data": {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки", "owner_id": 1}, "error": ""}
 
 В случае ошибки:
   {"result": "ERROR", "data": null, "error": "error description"}
@@ -146,10 +230,7 @@ func (ns *NotesService) handleCreateNote(w http.ResponseWriter, req *http.Reques
 func (ns *NotesService) handleGetNote(w http.ResponseWriter, req *http.Request) {
 	setHttpHeaders(w)
 
-	wErr, err := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetNote()")
-	if err != nil {
-		log.Println("(ns *NotesService) handleCreateNote: NewWrappedErrorWithFile()", err)
-	}
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetNote()")
 
 	// Создание ответа
 	resp := &dto.Response{}
@@ -188,8 +269,22 @@ func (ns *NotesService) handleGetNote(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	// Выбор состояния хранилища, из которого производится чтение
+	reader, release, err := ns.readerForRequest(req)
+	if err != nil {
+		resp.UpdateWithStatus(readerStatus(err, http.StatusOK), "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	defer release()
+
 	// Получение нужной записки по ID
-	foundPureNoteAny, status := ns.storage.GetByID(gettableNote.ID)
+	foundPureNoteAny, status := reader.GetByID(gettableNote.ID)
+	if err = snapshotErr(reader); err != nil {
+		resp.UpdateWithStatus(http.StatusGone, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
 	if !status {
 		err = errors.New(fmt.Sprintf("cannot find note with id %d", gettableNote.ID))
 		resp.Update("ERROR", nil, err.Error())
@@ -203,6 +298,12 @@ func (ns *NotesService) handleGetNote(w http.ResponseWriter, req *http.Request)
 		wErr.Specify(err, "foundPureNote, ok := foundPureNoteAny.(PureNote)").LogError()
 		return
 	}
+	if !ns.canAccessNote(req, gettableNote.ID, foundPureNote, accessRead) {
+		err = forbiddenError(accessRead)
+		resp.UpdateWithStatus(http.StatusForbidden, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), gettableNote.ID))
+		return
+	}
 	foundNote := foundPureNote.ToNoteWithID(gettableNote.ID)
 
 	// Формирование содержимого для ответа
@@ -220,6 +321,11 @@ func (ns *NotesService) handleGetNote(w http.ResponseWriter, req *http.Request)
 Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
   {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки"}
 
+Все поля обязательны. Для изменения отдельных полей используется PATCH /notes/{id}.
+С заголовком If-Match: "<ETag заметки>" запись обновляется, только если она не изменилась, иначе возвращается 412.
+Запись другого пользователя может обновить администратор или пользователь с правом "edit", остальным возвращается 403.
+Владелец записи при обновлении не меняется.
+
 Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
   {"result": "OK", "data": null, "error": ""}
 
@@ -229,10 +335,7 @@ func (ns *NotesService) handleGetNote(w http.ResponseWriter, req *http.Request)
 func (ns *NotesService) handleUpdateNote(w http.ResponseWriter, req *http.Request) {
 	setHttpHeaders(w)
 
-	wErr, err := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleUpdateNote()")
-	if err != nil {
-		log.Println("(ns *NotesService) handleUpdateNote: NewWrappedErrorWithFile()", err)
-	}
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleUpdateNote()")
 
 	// Создание ответа
 	resp := &dto.Response{}
@@ -272,19 +375,31 @@ func (ns *NotesService) handleUpdateNote(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	// Обновление записи
-	ok, err := ns.storage.UpdateByID(updatableNote.ID, entity.GetPureNote(updatableNote))
+	// Проверка доступа и условия If-Match, обновление записи
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	foundPureNote, status, err := ns.authorizeNote(req, updatableNote.ID, accessEdit)
+	if err == nil {
+		status, err = ns.checkIfMatch(req, updatableNote.ID)
+	}
+	if err != nil {
+		respondCheckError(resp, wErr, status, err)
+		return
+	}
+	updatableNote.OwnerID = foundPureNote.Owner()
+	ok, err := ns.getStorage().UpdateByID(updatableNote.ID, entity.GetPureNote(updatableNote))
 	if err != nil {
 		resp.Update("ERROR", nil, "internal server error")
 		wErr.Specify(err, "ns.storage.UpdateByID(updatableNote.ID, updatableNote)").LogError()
 		return
 	}
 	if !ok {
-		messageString := fmt.Sprintf("cannot update non-existing note: %s", err.Error())
+		messageString := fmt.Sprintf("cannot update non-existing note: %d", updatableNote.ID)
 		resp.Update("ERROR", nil, messageString)
 		wErr.LogMsg(messageString)
 		return
 	}
+	ns.noteSaved(updatableNote.ID, entity.GetPureNote(updatableNote), requestAuthor(req, updatableNote))
 
 	resp.Update("OK", nil, "")
 	wErr.LogMsg(fmt.Sprintf("OK - update: {id: %d}", updatableNote.ID))
@@ -295,6 +410,9 @@ func (ns *NotesService) handleUpdateNote(w http.ResponseWriter, req *http.Reques
 Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
   {"id": 1}
 
+С заголовком If-Match: "<ETag заметки>" запись удаляется, только если она не изменилась, иначе возвращается 412.
+Запись другого пользователя может удалить только администратор, остальным возвращается 403.
+
 Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
   {"result": "OK", "data": null, "error": ""}
 
@@ -304,10 +422,7 @@ func (ns *NotesService) handleUpdateNote(w http.ResponseWriter, req *http.Reques
 func (ns *NotesService) handleDeleteNoteByID(w http.ResponseWriter, req *http.Request) {
 	setHttpHeaders(w)
 
-	wErr, err := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleDeleteNoteByID()")
-	if err != nil {
-		log.Println("(ns *NotesService) handleDeleteNoteByID: NewWrappedErrorWithFile()", err)
-	}
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleDeleteNoteByID()")
 
 	// Создание ответа
 	resp := &dto.Response{}
@@ -346,17 +461,24 @@ func (ns *NotesService) handleDeleteNoteByID(w http.ResponseWriter, req *http.Re
 		return
 	}
 
-	// Проверка наличия записи с таким ID
-	_, status := ns.storage.GetByID(deletableNote.ID)
-	if !status {
-		messageString := fmt.Sprintf("note with this ID doesn't exist: %d", deletableNote.ID)
-		resp.Update("ERROR", nil, messageString)
-		wErr.LogMsg(messageString)
+	// Проверка наличия записи с таким ID, доступа к ней и условия If-Match
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	_, status, err := ns.authorizeNote(req, deletableNote.ID, accessOwner)
+	if status == http.StatusNotFound {
+		err = fmt.Errorf("note with this ID doesn't exist: %d", deletableNote.ID)
+	}
+	if err == nil {
+		status, err = ns.checkIfMatch(req, deletableNote.ID)
+	}
+	if err != nil {
+		respondCheckError(resp, wErr, status, err)
 		return
 	}
 
 	// Удаление записи
-	ns.storage.RemoveByID(deletableNote.ID)
+	ns.getStorage().RemoveByID(deletableNote.ID)
+	ns.noteDeleted(deletableNote.ID)
 
 	resp.Update("OK", nil, "")
 	wErr.LogMsg(fmt.Sprintf("OK - delete: {id: %d}", deletableNote.ID))
@@ -366,11 +488,27 @@ func (ns *NotesService) handleDeleteNoteByID(w http.ResponseWriter, req *http.Re
 /*
 Запрос должен быть с методом GET. Тело запроса игнорируется.
 
+Для многоверсионного хранилища записи можно получить в прошлом состоянии
+параметром URL ?as_of=2023-10-01T12:00:00Z или ?snapshot=<токен из /snapshot>.
+Если версии этого состояния уже удалены сборщиком мусора, возвращается ошибка со статусом 410.
+
+Возвращаются только записи пользователя, выполняющего запрос; администратору - записи всех пользователей
+(записи одного пользователя отбираются параметром URL ?owner=<ID пользователя>).
+
+По умолчанию записи отсортированы по возрастанию ID. С параметром URL ?order=manual
+записи возвращаются в пользовательском порядке, заданном через /reorder.
+
+Страница, сортировка и фильтры задаются параметрами URL (см. listParams), например:
+  /get-all?limit=20&sort=last_name&order=desc&name=Иван
+Следующая страница запрашивается с параметром ?cursor=<next_cursor из ответа> и теми же sort и order.
+
+Ответ содержит заголовок ETag. Если он совпадает с заголовком запроса If-None-Match, возвращается 304 без тела.
+
 Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
   {"result": "OK", "data": [
   {"id": 1, "name": "Иванов", "last_name": "Иван", "note": "Привет, друг!"},
   {"id": 2, "name": "Петров", "last_name": "Петр", "note": "Привет, друг!"}
-  ], "error": ""}
+  ], "error": "", "total": 5, "next_cursor": "eyJzIjoiaWQiLCJpIjoyfQ"}
 
 В случае ошибки:
   {"result": "ERROR", "data": null, "error": "error description"}
@@ -378,10 +516,7 @@ func (ns *NotesService) handleDeleteNoteByID(w http.ResponseWriter, req *http.Re
 func (ns *NotesService) handleGetAllNotes(w http.ResponseWriter, req *http.Request) {
 	setHttpHeaders(w)
 
-	wErr, err := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetAllNotes()")
-	if err != nil {
-		log.Println("(ns *NotesService) handleGetAllNotes: NewWrappedErrorWithFile()", err)
-	}
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetAllNotes()")
 
 	// Создание ответа
 	resp := &dto.Response{}
@@ -397,32 +532,42 @@ func (ns *NotesService) handleGetAllNotes(w http.ResponseWriter, req *http.Reque
 
 	// Тело запроса игнорируется, поэтому его парсинг не производится
 
-	// Получение всех записей
-	allNotesMap, status := ns.storage.GetAll()
-	if !status {
-		messageString := "no records found"
-		resp.Update("ERROR", nil, messageString)
-		wErr.LogMsg(messageString)
+	// Параметры постраничного вывода, сортировки и фильтрации
+	params, err := parseListParams(req.URL.Query())
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
 		return
 	}
+	params.visible = ns.accessibleNotes(req)
 
-	// Преобразование из map[int64]interface{} в []*dto.Note
-	allNotes := make([]*dto.Note, 0, len(allNotesMap))
-	for id, pureNoteAny := range allNotesMap {
-		pureNote, ok := pureNoteAny.(entity.PureNote)
-		if !ok {
-			err = errors.New("cannot convert interface{} to PureNote")
+	// Выбор состояния хранилища, из которого производится чтение
+	reader, release, err := ns.readerForRequest(req)
+	if err != nil {
+		resp.UpdateWithStatus(readerStatus(err, http.StatusOK), "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	defer release()
+
+	// Получение страницы записей
+	allNotes, total, nextCursor, status, err := ns.listNotes(reader, params)
+	if err != nil {
+		if status == http.StatusInternalServerError {
 			resp.Update("ERROR", nil, "internal server error")
-			wErr.Specify(err, "pureNote, ok := pureNoteAny.(PureNote)").LogError()
+			wErr.Specify(err, "ns.listNotes(reader, params)").LogError()
 			return
 		}
-		allNotes = append(allNotes, pureNote.ToNoteWithID(id))
+		resp.UpdateWithStatus(readerStatus(err, http.StatusOK), "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	if total == 0 {
+		messageString := "no records found"
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
 	}
-
-	// Сортировка []*dto.Note по возрастанию ID
-	sort.Slice(allNotes, func(i, j int) bool {
-		return allNotes[i].ID < allNotes[j].ID
-	})
 
 	// Формирование содержимого ответа в формате JSON
 	allNotesJson, err := json.Marshal(allNotes)
@@ -434,12 +579,38 @@ func (ns *NotesService) handleGetAllNotes(w http.ResponseWriter, req *http.Reque
 	}
 
 	resp.Update("OK", allNotesJson, "")
-	wErr.LogMsg(fmt.Sprintf("OK - get-all: {count: %d}", len(allNotes)))
+	resp.SetPage(int64(total), nextCursor)
+
+	// Проверка условия If-None-Match
+	etag, err := responseETag(resp)
+	if err != nil {
+		resp.Update("ERROR", nil, "internal server error")
+		wErr.Specify(err, "responseETag(resp)").LogError()
+		return
+	}
+	if notModified(w, req, resp, etag) {
+		wErr.LogMsg(fmt.Sprintf("OK - get-all: not modified {etag: %s}", etag))
+		return
+	}
+	wErr.LogMsg(fmt.Sprintf("OK - get-all: {count: %d, total: %d}", len(allNotes), total))
 }
 
 func writeResponseContent(w http.ResponseWriter, resp *dto.Response, wErr *pkg.WrappedError) {
 	defer wErr.Close()
 
+	// При панике в обработчике ответ не отправляется: клиент получит 500 от recoverPanic
+	if recovered := recover(); recovered != nil {
+		panic(recovered)
+	}
+
+	if resp.Status != 0 {
+		w.WriteHeader(resp.Status)
+	}
+	// Ответы 204 и 304 не могут иметь тела
+	if resp.Status == http.StatusNoContent || resp.Status == http.StatusNotModified {
+		return
+	}
+
 	err := json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		wErr.Specify(err, "json.NewEncoder(w).Encode(resp)").LogError()
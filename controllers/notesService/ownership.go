@@ -0,0 +1,135 @@
+package notesService
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"notesServer/gates/sharing"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+)
+
+// noteAccess - уровень доступа пользователя к заметке. Каждый уровень включает предыдущие.
+type noteAccess int
+
+const (
+	accessNone  noteAccess = iota
+	accessRead             // чтение заметки и ее истории (право "read")
+	accessEdit             // изменение заметки (право "edit")
+	accessOwner            // удаление заметки и управление правами доступа к ней (владелец или администратор)
+)
+
+// errForbidden ошибка, возвращаемая при обращении к заметке без необходимого уровня доступа
+var errForbidden = errors.New("access denied")
+
+// forbiddenError возвращает ошибку обращения к заметке без уровня доступа need
+func forbiddenError(need noteAccess) error {
+	switch need {
+	case accessOwner:
+		return fmt.Errorf("%w: only the note owner can do this", errForbidden)
+	case accessEdit:
+		return fmt.Errorf("%w: edit permission for the note is required", errForbidden)
+	default:
+		return fmt.Errorf("%w: note belongs to another user and is not shared with you", errForbidden)
+	}
+}
+
+// WithShares задает права доступа к заметкам. По умолчанию они хранятся только в памяти.
+func WithShares(shares *sharing.Shares) Option {
+	return func(ns *NotesService) {
+		ns.shares = shares
+	}
+}
+
+// accessTo возвращает уровень доступа пользователя запроса к заметке id: владелец и администратор
+// имеют полный доступ, остальные - право, выданное им лично или одной из их групп (см. handleGrantShare).
+// Заметки без владельца, созданные до появления пользователей, доступны только администраторам.
+func (ns *NotesService) accessTo(req *http.Request, id int64, note entity.PureNote) noteAccess {
+	identity, ok := identityFromRequest(req)
+	switch {
+	case !ok:
+		return accessNone
+	case identity.Admin || note.Owner() == identity.ID:
+		return accessOwner
+	}
+	switch ns.shares.Permission(id, identity.ID, identity.Groups) {
+	case sharing.PermissionEdit:
+		return accessEdit
+	case sharing.PermissionRead:
+		return accessRead
+	default:
+		return accessNone
+	}
+}
+
+// canAccessNote проверяет, что пользователь запроса имеет к заметке id уровень доступа не ниже need
+func (ns *NotesService) canAccessNote(req *http.Request, id int64, note entity.PureNote, need noteAccess) bool {
+	return ns.accessTo(req, id, note) >= need
+}
+
+// accessibleNotes возвращает предикат для storage.Storage.Find, отбирающий заметки,
+// которые пользователь запроса может читать
+func (ns *NotesService) accessibleNotes(req *http.Request) func(id int64, v any) bool {
+	return func(id int64, v any) bool {
+		pureNote, ok := v.(entity.PureNote)
+		return ok && ns.canAccessNote(req, id, pureNote, accessRead)
+	}
+}
+
+// authorizeNote возвращает заметку id из хранилища, если пользователь запроса имеет к ней уровень доступа не ниже need.
+// В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту: 404 - заметки нет, 403 - нет доступа.
+func (ns *NotesService) authorizeNote(req *http.Request, id int64, need noteAccess) (entity.PureNote, int, error) {
+	pureNoteAny, ok := ns.getStorage().GetByID(id)
+	if !ok {
+		return entity.PureNote{}, http.StatusNotFound, fmt.Errorf("cannot find note with id %d", id)
+	}
+	pureNote, ok := pureNoteAny.(entity.PureNote)
+	if !ok {
+		return entity.PureNote{}, http.StatusInternalServerError, errors.New("cannot convert interface{} to PureNote")
+	}
+	if !ns.canAccessNote(req, id, pureNote, need) {
+		return entity.PureNote{}, http.StatusForbidden, forbiddenError(need)
+	}
+	return pureNote, http.StatusOK, nil
+}
+
+// authorizeHistory проверяет, что пользователь запроса имеет уровень доступа не ниже need к заметке id
+// для работы с ее историей ревизий. Владелец определяется по последней ревизии, поэтому история удаленной
+// заметки остается закрытой для других. Если истории нет, ошибка не возвращается: об этом сообщает сам обработчик.
+func (ns *NotesService) authorizeHistory(req *http.Request, id int64, need noteAccess) error {
+	revisions, ok := ns.history.List(id)
+	if !ok || len(revisions) == 0 {
+		return nil
+	}
+	if !ns.canAccessNote(req, id, revisions[len(revisions)-1].Note, need) {
+		return forbiddenError(need)
+	}
+	return nil
+}
+
+// requireAdmin возвращает обработчик, пропускающий к handler только запросы администраторов.
+// Остальные пользователи получают ответ со статусом 403.
+func requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		identity, _ := identityFromRequest(req)
+		if !identity.Admin {
+			respondForbidden(w, fmt.Sprintf("access denied: %s requires administrator role", req.URL.Path))
+			return
+		}
+		handler(w, req)
+	}
+}
+
+// respondForbidden отвечает клиенту статусом 403
+func respondForbidden(w http.ResponseWriter, messageString string) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("respondForbidden()")
+
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	resp.UpdateWithStatus(http.StatusForbidden, "ERROR", nil, messageString)
+	wErr.LogMsg(messageString)
+}
@@ -0,0 +1,73 @@
+package notesService
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"notesServer/gates/ratelimit"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"strconv"
+	"time"
+)
+
+// WithRateLimits задает ограничения частоты запросов по маршрутам (см. ratelimit.ParseRouteLimits).
+// По умолчанию запросы не ограничены.
+func WithRateLimits(limits map[string]ratelimit.Limit) Option {
+	return func(ns *NotesService) {
+		ns.rateLimits = ratelimit.NewRoutes(limits)
+	}
+}
+
+// rateLimit ограничивает частоту запросов каждого клиента к маршрутам, для которых задано ограничение
+/*
+Клиент определяется по IP-адресу (заголовок X-Forwarded-For не учитывается).
+Middleware стоит до authenticateJWT и authenticate: запросы с неверным или подобранным токеном, а также
+дорогие проверки JWT тоже ограничиваются, и ответ 401 не выдается сверх ограничения.
+Сверх ограничения клиент получает ответ со статусом 429 и заголовком Retry-After (секунды до следующей попытки):
+  {"result": "ERROR", "data": null, "error": "rate limit exceeded for /create: retry in 1s"}
+
+Состояние лимитеров доступно администратору в /admin/metrics.
+*/
+func (ns *NotesService) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		limiter, route := ns.rateLimits.For(req.URL.Path)
+		if limiter == nil || req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+		allowed, retryAfter := limiter.Allow(rateLimitKey(req))
+		if !allowed {
+			respondTooManyRequests(w, route, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// rateLimitKey возвращает ключ клиента для лимитера - его IP-адрес. Токен ключом не служит:
+// лимитер работает до проверки токена, и случайными токенами ограничение можно было бы обойти.
+func rateLimitKey(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// respondTooManyRequests отвечает клиенту статусом 429 с заголовком Retry-After
+func respondTooManyRequests(w http.ResponseWriter, route string, retryAfter time.Duration) {
+	setHttpHeaders(w)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	wErr := pkg.NewWrappedErrorWithFile("respondTooManyRequests()")
+
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	messageString := fmt.Sprintf("rate limit exceeded for %s: retry in %ds", route, seconds)
+	resp.UpdateWithStatus(http.StatusTooManyRequests, "ERROR", nil, messageString)
+	wErr.LogMsg(messageString)
+}
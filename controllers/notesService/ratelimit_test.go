@@ -0,0 +1,64 @@
+package notesService
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"notesServer/gates/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"),
+		WithRateLimits(map[string]ratelimit.Limit{"/register": {Rate: 1.0 / 60, Burst: 1}}))
+
+	// Неверный запрос отклоняется сразу, поэтому между запросами проходит заметно меньше секунды
+	if recorder, _ := ts.do("", http.MethodPost, "/register", "{"); recorder.Code == http.StatusTooManyRequests {
+		t.Fatalf("first /register: %d", recorder.Code)
+	}
+	recorder, resp := ts.do("", http.MethodPost, "/register", "{")
+	if recorder.Code != http.StatusTooManyRequests || resp.Result != "ERROR" {
+		t.Fatalf("second /register: %d %+v, want 429", recorder.Code, resp)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Fatalf("Retry-After = %q, want 60", retryAfter)
+	}
+
+	// Другой клиент ограничивается отдельно
+	req := httptest.NewRequest(http.MethodPost, "/register", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	other := httptest.NewRecorder()
+	ts.ns.server.Handler.ServeHTTP(other, req)
+	if other.Code == http.StatusTooManyRequests {
+		t.Fatalf("/register of another client: %d", other.Code)
+	}
+}
+
+// Ограничение действует до проверки токена: подбор токенов получает 429, а не бесконечные 401
+func TestRateLimitInvalidTokens(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"),
+		WithRateLimits(map[string]ratelimit.Limit{"*": {Rate: 1.0 / 60, Burst: 3}}))
+	statuses := make([]int, 0, 5)
+	for i := 0; i < 5; i++ {
+		recorder, _ := ts.do("guessed-token-"+itoa(int64(i)), http.MethodGet, "/me", "")
+		statuses = append(statuses, recorder.Code)
+	}
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized,
+		http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses of requests with invalid tokens = %v, want %v", statuses, want)
+		}
+	}
+}
+
+func TestRespondTooManyRequestsRoundsUp(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	respondTooManyRequests(recorder, "/create", 1500*time.Millisecond)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", recorder.Code)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Fatalf("Retry-After = %q, want 2", retryAfter)
+	}
+}
@@ -0,0 +1,152 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"sort"
+)
+
+// sortNotesManually сортирует заметки в пользовательском порядке хранилища.
+// Заметки, отсутствующие в хранилище (например, прочитанные из снимка), остаются в конце в исходном порядке.
+func (ns *NotesService) sortNotesManually(notes []*dto.Note) error {
+	ordered, ok := ns.getStorage().(storage.Ordered)
+	if !ok {
+		return errors.New("storage does not support manual ordering")
+	}
+
+	items, _ := ordered.GetAllOrdered()
+	positions := make(map[int64]int, len(items))
+	for i, item := range items {
+		positions[item.ID] = i
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		iPosition, iOk := positions[notes[i].ID]
+		jPosition, jOk := positions[notes[j].ID]
+		if !iOk || !jOk {
+			return iOk && !jOk
+		}
+		return iPosition < jPosition
+	})
+	return nil
+}
+
+// handleReorderNote обрабатывает запрос на перемещение записи в пользовательском порядке
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON одного из следующих видов:
+  {"id": 1, "before": 3}         - поставить запись 1 перед записью 3
+  {"id": 1, "after": 3}          - поставить запись 1 после записи 3
+  {"id": 1, "position": "front"} - поставить запись 1 в начало ("back" - в конец)
+
+Пользовательский порядок поддерживается только хранилищем-списком.
+Перемещаемая запись и запись, относительно которой она ставится, должны быть доступны пользователю для изменения (иначе 403).
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": null, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleReorderNote(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleReorderNote()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	reorderRequest := dto.NewReorderRequest()
+	err = json.Unmarshal(requestBytes, &reorderRequest)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка валидности полученных данных
+	targets := 0
+	for _, isSet := range []bool{reorderRequest.Before != 0, reorderRequest.After != 0, reorderRequest.Position != ""} {
+		if isSet {
+			targets++
+		}
+	}
+	if reorderRequest.ID < 1 || targets != 1 {
+		err = errors.New("invalid reorder request: need note id and exactly one of 'before', 'after', 'position'")
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d, before: %d, after: %d, position: '%s'}", err.Error(),
+			reorderRequest.ID, reorderRequest.Before, reorderRequest.After, reorderRequest.Position))
+		return
+	}
+
+	// Проверка доступа к перемещаемой записи и к записи, относительно которой она ставится
+	for _, id := range []int64{reorderRequest.ID, reorderRequest.Before, reorderRequest.After} {
+		if id == 0 {
+			continue
+		}
+		_, status, err := ns.authorizeNote(req, id, accessEdit)
+		if status == http.StatusForbidden {
+			resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+			wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), id))
+			return
+		}
+	}
+
+	ordered, ok := ns.getStorage().(storage.Ordered)
+	if !ok {
+		messageString := "storage does not support manual ordering"
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Перемещение записи
+	var moved bool
+	switch {
+	case reorderRequest.Before != 0:
+		moved = ordered.MoveBefore(reorderRequest.ID, reorderRequest.Before)
+	case reorderRequest.After != 0:
+		moved = ordered.MoveAfter(reorderRequest.ID, reorderRequest.After)
+	case reorderRequest.Position == "front":
+		moved = ordered.MoveToFront(reorderRequest.ID)
+	case reorderRequest.Position == "back":
+		moved = ordered.MoveToBack(reorderRequest.ID)
+	default:
+		messageString := fmt.Sprintf("invalid position: '%s' (need 'front' or 'back')", reorderRequest.Position)
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	if !moved {
+		messageString := fmt.Sprintf("cannot reorder note %d: note or anchor note doesn't exist", reorderRequest.ID)
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	resp.Update("OK", nil, "")
+	wErr.LogMsg(fmt.Sprintf("OK - reorder: {id: %d}", reorderRequest.ID))
+}
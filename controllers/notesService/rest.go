@@ -0,0 +1,691 @@
+package notesService
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+	"notesServer/pkg/jsonPatch"
+	"strconv"
+	"strings"
+)
+
+// REST API заметок:
+//
+//	GET    /notes      - список заметок
+//	POST   /notes      - создание заметки
+//	GET    /notes/{id} - получение заметки
+//	PUT    /notes/{id} - замена заметки
+//	PATCH  /notes/{id} - частичное обновление заметки (JSON Merge Patch или JSON Patch)
+//	DELETE /notes/{id} - удаление заметки
+//
+// Каждая заметка принадлежит создавшему ее пользователю. Заметки других пользователей доступны администратору
+// и пользователям, которым владелец выдал право на чтение или изменение:
+//
+//	GET    /notes/{id}/shares                 - список выданных прав
+//	PUT    /notes/{id}/shares/users/{login}   - выдача права пользователю
+//	PUT    /notes/{id}/shares/groups/{group}  - выдача права группе пользователей
+//	DELETE /notes/{id}/shares/users/{login}   - отзыв права пользователя (аналогично для групп)
+//
+// Владелец может выдать подписанную ссылку на чтение заметки без учетной записи:
+//
+//	POST   /notes/{id}/links                  - создание ссылки с ограниченным сроком действия
+//	DELETE /notes/{id}/links                  - отзыв всех ссылок на заметку
+//	GET    /public/notes/{id}?expires=&sig=   - чтение заметки по ссылке (без API-токена)
+//
+// В отличие от маршрутов /create, /get, /update и /delete, ошибки возвращаются с соответствующим HTTP-статусом:
+// 400 - некорректный запрос, 403 - нет доступа к заметке, 404 - заметка не найдена, 405 - метод не поддерживается,
+// 409 - конфликт с состоянием хранилища, 412 - заметка изменилась (If-Match), 415 - неподдерживаемый формат изменения,
+// 422 - изменение нельзя применить к заметке, 500 - внутренняя ошибка сервера.
+
+// routeNotes направляет запрос к /notes обработчику его метода
+func (ns *NotesService) routeNotes(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		ns.handleListNotes(w, req)
+	case http.MethodPost:
+		ns.idempotent(ns.handlePostNote)(w, req)
+	default:
+		handleMethodNotAllowed(w, req, http.MethodGet, http.MethodPost)
+	}
+}
+
+// routeNote направляет запрос к /notes/{id} обработчику его метода, запрос к правам доступа
+// /notes/{id}/shares... - в routeNoteShares, а запрос к публичным ссылкам /notes/{id}/links - в routeNoteLinks
+func (ns *NotesService) routeNote(w http.ResponseWriter, req *http.Request) {
+	if isSharesPath(req.URL.Path) {
+		ns.routeNoteShares(w, req)
+		return
+	}
+	if isLinksPath(req.URL.Path) {
+		ns.routeNoteLinks(w, req)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		ns.handleGetNoteByPath(w, req)
+	case http.MethodPut:
+		ns.handlePutNote(w, req)
+	case http.MethodPatch:
+		ns.handlePatchNote(w, req)
+	case http.MethodDelete:
+		ns.handleDeleteNoteByPath(w, req)
+	default:
+		handleMethodNotAllowed(w, req, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// handleMethodNotAllowed отвечает статусом 405 и списком допустимых методов в заголовке Allow
+func handleMethodNotAllowed(w http.ResponseWriter, req *http.Request, allowed ...string) {
+	setHttpHeaders(w)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+	wErr := pkg.NewWrappedErrorWithFile("handleMethodNotAllowed()")
+
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	messageString := fmt.Sprintf("invalid request method: '%s' (need one of '%s')", req.Method, strings.Join(allowed, "', '"))
+	resp.UpdateWithStatus(http.StatusMethodNotAllowed, "ERROR", nil, messageString)
+	wErr.LogMsg(messageString)
+}
+
+// handleListNotes обрабатывает запрос на получение списка записей
+/*
+Запрос должен быть с методом GET: GET /notes. Тело запроса игнорируется.
+
+Поддерживает те же параметры URL, что и /get-all: ?as_of=, ?snapshot=, параметры страницы, сортировки и фильтров.
+Как и /get-all, возвращает только записи пользователя, выполняющего запрос (администратору - всех пользователей).
+
+Ответ содержит заголовок ETag. Если он совпадает с заголовком запроса If-None-Match, возвращается 304 без тела.
+
+Возвращает клиенту ответ со статусом 200 и с содержимым в формате JSON следующего вида
+(если записей нет, data - пустой массив):
+  {"result": "OK", "data": [{"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки", "owner_id": 1}],
+   "error": "", "total": 1}
+
+В случае ошибки (400, 410, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleListNotes(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleListNotes()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Параметры постраничного вывода, сортировки и фильтрации
+	params, err := parseListParams(req.URL.Query())
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	params.visible = ns.accessibleNotes(req)
+
+	// Выбор состояния хранилища, из которого производится чтение
+	reader, release, err := ns.readerForRequest(req)
+	if err != nil {
+		resp.UpdateWithStatus(readerStatus(err, http.StatusBadRequest), "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	defer release()
+
+	// Получение страницы записей
+	allNotes, total, nextCursor, status, err := ns.listNotes(reader, params)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+			wErr.Specify(err, "ns.listNotes(reader, params)").LogError()
+			return
+		}
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Формирование содержимого ответа в формате JSON
+	allNotesJson, err := json.Marshal(allNotes)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(allNotes)").LogError()
+		return
+	}
+
+	resp.UpdateWithStatus(http.StatusOK, "OK", allNotesJson, "")
+	resp.SetPage(int64(total), nextCursor)
+
+	// Проверка условия If-None-Match
+	etag, err := responseETag(resp)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "responseETag(resp)").LogError()
+		return
+	}
+	if notModified(w, req, resp, etag) {
+		wErr.LogMsg(fmt.Sprintf("OK - GET /notes: not modified {etag: %s}", etag))
+		return
+	}
+	wErr.LogMsg(fmt.Sprintf("OK - GET /notes: {count: %d, total: %d}", len(allNotes), total))
+}
+
+// handlePostNote обрабатывает запрос на создание записи
+/*
+Запрос должен быть с методом POST: POST /notes, с содержимым в формате JSON следующего вида:
+  {"name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки"}
+
+Владельцем записи становится пользователь, выполняющий запрос.
+Повтор запроса с тем же заголовком Idempotency-Key не создает новых записей, а возвращает исходный ответ (см. idempotent).
+
+Возвращает клиенту ответ со статусом 201, заголовком Location: /notes/{id} и содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"id": 1}, "error": ""}
+
+В случае ошибки (400, 409, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handlePostNote(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handlePostNote()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	creatableNote := dto.NewNote()
+	err = json.Unmarshal(requestBytes, &creatableNote)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка наличия необходимых данных в запросе
+	if creatableNote.Name == "" || creatableNote.LastName == "" || creatableNote.Content == "" {
+		err = errors.New("required data is missing")
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {name: '%s', lastName: '%s', note: '%s'}",
+			err.Error(), creatableNote.Name, creatableNote.LastName, creatableNote.Content))
+		return
+	}
+
+	// Вставка записи в хранилище. Владелец заметки - пользователь, выполняющий запрос.
+	identity, _ := identityFromRequest(req)
+	creatableNote.OwnerID = identity.ID
+	id, err := ns.getStorage().Add(entity.GetPureNote(creatableNote))
+	if err != nil {
+		resp.UpdateWithStatus(statusForStorageError(err), "ERROR", nil, "cannot add note: "+err.Error())
+		wErr.Specify(err, "ns.storage.Add(creatableNote)").LogError()
+		return
+	}
+	creatableNote.ID = id
+	ns.noteSaved(id, entity.GetPureNote(creatableNote), requestAuthor(req, creatableNote))
+
+	// Формирование содержимого для ответа
+	idJson, err := json.Marshal(map[string]int64{
+		"id": id,
+	})
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(idMap)").LogError()
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/notes/%d", id))
+	resp.UpdateWithStatus(http.StatusCreated, "OK", idJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - POST /notes: {id: %d}", id))
+}
+
+// handleGetNoteByPath обрабатывает запрос на получение одной записи
+/*
+Запрос должен быть с методом GET: GET /notes/{id}. Тело запроса игнорируется.
+
+Поддерживает те же параметры URL, что и /get: ?as_of= и ?snapshot=.
+
+Ответ содержит заголовок ETag. Если он совпадает с заголовком запроса If-None-Match, возвращается 304 без тела.
+
+Возвращает клиенту ответ со статусом 200 и с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки", "owner_id": 1}, "error": ""}
+
+В случае ошибки (400, 403, 404, 410, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleGetNoteByPath(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetNoteByPath()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Получение ID из пути
+	id, status, err := noteIDFromPath(req.URL.Path)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Выбор состояния хранилища, из которого производится чтение
+	reader, release, err := ns.readerForRequest(req)
+	if err != nil {
+		resp.UpdateWithStatus(readerStatus(err, http.StatusBadRequest), "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	defer release()
+
+	// Получение нужной записки по ID
+	foundPureNoteAny, ok := reader.GetByID(id)
+	if err = snapshotErr(reader); err != nil {
+		resp.UpdateWithStatus(http.StatusGone, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	if !ok {
+		messageString := fmt.Sprintf("cannot find note with id %d", id)
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	foundPureNote, ok := foundPureNoteAny.(entity.PureNote)
+	if !ok {
+		err = errors.New("cannot convert interface{} to PureNote")
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "foundPureNote, ok := foundPureNoteAny.(PureNote)").LogError()
+		return
+	}
+	if !ns.canAccessNote(req, id, foundPureNote, accessRead) {
+		err = forbiddenError(accessRead)
+		resp.UpdateWithStatus(http.StatusForbidden, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), id))
+		return
+	}
+
+	// Формирование содержимого для ответа
+	noteJson, err := json.Marshal(foundPureNote.ToNoteWithID(id))
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(foundNote)").LogError()
+		return
+	}
+	if notModified(w, req, resp, etagOf(noteJson)) {
+		wErr.LogMsg(fmt.Sprintf("OK - GET /notes/%d: not modified", id))
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", noteJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - GET /notes/%d", id))
+}
+
+// handlePutNote обрабатывает запрос на замену записи
+/*
+Запрос должен быть с методом PUT: PUT /notes/{id}, с содержимым в формате JSON следующего вида:
+  {"name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки"}
+
+Все поля обязательны. Поля "id" и "owner_id" можно не указывать, но если они указаны,
+они должны совпадать с ID из пути и владельцем записи: сменить владельца нельзя.
+С заголовком If-Match: "<ETag заметки>" запись заменяется, только если она не изменилась, иначе возвращается 412.
+
+Возвращает клиенту ответ со статусом 200, заголовком ETag новой версии и обновленной записью:
+  {"result": "OK", "data": {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Содержимое заметки", "owner_id": 1}, "error": ""}
+
+В случае ошибки (400, 403, 404, 409, 412, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handlePutNote(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handlePutNote()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Получение ID из пути
+	id, status, err := noteIDFromPath(req.URL.Path)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	updatableNote := dto.NewNote()
+	updatableNote.ID = id
+	err = json.Unmarshal(requestBytes, &updatableNote)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка наличия необходимых данных в запросе
+	if updatableNote.ID != id {
+		messageString := fmt.Sprintf("note id in body (%d) does not match id in path (%d)", updatableNote.ID, id)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	if updatableNote.Name == "" || updatableNote.LastName == "" || updatableNote.Content == "" {
+		err = errors.New("required data is missing")
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {name: '%s', lastName: '%s', note: '%s'}",
+			err.Error(), updatableNote.Name, updatableNote.LastName, updatableNote.Content))
+		return
+	}
+
+	ns.replaceNote(w, req, resp, wErr, updatableNote, "PUT")
+}
+
+// handlePatchNote обрабатывает запрос на частичное обновление записи
+/*
+Запрос должен быть с методом PATCH: PATCH /notes/{id}. Формат изменения определяется заголовком Content-Type:
+
+application/merge-patch+json или application/json - JSON Merge Patch (RFC 7396), только изменяемые поля:
+  {"note": "Новое содержимое заметки"}
+
+application/json-patch+json - JSON Patch (RFC 6902), массив операций над заметкой:
+  [{"op": "test", "path": "/note", "value": "Старое содержимое"}, {"op": "replace", "path": "/note", "value": "Новое"}]
+
+Изменения применяются к сохраненной заметке, после чего результат проверяется так же, как при PUT:
+поля name, last_name и note не могут быть пустыми, id и owner_id изменять нельзя, других полей быть не должно.
+С заголовком If-Match: "<ETag заметки>" запись обновляется, только если она не изменилась, иначе возвращается 412.
+
+Возвращает клиенту ответ со статусом 200, заголовком ETag новой версии и обновленной записью:
+  {"result": "OK", "data": {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Новое содержимое заметки"}, "error": ""}
+
+В случае ошибки (400, 403, 404, 409 - не выполнена операция test, 412, 415, 422 - путь не существует, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handlePatchNote(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handlePatchNote()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Получение ID из пути
+	id, status, err := noteIDFromPath(req.URL.Path)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+
+	// Запись читается, изменяется и сохраняется под ns.writeMu: иначе изменение, сохраненное другим запросом
+	// между чтением и записью, было бы потеряно, а операция test проверяла бы устаревшее состояние
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+
+	// Получение текущей записи, поверх которой применяются переданные поля.
+	// Доступ и условие If-Match проверяются до применения изменений, чтобы операция test
+	// не раскрывала содержимое чужой заметки.
+	foundPureNote, status, err := ns.authorizeNote(req, id, accessEdit)
+	if err == nil {
+		status, err = ns.checkIfMatch(req, id)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.checkIfMatch(req, id)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	updatableNote, status, err := patchNote(foundPureNote.ToNoteWithID(id), req.Header.Get("Content-Type"), requestBytes)
+	if status == http.StatusUnsupportedMediaType {
+		w.Header().Set("Accept-Patch", jsonPatch.MergePatchContentType+", "+jsonPatch.JSONPatchContentType)
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Проверка данных после применения изменений
+	if updatableNote.ID != id {
+		messageString := fmt.Sprintf("note id in patch (%d) does not match id in path (%d)", updatableNote.ID, id)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	if updatableNote.Name == "" || updatableNote.LastName == "" || updatableNote.Content == "" {
+		err = errors.New("required data cannot be empty")
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {name: '%s', lastName: '%s', note: '%s'}",
+			err.Error(), updatableNote.Name, updatableNote.LastName, updatableNote.Content))
+		return
+	}
+
+	ns.replaceNoteUnsafely(w, req, resp, wErr, updatableNote, "PATCH")
+}
+
+// replaceNote записывает новое значение заметки в хранилище, если пользователь запроса может ее изменять
+// и выполнено условие If-Match, и формирует ответ PUT и PATCH запросов
+func (ns *NotesService) replaceNote(w http.ResponseWriter, req *http.Request, resp *dto.Response, wErr *pkg.WrappedError,
+	note *dto.Note, method string) {
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+
+	ns.replaceNoteUnsafely(w, req, resp, wErr, note, method)
+}
+
+// replaceNoteUnsafely - replaceNote для обработчиков, которые уже удерживают ns.writeMu
+// (например, потому что новое значение заметки вычислено из прочитанного под той же блокировкой)
+func (ns *NotesService) replaceNoteUnsafely(w http.ResponseWriter, req *http.Request, resp *dto.Response, wErr *pkg.WrappedError,
+	note *dto.Note, method string) {
+	// Проверка доступа и условия If-Match, обновление записи
+	foundPureNote, status, err := ns.authorizeNote(req, note.ID, accessEdit)
+	if err == nil {
+		status, err = ns.checkIfMatch(req, note.ID)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.checkIfMatch(req, note.ID)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	if note.OwnerID != 0 && note.OwnerID != foundPureNote.Owner() {
+		messageString := fmt.Sprintf("note owner cannot be changed (owner_id %d, need %d)", note.OwnerID, foundPureNote.Owner())
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	note.OwnerID = foundPureNote.Owner()
+	ok, err := ns.getStorage().UpdateByID(note.ID, entity.GetPureNote(note))
+	if err != nil {
+		resp.UpdateWithStatus(statusForStorageError(err), "ERROR", nil, "cannot update note: "+err.Error())
+		wErr.Specify(err, "ns.storage.UpdateByID(note.ID, note)").LogError()
+		return
+	}
+	if !ok {
+		messageString := fmt.Sprintf("cannot update non-existing note: %d", note.ID)
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	ns.noteSaved(note.ID, entity.GetPureNote(note), requestAuthor(req, note))
+
+	// Формирование содержимого для ответа
+	noteJson, err := json.Marshal(note)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(note)").LogError()
+		return
+	}
+	w.Header().Set("ETag", etagOf(noteJson))
+	resp.UpdateWithStatus(http.StatusOK, "OK", noteJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - %s /notes/%d", method, note.ID))
+}
+
+// handleDeleteNoteByPath обрабатывает запрос на удаление записи
+/*
+Запрос должен быть с методом DELETE: DELETE /notes/{id}. Тело запроса игнорируется.
+С заголовком If-Match: "<ETag заметки>" запись удаляется, только если она не изменилась, иначе возвращается 412.
+
+Возвращает клиенту ответ со статусом 204 без содержимого.
+
+В случае ошибки (400, 403, 404, 412, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleDeleteNoteByPath(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleDeleteNoteByPath()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Получение ID из пути
+	id, status, err := noteIDFromPath(req.URL.Path)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Проверка доступа и условия If-Match, удаление записи
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	_, status, err = ns.authorizeNote(req, id, accessOwner)
+	if err == nil {
+		status, err = ns.checkIfMatch(req, id)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.checkIfMatch(req, id)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	results := ns.getStorage().RemoveMany([]int64{id})
+	if !results[0].OK {
+		messageString := fmt.Sprintf("note with this ID doesn't exist: %d", id)
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	ns.noteDeleted(id)
+
+	resp.UpdateWithStatus(http.StatusNoContent, "OK", nil, "")
+	wErr.LogMsg(fmt.Sprintf("OK - DELETE /notes/%d", id))
+}
+
+// patchNote применяет к заметке note изменение patch в формате, заданном типом содержимого contentType,
+// и возвращает измененную заметку. В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту.
+func patchNote(note *dto.Note, contentType string, patch []byte) (*dto.Note, int, error) {
+	noteJson, err := json.Marshal(note)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// Применение изменения
+	mediaType := ""
+	if contentType != "" {
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("invalid Content-Type: %q", contentType)
+		}
+	}
+	var patchedJson []byte
+	switch mediaType {
+	case "", "application/json", jsonPatch.MergePatchContentType:
+		patchedJson, err = jsonPatch.Merge(noteJson, patch)
+	case jsonPatch.JSONPatchContentType:
+		patchedJson, err = jsonPatch.Apply(noteJson, patch)
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported Content-Type: %q (need %s or %s)",
+			mediaType, jsonPatch.MergePatchContentType, jsonPatch.JSONPatchContentType)
+	}
+	switch {
+	case errors.Is(err, jsonPatch.ErrTestFailed):
+		return nil, http.StatusConflict, err
+	case errors.Is(err, jsonPatch.ErrUnprocessable):
+		return nil, http.StatusUnprocessableEntity, err
+	case err != nil:
+		return nil, http.StatusBadRequest, err
+	}
+
+	// Измененный документ должен оставаться заметкой
+	patchedNote := dto.NewNote()
+	decoder := json.NewDecoder(bytes.NewReader(patchedJson))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(patchedNote)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("patched note is invalid: %s", err)
+	}
+	return patchedNote, http.StatusOK, nil
+}
+
+// noteIDFromPath возвращает ID заметки из пути вида /notes/{id}.
+// В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту.
+func noteIDFromPath(path string) (id int64, status int, err error) {
+	idString := strings.TrimPrefix(path, "/notes/")
+	if idString == "" || strings.Contains(idString, "/") {
+		return 0, http.StatusNotFound, fmt.Errorf("resource not found: %s", path)
+	}
+	id, err = strconv.ParseInt(idString, 10, 64)
+	if err != nil || id < 1 {
+		return 0, http.StatusBadRequest, fmt.Errorf("invalid note id: %s", idString)
+	}
+	return id, http.StatusOK, nil
+}
+
+// statusForStorageError возвращает HTTP-статус для ошибки, полученной от хранилища
+func statusForStorageError(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrMismatchType), errors.Is(err, storage.ErrIDExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,74 @@
+package notesService
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// patchJSONPatch выполняет PATCH /notes/{id} с изменением в формате JSON Patch
+func (ts *testService) patchJSONPatch(token string, id int64, patch string, headers ...string) int {
+	ts.t.Helper()
+	headers = append(headers, "Content-Type: application/json-patch+json")
+	recorder, _ := ts.do(token, http.MethodPatch, "/notes/"+itoa(id), patch, headers...)
+	return recorder.Code
+}
+
+// TestPatchNoteCompareAndSwap проверяет, что операция test в JSON Patch видит последнее сохраненное состояние:
+// параллельные запросы "если note == n, то note = n+1" не должны пройти проверку на одном и том же n
+func TestPatchNoteCompareAndSwap(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	token := ts.register("alice")
+	id := ts.createNote(token, "0")
+
+	const workers, increments = 4, 25
+	succeeded := atomic.Int64{}
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for done := 0; done < increments; {
+				note, _ := ts.getNote(token, id)
+				n, _ := strconv.Atoi(note.Content)
+				status := ts.patchJSONPatch(token, id, `[{"op": "test", "path": "/note", "value": "`+strconv.Itoa(n)+`"},`+
+					`{"op": "replace", "path": "/note", "value": "`+strconv.Itoa(n+1)+`"}]`)
+				if status == http.StatusOK {
+					succeeded.Add(1)
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	note, _ := ts.getNote(token, id)
+	if want := strconv.FormatInt(succeeded.Load(), 10); note.Content != want {
+		t.Fatalf("note = %q after %s successful increments: updates were lost", note.Content, want)
+	}
+}
+
+func TestPatchNoteIfMatch(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	token := ts.register("alice")
+	id := ts.createNote(token, "исходная")
+	recorder, _ := ts.do(token, http.MethodGet, "/notes/"+itoa(id), "")
+	etag := recorder.Header().Get("ETag")
+
+	status := ts.patchJSONPatch(token, id, `[{"op": "replace", "path": "/note", "value": "первая"}]`, "If-Match: "+etag)
+	if status != http.StatusOK {
+		t.Fatalf("PATCH with current ETag = %d", status)
+	}
+
+	// ETag устарел: изменение не применяется, даже если операция test прошла бы
+	status = ts.patchJSONPatch(token, id, `[{"op": "test", "path": "/note", "value": "первая"},`+
+		`{"op": "replace", "path": "/note", "value": "вторая"}]`, "If-Match: "+etag)
+	if status != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH with stale ETag = %d, want 412", status)
+	}
+	if note, _ := ts.getNote(token, id); note.Content != "первая" {
+		t.Fatalf("note = %q after rejected PATCH", note.Content)
+	}
+}
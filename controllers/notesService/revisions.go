@@ -0,0 +1,426 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/history"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+)
+
+// defaultMaxRevisions - количество последних ревизий каждой заметки, которые хранятся по умолчанию
+const defaultMaxRevisions = 100
+
+// WithMaxRevisions задает количество последних ревизий каждой заметки, которые хранятся в истории.
+// Более старые ревизии удаляются: получить их, сравнить с ними или откатиться к ним нельзя.
+func WithMaxRevisions(maxRevisions int) Option {
+	return func(ns *NotesService) {
+		ns.history = history.NewHistory(maxRevisions)
+	}
+}
+
+// noteAuthor возвращает автора изменения заметки по ее имени и фамилии (для заметок, загруженных при запуске)
+func noteAuthor(note *dto.Note) string {
+	return note.Name + " " + note.LastName
+}
+
+// requestAuthor возвращает автора изменения заметки, выполняемого запросом: логин пользователя
+func requestAuthor(req *http.Request, note *dto.Note) string {
+	identity, ok := identityFromRequest(req)
+	if !ok {
+		return noteAuthor(note)
+	}
+	return identity.Login
+}
+
+// handleGetRevisions обрабатывает запрос на получение списка ревизий записи
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"id": 1}
+
+История записи другого пользователя доступна тем же пользователям, что и сама запись (см. handleGetNote),
+остальным возвращается ошибка со статусом 403. Хранятся только последние ревизии записи (см. WithMaxRevisions).
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": [
+  {"id": 1, "revision": 1, "timestamp": "2023-10-01T12:00:00Z", "author": "Имя Фамилия", "name": "Имя", "last_name": "Фамилия", "note": "Первая версия"},
+  {"id": 1, "revision": 2, "timestamp": "2023-10-01T12:05:00Z", "author": "Имя Фамилия", "name": "Имя", "last_name": "Фамилия", "note": "Вторая версия"}
+  ], "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleGetRevisions(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetRevisions()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	revisionRequest := dto.NewRevisionRequest()
+	err = json.Unmarshal(requestBytes, &revisionRequest)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка валидности полученного ID
+	if revisionRequest.ID < 1 {
+		err = errors.New("invalid note id")
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s %d", err.Error(), revisionRequest.ID))
+		return
+	}
+
+	// Проверка доступа к заметке
+	err = ns.authorizeHistory(req, revisionRequest.ID, accessRead)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusForbidden, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), revisionRequest.ID))
+		return
+	}
+
+	// Получение ревизий
+	revisions, status := ns.history.List(revisionRequest.ID)
+	if !status {
+		err = errors.New(fmt.Sprintf("cannot find revisions of note with id %d", revisionRequest.ID))
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	revisionsDto := make([]*dto.Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		revisionsDto = append(revisionsDto, revision.ToRevisionWithID(revisionRequest.ID))
+	}
+
+	// Формирование содержимого для ответа
+	revisionsJson, err := json.Marshal(revisionsDto)
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(revisionsDto)").LogError()
+		return
+	}
+	resp.Update("OK", revisionsJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - revisions: {id: %d, count: %d}", revisionRequest.ID, len(revisionsDto)))
+}
+
+// handleGetRevision обрабатывает запрос на получение одной ревизии записи
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"id": 1, "revision": 2}
+
+История записи другого пользователя доступна тем же пользователям, что и сама запись (см. handleGetNote),
+остальным возвращается ошибка со статусом 403.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"id": 1, "revision": 2, "timestamp": "2023-10-01T12:05:00Z", "author": "Имя Фамилия", "name": "Имя", "last_name": "Фамилия", "note": "Вторая версия"}, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleGetRevision(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGetRevision()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	revisionRequest := dto.NewRevisionRequest()
+	err = json.Unmarshal(requestBytes, &revisionRequest)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка валидности полученных данных
+	if revisionRequest.ID < 1 || revisionRequest.Revision < 1 {
+		err = errors.New("invalid note id or revision")
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d, revision: %d}", err.Error(), revisionRequest.ID, revisionRequest.Revision))
+		return
+	}
+
+	// Проверка доступа к заметке
+	err = ns.authorizeHistory(req, revisionRequest.ID, accessRead)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusForbidden, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), revisionRequest.ID))
+		return
+	}
+
+	// Получение ревизии
+	revision, status := ns.history.Get(revisionRequest.ID, revisionRequest.Revision)
+	if !status {
+		err = errors.New(fmt.Sprintf("cannot find revision %d of note with id %d", revisionRequest.Revision, revisionRequest.ID))
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Формирование содержимого для ответа
+	revisionJson, err := json.Marshal(revision.ToRevisionWithID(revisionRequest.ID))
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(revision)").LogError()
+		return
+	}
+	resp.Update("OK", revisionJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - revision: {id: %d, revision: %d}", revisionRequest.ID, revisionRequest.Revision))
+}
+
+// handleDiffRevisions обрабатывает запрос на построчное сравнение содержимого двух ревизий записи
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"id": 1, "from": 1, "to": 2}
+
+История записи другого пользователя доступна тем же пользователям, что и сама запись (см. handleGetNote),
+остальным возвращается ошибка со статусом 403.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"id": 1, "from": 1, "to": 2, "lines": [
+  {"op": "=", "line": "Без изменений"},
+  {"op": "-", "line": "Удаленная строка"},
+  {"op": "+", "line": "Добавленная строка"}
+  ]}, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleDiffRevisions(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleDiffRevisions()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	diffRequest := dto.NewRevisionRequest()
+	err = json.Unmarshal(requestBytes, &diffRequest)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка валидности полученных данных
+	if diffRequest.ID < 1 || diffRequest.From < 1 || diffRequest.To < 1 {
+		err = errors.New("invalid note id or revisions")
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d, from: %d, to: %d}", err.Error(), diffRequest.ID, diffRequest.From, diffRequest.To))
+		return
+	}
+
+	// Проверка доступа к заметке
+	err = ns.authorizeHistory(req, diffRequest.ID, accessRead)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusForbidden, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d}", err.Error(), diffRequest.ID))
+		return
+	}
+
+	// Получение сравниваемых ревизий
+	fromRevision, status := ns.history.Get(diffRequest.ID, diffRequest.From)
+	if !status {
+		err = errors.New(fmt.Sprintf("cannot find revision %d of note with id %d", diffRequest.From, diffRequest.ID))
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	toRevision, status := ns.history.Get(diffRequest.ID, diffRequest.To)
+	if !status {
+		err = errors.New(fmt.Sprintf("cannot find revision %d of note with id %d", diffRequest.To, diffRequest.ID))
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Построчное сравнение содержимого
+	fromNote := fromRevision.Note.ToNoteWithID(diffRequest.ID)
+	toNote := toRevision.Note.ToNoteWithID(diffRequest.ID)
+	diff := &dto.Diff{ID: diffRequest.ID, From: diffRequest.From, To: diffRequest.To}
+	for _, op := range pkg.LineDiff(fromNote.Content, toNote.Content) {
+		diff.Lines = append(diff.Lines, &dto.DiffLine{Op: op.Op, Line: op.Line})
+	}
+
+	// Формирование содержимого для ответа
+	diffJson, err := json.Marshal(diff)
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(diff)").LogError()
+		return
+	}
+	resp.Update("OK", diffJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - diff: {id: %d, from: %d, to: %d}", diffRequest.ID, diffRequest.From, diffRequest.To))
+}
+
+// handleRevertNote обрабатывает запрос на откат записи к одной из ее ревизий
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"id": 1, "revision": 1}
+
+Откат не удаляет историю, а создает новую ревизию с содержимым указанной. Автор новой ревизии - пользователь,
+выполняющий запрос. Откатить запись другого пользователя может администратор или пользователь с правом "edit",
+остальным возвращается 403.
+
+С заголовком If-Match: "<ETag заметки>" запись откатывается, только если она не изменилась, иначе возвращается 412.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"id": 1, "revision": 3}, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleRevertNote(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleRevertNote()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	revertRequest := dto.NewRevisionRequest()
+	err = json.Unmarshal(requestBytes, &revertRequest)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка валидности полученных данных
+	if revertRequest.ID < 1 || revertRequest.Revision < 1 {
+		err = errors.New("invalid note id or revision")
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {id: %d, revision: %d}", err.Error(), revertRequest.ID, revertRequest.Revision))
+		return
+	}
+
+	// Проверка доступа и условия If-Match, откат записи. Как и в /update, блокировка удерживается
+	// от проверки до записи, иначе откат мог бы перезаписать изменение, сделанное после проверки.
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	_, status, err := ns.authorizeNote(req, revertRequest.ID, accessEdit)
+	if err == nil {
+		status, err = ns.checkIfMatch(req, revertRequest.ID)
+	}
+	if err != nil {
+		respondCheckError(resp, wErr, status, err)
+		return
+	}
+
+	// Получение ревизии, к которой производится откат
+	revision, ok := ns.history.Get(revertRequest.ID, revertRequest.Revision)
+	if !ok {
+		err = errors.New(fmt.Sprintf("cannot find revision %d of note with id %d", revertRequest.Revision, revertRequest.ID))
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Обновление записи
+	ok, err = ns.getStorage().UpdateByID(revertRequest.ID, revision.Note)
+	if err != nil {
+		resp.Update("ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.storage.UpdateByID(revertRequest.ID, revision.Note)").LogError()
+		return
+	}
+	if !ok {
+		messageString := fmt.Sprintf("cannot revert non-existing note: %d", revertRequest.ID)
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	newRevision := ns.noteSaved(revertRequest.ID, revision.Note, requestAuthor(req, revision.Note.ToNoteWithID(revertRequest.ID)))
+
+	// Формирование содержимого для ответа
+	revisionJson, err := json.Marshal(map[string]int64{
+		"id":       revertRequest.ID,
+		"revision": newRevision,
+	})
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(revisionMap)").LogError()
+		return
+	}
+	resp.Update("OK", revisionJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - revert: {id: %d, revision: %d, new revision: %d}",
+		revertRequest.ID, revertRequest.Revision, newRevision))
+}
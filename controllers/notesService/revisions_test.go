@@ -0,0 +1,49 @@
+package notesService
+
+import (
+	"net/http"
+	"testing"
+)
+
+// revert откатывает заметку id к ревизии revision через /revert и возвращает статус и ответ
+func (ts *testService) revert(token string, id int64, revision int64, headers ...string) (int, string) {
+	ts.t.Helper()
+	recorder, resp := ts.do(token, http.MethodPost, "/revert", `{"id": `+itoa(id)+`, "revision": `+itoa(revision)+`}`, headers...)
+	return recorder.Code, resp.Result
+}
+
+func TestRevertNoteIfMatch(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	token := ts.register("alice")
+	id := ts.createNote(token, "первая")
+	recorder, _ := ts.do(token, http.MethodGet, "/notes/"+itoa(id), "")
+	etag := recorder.Header().Get("ETag")
+	ts.do(token, http.MethodPut, "/notes/"+itoa(id), `{"name": "Иван", "last_name": "Иванов", "note": "вторая"}`)
+
+	// Заметка изменилась после получения ETag: откат не применяется
+	if status, result := ts.revert(token, id, 1, "If-Match: "+etag); status != http.StatusPreconditionFailed || result != "ERROR" {
+		t.Fatalf("/revert with stale ETag = %d %s, want 412", status, result)
+	}
+	if note, _ := ts.getNote(token, id); note.Content != "вторая" {
+		t.Fatalf("note = %q after rejected revert", note.Content)
+	}
+
+	recorder, _ = ts.do(token, http.MethodGet, "/notes/"+itoa(id), "")
+	if status, result := ts.revert(token, id, 1, "If-Match: "+recorder.Header().Get("ETag")); status != http.StatusOK || result != "OK" {
+		t.Fatalf("/revert with current ETag = %d %s", status, result)
+	}
+	if note, _ := ts.getNote(token, id); note.Content != "первая" {
+		t.Fatalf("note = %q after revert to revision 1", note.Content)
+	}
+}
+
+func TestRevertNoteOfAnotherUser(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "map://?init_id=1"))
+	alice := ts.register("alice")
+	bob := ts.register("bob")
+	id := ts.createNote(alice, "заметка")
+
+	if status, _ := ts.revert(bob, id, 1); status != http.StatusForbidden {
+		t.Fatalf("/revert of another user's note = %d, want 403", status)
+	}
+}
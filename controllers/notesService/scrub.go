@@ -0,0 +1,92 @@
+package notesService
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+)
+
+// handleScrubStorage обрабатывает запрос на проверку целостности записей хранилища
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида (тело может быть пустым):
+  {"quarantine": true}
+
+Если quarantine == true, поврежденные записи, которые нельзя восстановить, и записи, которых нет в памяти,
+переносятся в карантин. Доступен только администраторам (см. requireAdmin).
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"checked": 10, "corrupt": [3, 7], "repaired": [3], "quarantined": [7, 9],
+   "missing_on_disk": [11], "missing_in_memory": [9]}, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleScrubStorage(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleScrubStorage()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	scrubRequest := struct {
+		Quarantine bool `json:"quarantine"`
+	}{}
+	if len(requestBytes) != 0 {
+		err = json.Unmarshal(requestBytes, &scrubRequest)
+		if err != nil {
+			messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+			resp.Update("ERROR", nil, messageString)
+			wErr.LogMsg(messageString)
+			return
+		}
+	}
+
+	scrubber, ok := ns.getStorage().(storage.Scrubber)
+	if !ok {
+		messageString := "storage does not support integrity checks"
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Проверка хранилища
+	report, err := scrubber.Scrub(scrubRequest.Quarantine)
+	if err != nil {
+		resp.Update("ERROR", nil, "cannot scrub storage: "+err.Error())
+		wErr.Specify(err, "scrubber.Scrub(scrubRequest.Quarantine)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	reportJson, err := json.Marshal(report)
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(report)").LogError()
+		return
+	}
+	resp.Update("OK", reportJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - scrub: {checked: %d, corrupt: %v, quarantined: %v, missing on disk: %v, missing in memory: %v}",
+		report.Checked, report.Corrupt, report.Quarantined, report.MissingOnDisk, report.MissingInMemory))
+}
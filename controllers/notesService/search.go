@@ -0,0 +1,132 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"notesServer/gates/search"
+	"notesServer/models/dto"
+	"notesServer/models/entity"
+	"notesServer/pkg"
+	"strconv"
+)
+
+// Размер страницы результатов поиска
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// noteSearchFields возвращает индексируемые поля заметки. Имена полей совпадают с полями JSON заметки.
+func noteSearchFields(note entity.PureNote) map[string]string {
+	return map[string]string{
+		"name":      note.Name(),
+		"last_name": note.LastName(),
+		"note":      note.Content(),
+	}
+}
+
+// handleSearchNotes обрабатывает запрос на полнотекстовый поиск записей
+/*
+Запрос должен быть с методом GET: GET /search?q=запрос&limit=20. Тело запроса игнорируется.
+
+Запрос ищется по содержимому, имени и фамилии автора без учета регистра, различия ё/е
+и окончаний слов (например, "заметки" находит "заметка"):
+  привет друг          - записи со всеми словами
+  привет OR здравствуй - записи с любым из слов (AND связывает сильнее, чем OR)
+  "привет друг"        - записи, в которых слова идут подряд
+
+Ищутся только записи, доступные пользователю, выполняющему запрос (см. accessTo).
+
+Возвращает клиенту ответ со статусом 200 и записями по убыванию релевантности (BM25):
+  {"result": "OK", "data": [
+  {"id": 1, "name": "Имя", "last_name": "Фамилия", "note": "Привет, друг!", "score": 1.38,
+   "snippets": {"note": "<mark>Привет</mark>, <mark>друг</mark>!"}}
+  ], "error": "", "total": 1}
+
+В случае ошибки (400, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleSearchNotes(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleSearchNotes()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodGet)
+		resp.UpdateWithStatus(http.StatusMethodNotAllowed, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Парсинг параметров запроса
+	queryText := req.URL.Query().Get("q")
+	limit := defaultSearchLimit
+	var err error
+	if req.URL.Query().Has("limit") {
+		limit, err = strconv.Atoi(req.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			messageString := fmt.Sprintf("invalid 'limit': need an integer from 1 to %d", maxSearchLimit)
+			resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+			wErr.LogMsg(messageString)
+			return
+		}
+	}
+
+	// Поиск
+	accessible := ns.accessibleNotes(req)
+	hits, total, err := ns.index.Search(queryText, limit, func(id int64) bool {
+		pureNoteAny, ok := ns.getStorage().GetByID(id)
+		return ok && accessible(id, pureNoteAny)
+	})
+	if errors.Is(err, search.ErrInvalidQuery) {
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), queryText))
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.index.Search(queryText, limit, accessible)").LogError()
+		return
+	}
+
+	// Получение найденных записей
+	results := make([]*dto.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		pureNoteAny, ok := ns.getStorage().GetByID(hit.ID)
+		if !ok {
+			continue // запись удалена после поиска
+		}
+		pureNote, ok := pureNoteAny.(entity.PureNote)
+		if !ok {
+			err = errors.New("cannot convert interface{} to PureNote")
+			resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+			wErr.Specify(err, "pureNote, ok := pureNoteAny.(PureNote)").LogError()
+			return
+		}
+		results = append(results, &dto.SearchResult{
+			Note:     *pureNote.ToNoteWithID(hit.ID),
+			Score:    hit.Score,
+			Snippets: hit.Snippets,
+		})
+	}
+
+	// Формирование содержимого ответа в формате JSON
+	resultsJson, err := json.Marshal(results)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(results)").LogError()
+		return
+	}
+
+	resp.UpdateWithStatus(http.StatusOK, "OK", resultsJson, "")
+	resp.SetPage(int64(total), "")
+	wErr.LogMsg(fmt.Sprintf("OK - search: {q: %q, count: %d, total: %d}", queryText, len(results), total))
+}
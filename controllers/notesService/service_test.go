@@ -0,0 +1,125 @@
+package notesService
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"notesServer/gates/storage"
+	"notesServer/models/dto"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "notesServer/gates/storage/list"
+	_ "notesServer/gates/storage/mp"
+	_ "notesServer/gates/storage/mvcc"
+)
+
+// testPassword - пароль пользователей, регистрируемых в тестах
+const testPassword = "correct horse battery"
+
+// testService - сервис заметок для тестов обработчиков: запросы выполняются без сети через server.Handler
+type testService struct {
+	t  *testing.T
+	ns *NotesService
+}
+
+// newTestService возвращает сервис заметок над хранилищем st без журнала запросов
+func newTestService(t *testing.T, st storage.Storage, opts ...Option) *testService {
+	t.Helper()
+	opts = append([]Option{WithAccessLog(io.Discard)}, opts...)
+	return &testService{t: t, ns: NewNotesService("", st, opts...)}
+}
+
+// openStorage открывает хранилище по строке подключения
+func openStorage(t *testing.T, dsn string) storage.Storage {
+	t.Helper()
+	st, err := storage.Open(dsn)
+	if err != nil {
+		t.Fatalf("storage.Open(%q): %v", dsn, err)
+	}
+	return st
+}
+
+// register регистрирует пользователя и возвращает его API-токен
+func (ts *testService) register(login string) string {
+	ts.t.Helper()
+	_, err := ts.ns.users.Register(login, testPassword)
+	if err != nil {
+		ts.t.Fatalf("Register(%q): %v", login, err)
+	}
+	token, _, err := ts.ns.users.Login(login, testPassword)
+	if err != nil {
+		ts.t.Fatalf("Login(%q): %v", login, err)
+	}
+	return token
+}
+
+// do выполняет запрос с API-токеном token (пустой - без токена) и заголовками headers вида "Имя: значение"
+func (ts *testService) do(token string, method string, path string, body string, headers ...string) (*httptest.ResponseRecorder, dto.Response) {
+	ts.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, header := range headers {
+		name, value, _ := strings.Cut(header, ":")
+		req.Header.Set(name, strings.TrimSpace(value))
+	}
+	recorder := httptest.NewRecorder()
+	ts.ns.server.Handler.ServeHTTP(recorder, req)
+
+	resp := dto.Response{}
+	if recorder.Body.Len() > 0 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			ts.t.Fatalf("%s %s: cannot unmarshal response %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder, resp
+}
+
+// createNote создает заметку через /create и возвращает ее идентификатор
+func (ts *testService) createNote(token string, content string) int64 {
+	ts.t.Helper()
+	_, resp := ts.do(token, http.MethodPost, "/create", `{"name": "Иван", "last_name": "Иванов", "note": "`+content+`"}`)
+	if resp.Result != "OK" {
+		ts.t.Fatalf("/create: %s", resp.Error)
+	}
+	data := struct {
+		ID int64 `json:"id"`
+	}{}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		ts.t.Fatalf("/create: cannot unmarshal data %s: %v", resp.Data, err)
+	}
+	return data.ID
+}
+
+// getNote возвращает заметку по REST-маршруту GET /notes/{id} вместе со статусом ответа
+func (ts *testService) getNote(token string, id int64) (dto.Note, int) {
+	ts.t.Helper()
+	recorder, resp := ts.do(token, http.MethodGet, "/notes/"+itoa(id), "")
+	note := dto.Note{}
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(resp.Data, &note); err != nil {
+			ts.t.Fatalf("GET /notes/%d: cannot unmarshal data %s: %v", id, resp.Data, err)
+		}
+	}
+	return note, recorder.Code
+}
+
+// itoa возвращает десятичную запись id
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// registerAdmin регистрирует пользователя с ролью администратора и возвращает его API-токен
+func (ts *testService) registerAdmin(login string) string {
+	ts.t.Helper()
+	token := ts.register(login)
+	_, err := ts.ns.users.SetAdmin(login, true)
+	if err != nil {
+		ts.t.Fatalf("SetAdmin(%q): %v", login, err)
+	}
+	return token
+}
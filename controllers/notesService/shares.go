@@ -0,0 +1,349 @@
+package notesService
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/auth"
+	"notesServer/gates/sharing"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"strconv"
+	"strings"
+)
+
+// Виды получателей прав в пути /notes/{id}/shares/{вид}/{имя}
+const (
+	shareToUsers  = "users"
+	shareToGroups = "groups"
+)
+
+// sharesPath - разобранный путь /notes/{id}/shares или /notes/{id}/shares/{users|groups}/{имя}
+type sharesPath struct {
+	id   int64
+	kind string // shareToUsers, shareToGroups или "" для списка прав
+	name string
+}
+
+// isSharesPath проверяет, что путь относится к правам доступа к заметке: /notes/{id}/shares...
+func isSharesPath(path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/notes/"), "/")
+	return len(parts) > 1 && parts[1] == "shares"
+}
+
+// parseSharesPath разбирает путь /notes/{id}/shares или /notes/{id}/shares/{users|groups}/{имя}.
+// В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту.
+func parseSharesPath(path string) (*sharesPath, int, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/notes/"), "/")
+	if (len(parts) != 2 && len(parts) != 4) || parts[1] != "shares" {
+		return nil, http.StatusNotFound, fmt.Errorf("resource not found: %s", path)
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id < 1 {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid note id: %s", parts[0])
+	}
+	parsed := &sharesPath{id: id}
+	if len(parts) == 4 {
+		if (parts[2] != shareToUsers && parts[2] != shareToGroups) || parts[3] == "" {
+			return nil, http.StatusNotFound, fmt.Errorf("resource not found: %s", path)
+		}
+		parsed.kind, parsed.name = parts[2], strings.ToLower(parts[3])
+	}
+	return parsed, http.StatusOK, nil
+}
+
+// routeNoteShares направляет запрос к /notes/{id}/shares... обработчику его метода
+func (ns *NotesService) routeNoteShares(w http.ResponseWriter, req *http.Request) {
+	switch strings.Count(strings.TrimPrefix(req.URL.Path, "/notes/"), "/") {
+	case 1: // /notes/{id}/shares
+		if req.Method != http.MethodGet {
+			handleMethodNotAllowed(w, req, http.MethodGet)
+			return
+		}
+		ns.handleListShares(w, req)
+	case 3: // /notes/{id}/shares/{users|groups}/{имя}
+		switch req.Method {
+		case http.MethodPut:
+			ns.handleGrantShare(w, req)
+		case http.MethodDelete:
+			ns.handleRevokeShare(w, req)
+		default:
+			handleMethodNotAllowed(w, req, http.MethodPut, http.MethodDelete)
+		}
+	default:
+		ns.handleListShares(w, req) // путь неверный: обработчик ответит 404
+	}
+}
+
+// handleListShares обрабатывает запрос на получение списка прав доступа к записи
+/*
+Запрос должен быть с методом GET: GET /notes/{id}/shares. Тело запроса игнорируется.
+Список доступен всем, кто может читать запись.
+
+Возвращает клиенту ответ со статусом 200 и правами в порядке их выдачи:
+  {"result": "OK", "data": [
+  {"user": "petrov", "permission": "edit", "granted_by": "ivanov", "created_at": "2023-10-01T12:00:00Z"},
+  {"group": "editors", "permission": "read", "granted_by": "ivanov", "created_at": "2023-10-01T12:05:00Z"}
+  ], "error": ""}
+
+В случае ошибки (400, 403, 404, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleListShares(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleListShares()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Разбор пути и проверка доступа
+	path, status, err := parseSharesPath(req.URL.Path)
+	if err == nil {
+		_, status, err = ns.authorizeNote(req, path.id, accessRead)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authorizeNote(req, path.id, accessRead)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Получение прав
+	shares, err := ns.shares.List(path.id)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.shares.List(path.id)").LogError()
+		return
+	}
+	sharesDto := make([]*dto.Share, 0, len(shares))
+	for _, share := range shares {
+		sharesDto = append(sharesDto, ns.shareToDto(share))
+	}
+
+	// Формирование содержимого для ответа
+	sharesJson, err := json.Marshal(sharesDto)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(sharesDto)").LogError()
+		return
+	}
+	resp.UpdateWithStatus(http.StatusOK, "OK", sharesJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - GET /notes/%d/shares: {count: %d}", path.id, len(sharesDto)))
+}
+
+// handleGrantShare обрабатывает запрос на выдачу права доступа к записи пользователю или группе
+/*
+Запрос должен быть с методом PUT: PUT /notes/{id}/shares/users/{login} или PUT /notes/{id}/shares/groups/{group},
+с содержимым в формате JSON следующего вида:
+  {"permission": "read"}
+
+Права: "read" - чтение записи и ее истории, "edit" - еще и изменение (PUT, PATCH, /update, /revert и т.д.).
+Удалять запись и управлять правами может только ее владелец или администратор.
+Пользователь получает наибольшее из прав, выданных ему лично и его группам. Группы назначает администратор (/admin/groups).
+Повторная выдача права тому же получателю заменяет прежнее право.
+
+Возвращает клиенту ответ со статусом 201 (право выдано впервые) или 200 (право изменено) и выданным правом:
+  {"result": "OK", "data": {"user": "petrov", "permission": "read", "granted_by": "ivanov", "created_at": "2023-10-01T12:00:00Z"}, "error": ""}
+
+В случае ошибки (400, 403, 404 - запись или пользователь не найдены, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleGrantShare(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleGrantShare()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Разбор пути и парсинг запроса
+	path, status, err := parseSharesPath(req.URL.Path)
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	shareRequest := &dto.ShareRequest{}
+	err = json.Unmarshal(requestBytes, shareRequest)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	permission, err := sharing.ParsePermission(shareRequest.Permission)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusBadRequest, "ERROR", nil, err.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: %q", err.Error(), shareRequest.Permission))
+		return
+	}
+
+	// Проверка доступа и получателя права
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	note, status, err := ns.authorizeNote(req, path.id, accessOwner)
+	var subject sharing.Subject
+	if err == nil {
+		subject, status, err = ns.shareSubject(path)
+	}
+	if err == nil && subject.UserID == note.Owner() {
+		status, err = http.StatusBadRequest, errors.New("note owner already has full access")
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authorizeNote(req, path.id, accessOwner)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Выдача права
+	identity, _ := identityFromRequest(req)
+	share, created, err := ns.shares.Grant(path.id, subject, permission, identity.ID)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.shares.Grant(path.id, subject, permission, identity.ID)").LogError()
+		return
+	}
+
+	// Формирование содержимого для ответа
+	shareJson, err := json.Marshal(ns.shareToDto(share))
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "json.Marshal(share)").LogError()
+		return
+	}
+	status = http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	resp.UpdateWithStatus(status, "OK", shareJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - PUT /notes/%d/shares/%s/%s: {permission: %s}", path.id, path.kind, path.name, permission))
+}
+
+// handleRevokeShare обрабатывает запрос на отзыв права доступа к записи
+/*
+Запрос должен быть с методом DELETE: DELETE /notes/{id}/shares/users/{login} или DELETE /notes/{id}/shares/groups/{group}.
+Тело запроса игнорируется.
+
+Отозвать право может владелец записи или администратор, а право, выданное лично пользователю, - и он сам.
+
+Возвращает клиенту ответ со статусом 204 без содержимого.
+
+В случае ошибки (400, 403, 404 - запись не найдена или право не выдавалось, 500):
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleRevokeShare(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleRevokeShare()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Разбор пути и проверка доступа. Отказаться от своего права может любой пользователь.
+	path, status, err := parseSharesPath(req.URL.Path)
+	var subject sharing.Subject
+	if err == nil {
+		subject, status, err = ns.shareSubject(path)
+	}
+	identity, _ := identityFromRequest(req)
+	need := accessOwner
+	if subject.UserID != 0 && subject.UserID == identity.ID {
+		need = accessRead
+	}
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+	if err == nil {
+		_, status, err = ns.authorizeNote(req, path.id, need)
+	}
+	if status == http.StatusInternalServerError {
+		resp.UpdateWithStatus(status, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.authorizeNote(req, path.id, need)").LogError()
+		return
+	}
+	if err != nil {
+		resp.UpdateWithStatus(status, "ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Отзыв права
+	revoked, err := ns.shares.Revoke(path.id, subject)
+	if err != nil {
+		resp.UpdateWithStatus(http.StatusInternalServerError, "ERROR", nil, "internal server error")
+		wErr.Specify(err, "ns.shares.Revoke(path.id, subject)").LogError()
+		return
+	}
+	if !revoked {
+		messageString := fmt.Sprintf("note %d is not shared with %s %q", path.id, strings.TrimSuffix(path.kind, "s"), path.name)
+		resp.UpdateWithStatus(http.StatusNotFound, "ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	resp.UpdateWithStatus(http.StatusNoContent, "OK", nil, "")
+	wErr.LogMsg(fmt.Sprintf("OK - DELETE /notes/%d/shares/%s/%s", path.id, path.kind, path.name))
+}
+
+// shareSubject возвращает получателя права по пути запроса.
+// В случае ошибки возвращается HTTP-статус, с которым нужно ответить клиенту.
+func (ns *NotesService) shareSubject(path *sharesPath) (sharing.Subject, int, error) {
+	if path.kind == shareToGroups {
+		if !auth.ValidGroup(path.name) {
+			return sharing.Subject{}, http.StatusBadRequest, auth.ErrInvalidGroup
+		}
+		return sharing.Subject{Group: path.name}, http.StatusOK, nil
+	}
+	user, err := ns.users.Lookup(path.name)
+	if errors.Is(err, auth.ErrUnknownUser) {
+		return sharing.Subject{}, http.StatusNotFound, fmt.Errorf("%s: %q", err.Error(), path.name)
+	}
+	if err != nil {
+		return sharing.Subject{}, http.StatusInternalServerError, err
+	}
+	return sharing.Subject{UserID: user.ID}, http.StatusOK, nil
+}
+
+// shareToDto возвращает dto.Share для выданного права, заменяя ID пользователей их логинами
+func (ns *NotesService) shareToDto(share sharing.Share) *dto.Share {
+	shareDto := &dto.Share{
+		Group:      share.Group,
+		Permission: share.Permission,
+		GrantedBy:  ns.userLogin(share.GrantedBy),
+		CreatedAt:  share.CreatedAt.UTC(),
+	}
+	if share.UserID != 0 {
+		shareDto.User = ns.userLogin(share.UserID)
+	}
+	return shareDto
+}
+
+// userLogin возвращает логин пользователя id или "#id", если такого пользователя нет
+func (ns *NotesService) userLogin(id int64) string {
+	user, err := ns.users.Get(id)
+	if err != nil {
+		return fmt.Sprintf("#%d", id)
+	}
+	return user.Login
+}
@@ -0,0 +1,282 @@
+package notesService
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"notesServer/gates/storage"
+	"notesServer/models/dto"
+	"notesServer/pkg"
+	"time"
+)
+
+const (
+	snapshotTokenBytes  = 16
+	maxSnapshotsPerUser = 16 // снимок удерживает старые версии от сборки мусора, поэтому их число ограничено
+)
+
+// openSnapshot - снимок, созданный через /snapshot
+type openSnapshot struct {
+	snapshot storage.Snapshot
+	owner    int64 // ID создавшего снимок пользователя: только он читает из снимка и освобождает его
+}
+
+// errSnapshotNotFound ошибка, возвращаемая, если снимка с токеном нет или он создан другим пользователем
+var errSnapshotNotFound = errors.New("snapshot with this token doesn't exist")
+
+// noteReader - источник чтения заметок: текущее состояние хранилища или его снимок
+type noteReader interface {
+	GetByID(id int64) (any, bool)
+	GetAll() (map[int64]any, bool)
+}
+
+// snapshotErr возвращает ошибку чтения из снимка (см. storage.Snapshot.Err). Для самого хранилища возвращается nil.
+func snapshotErr(reader noteReader) error {
+	snapshot, ok := reader.(storage.Snapshot)
+	if !ok {
+		return nil
+	}
+	return snapshot.Err()
+}
+
+// readerStatus возвращает HTTP-статус ответа на ошибку выбора или чтения состояния хранилища:
+// 410, если нужные версии уже удалены сборщиком мусора, иначе status
+func readerStatus(err error, status int) int {
+	if errors.Is(err, storage.ErrVersionCollected) {
+		return http.StatusGone
+	}
+	return status
+}
+
+// readerForRequest возвращает источник чтения заметок по параметрам URL запроса:
+//
+//	?as_of=2023-10-01T12:00:00Z - состояние на момент времени в формате RFC3339
+//	?snapshot=9f86d081884c7d65  - состояние снимка, токен которого получен через /snapshot
+//
+// Без параметров возвращается само хранилище. После чтения нужно вызвать release и проверить snapshotErr:
+// снимок мог быть освобожден сборщиком мусора во время чтения.
+func (ns *NotesService) readerForRequest(req *http.Request) (reader noteReader, release func(), err error) {
+	asOf := req.URL.Query().Get("as_of")
+	token := req.URL.Query().Get("snapshot")
+	if asOf == "" && token == "" {
+		return ns.getStorage(), func() {}, nil
+	}
+	if asOf != "" && token != "" {
+		return nil, nil, errors.New("'as_of' and 'snapshot' cannot be used together")
+	}
+
+	// Снимок, созданный через /snapshot, освобождается только через /release-snapshot
+	if token != "" {
+		identity, _ := identityFromRequest(req)
+		snapshot, err := ns.ownSnapshot(token, identity.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return snapshot, func() {}, nil
+	}
+
+	versioned, ok := ns.getStorage().(storage.Versioned)
+	if !ok {
+		return nil, nil, errors.New("storage does not support point-in-time reads")
+	}
+	t, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid 'as_of' time: %s", err)
+	}
+	snapshot, err := versioned.SnapshotAsOf(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshot, snapshot.Release, nil
+}
+
+// ownSnapshot возвращает снимок с токеном token, созданный пользователем owner. Снимок, освобожденный
+// сборщиком мусора, тоже возвращается: об этом сообщает snapshotErr, а удаляет его addSnapshot.
+func (ns *NotesService) ownSnapshot(token string, owner int64) (storage.Snapshot, error) {
+	ns.snapshotsMu.Lock()
+	defer ns.snapshotsMu.Unlock()
+
+	open, ok := ns.snapshots[token]
+	if !ok || open.owner != owner {
+		return nil, errSnapshotNotFound
+	}
+	return open.snapshot, nil
+}
+
+// addSnapshot регистрирует снимок пользователя owner и возвращает его токен. Снимки, освобожденные сборщиком мусора,
+// предварительно удаляются. Если у пользователя уже maxSnapshotsPerUser снимков, возвращается ошибка.
+func (ns *NotesService) addSnapshot(snapshot storage.Snapshot, owner int64) (string, error) {
+	ns.snapshotsMu.Lock()
+	defer ns.snapshotsMu.Unlock()
+
+	count := 0
+	for token, open := range ns.snapshots {
+		if open.snapshot.Err() != nil {
+			delete(ns.snapshots, token)
+			continue
+		}
+		if open.owner == owner {
+			count++
+		}
+	}
+	if count >= maxSnapshotsPerUser {
+		return "", fmt.Errorf("too many open snapshots: %d (release one with /release-snapshot)", count)
+	}
+
+	random := make([]byte, snapshotTokenBytes)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+	ns.snapshots[token] = openSnapshot{snapshot: snapshot, owner: owner}
+	return token, nil
+}
+
+// handleCreateSnapshot обрабатывает запрос на создание снимка текущего состояния хранилища
+/*
+Запрос должен быть с методом POST. Тело запроса игнорируется.
+
+Полученный токен передается в параметре URL ?snapshot= запросов /get и /get-all.
+Снимок доступен только создавшему его пользователю и хранится до запроса /release-snapshot,
+но не дольше окна хранения версий хранилища. Одновременно у пользователя может быть не больше
+maxSnapshotsPerUser снимков.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": {"token": "9f86d081884c7d659a2feaa0c55ad015"}, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleCreateSnapshot(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleCreateSnapshot()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Тело запроса игнорируется, поэтому его парсинг не производится
+
+	versioned, ok := ns.getStorage().(storage.Versioned)
+	if !ok {
+		messageString := "storage does not support snapshots"
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+
+	// Создание снимка
+	identity, _ := identityFromRequest(req)
+	snapshot := versioned.Snapshot()
+	token, err := ns.addSnapshot(snapshot, identity.ID)
+	if err != nil {
+		snapshot.Release()
+		resp.Update("ERROR", nil, err.Error())
+		wErr.LogMsg(err.Error())
+		return
+	}
+
+	// Формирование содержимого для ответа
+	tokenJson, err := json.Marshal(map[string]string{
+		"token": token,
+	})
+	if err != nil {
+		resp.Update("ERROR", nil, err.Error())
+		wErr.Specify(err, "json.Marshal(tokenMap)").LogError()
+		return
+	}
+	resp.Update("OK", tokenJson, "")
+	wErr.LogMsg(fmt.Sprintf("OK - snapshot: {user: %d}", identity.ID))
+}
+
+// handleReleaseSnapshot обрабатывает запрос на освобождение снимка
+/*
+Запрос должен быть с методом POST и с содержимым в формате JSON следующего вида:
+  {"token": "9f86d081884c7d659a2feaa0c55ad015"}
+
+Освободить снимок может только создавший его пользователь.
+
+Возвращает клиенту ответ с содержимым в формате JSON следующего вида:
+  {"result": "OK", "data": null, "error": ""}
+
+В случае ошибки:
+  {"result": "ERROR", "data": null, "error": "error description"}
+*/
+func (ns *NotesService) handleReleaseSnapshot(w http.ResponseWriter, req *http.Request) {
+	setHttpHeaders(w)
+
+	wErr := pkg.NewWrappedErrorWithFile("(ns *NotesService) handleReleaseSnapshot()")
+
+	// Создание ответа
+	resp := &dto.Response{}
+	defer writeResponseContent(w, resp, wErr)
+
+	// Проверка метода
+	if req.Method != http.MethodPost {
+		messageString := fmt.Sprintf("invalid request method: '%s' (need '%s')", req.Method, http.MethodPost)
+		wErr.LogMsg(messageString)
+		resp.Update("ERROR", nil, messageString)
+		return
+	}
+
+	// Парсинг запроса
+	requestBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		errorString := fmt.Sprintf("cannot read request bytes: %s", err)
+		resp.Update("ERROR", nil, errorString)
+		wErr.Specify(err, "io.ReadAll(req.Body)").LogError()
+		return
+	}
+	tokenMap := map[string]string{}
+	err = json.Unmarshal(requestBytes, &tokenMap)
+	if err != nil {
+		messageString := fmt.Sprintf("cannot unmarshal request json: %s", err.Error())
+		resp.Update("ERROR", nil, messageString)
+		wErr.LogMsg(messageString)
+		return
+	}
+	token := tokenMap["token"]
+
+	// Освобождение снимка. Чужой снимок не освобождается и не выдается: для другого пользователя его нет.
+	identity, _ := identityFromRequest(req)
+	ns.snapshotsMu.Lock()
+	open, ok := ns.snapshots[token]
+	if !ok || open.owner != identity.ID {
+		ns.snapshotsMu.Unlock()
+		resp.Update("ERROR", nil, errSnapshotNotFound.Error())
+		wErr.LogMsg(fmt.Sprintf("%s: {user: %d}", errSnapshotNotFound.Error(), identity.ID))
+		return
+	}
+	delete(ns.snapshots, token)
+	ns.snapshotsMu.Unlock()
+	open.snapshot.Release()
+
+	resp.Update("OK", nil, "")
+	wErr.LogMsg(fmt.Sprintf("OK - release-snapshot: {user: %d}", identity.ID))
+}
+
+// releaseSnapshots освобождает все снимки, созданные через /snapshot. Вызывается при замене хранилища:
+// снимки и их токены относятся к прежнему хранилищу.
+func (ns *NotesService) releaseSnapshots() {
+	ns.snapshotsMu.Lock()
+	defer ns.snapshotsMu.Unlock()
+
+	for _, open := range ns.snapshots {
+		open.snapshot.Release()
+	}
+	ns.snapshots = make(map[string]openSnapshot)
+}
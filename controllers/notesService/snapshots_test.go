@@ -0,0 +1,142 @@
+package notesService
+
+import (
+	"encoding/json"
+	"net/http"
+	"notesServer/gates/storage/mvcc"
+	"notesServer/models/dto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createSnapshot создает снимок через /snapshot и возвращает его токен
+func (ts *testService) createSnapshot(token string) string {
+	ts.t.Helper()
+	_, resp := ts.do(token, http.MethodPost, "/snapshot", "")
+	if resp.Result != "OK" {
+		ts.t.Fatalf("/snapshot: %s", resp.Error)
+	}
+	data := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		ts.t.Fatalf("/snapshot: cannot unmarshal data %s: %v", resp.Data, err)
+	}
+	return data.Token
+}
+
+// releaseSnapshot освобождает снимок через /release-snapshot и возвращает ответ
+func (ts *testService) releaseSnapshot(token string, snapshot string) dto.Response {
+	ts.t.Helper()
+	_, resp := ts.do(token, http.MethodPost, "/release-snapshot", `{"token": "`+snapshot+`"}`)
+	return resp
+}
+
+func TestSnapshotIsReadOnlyAsOfCreation(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "mvcc://?init_id=1"))
+	token := ts.register("alice")
+	id := ts.createNote(token, "первая")
+	snapshot := ts.createSnapshot(token)
+	ts.do(token, http.MethodPut, "/notes/"+itoa(id), `{"name": "Иван", "last_name": "Иванов", "note": "вторая"}`)
+
+	recorder, resp := ts.do(token, http.MethodGet, "/notes/"+itoa(id)+"?snapshot="+snapshot, "")
+	if recorder.Code != http.StatusOK || !strings.Contains(string(resp.Data), "первая") {
+		t.Fatalf("GET from snapshot = %d %+v", recorder.Code, resp)
+	}
+	if resp := ts.releaseSnapshot(token, snapshot); resp.Result != "OK" {
+		t.Fatalf("/release-snapshot: %+v", resp)
+	}
+	if recorder, _ := ts.do(token, http.MethodGet, "/notes/"+itoa(id)+"?snapshot="+snapshot, ""); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("GET from released snapshot = %d, want 400", recorder.Code)
+	}
+}
+
+func TestSnapshotBelongsToOwner(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "mvcc://?init_id=1"))
+	alice := ts.register("alice")
+	bob := ts.register("bob")
+	ts.createNote(alice, "заметка")
+	snapshot := ts.createSnapshot(alice)
+
+	if recorder, _ := ts.do(bob, http.MethodGet, "/notes?snapshot="+snapshot, ""); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("GET from another user's snapshot = %d, want 400", recorder.Code)
+	}
+	if resp := ts.releaseSnapshot(bob, snapshot); resp.Result != "ERROR" {
+		t.Fatalf("/release-snapshot of another user's snapshot: %+v", resp)
+	}
+	if recorder, _ := ts.do(alice, http.MethodGet, "/notes?snapshot="+snapshot, ""); recorder.Code != http.StatusOK {
+		t.Fatalf("GET from own snapshot after foreign release = %d", recorder.Code)
+	}
+}
+
+func TestSnapshotTokensAreRandom(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "mvcc://?init_id=1"))
+	token := ts.register("alice")
+	first, second := ts.createSnapshot(token), ts.createSnapshot(token)
+	if first == second || len(first) != 2*snapshotTokenBytes {
+		t.Fatalf("snapshot tokens %q and %q", first, second)
+	}
+}
+
+func TestSnapshotsPerUserLimit(t *testing.T) {
+	ts := newTestService(t, openStorage(t, "mvcc://?init_id=1"))
+	alice := ts.register("alice")
+	bob := ts.register("bob")
+	snapshots := make([]string, 0, maxSnapshotsPerUser)
+	for i := 0; i < maxSnapshotsPerUser; i++ {
+		snapshots = append(snapshots, ts.createSnapshot(alice))
+	}
+
+	if _, resp := ts.do(alice, http.MethodPost, "/snapshot", ""); resp.Result != "ERROR" {
+		t.Fatalf("/snapshot over the limit: %+v", resp)
+	}
+	// Ограничение действует для каждого пользователя отдельно
+	ts.createSnapshot(bob)
+
+	// Освобожденный снимок больше не учитывается
+	ts.releaseSnapshot(alice, snapshots[0])
+	ts.createSnapshot(alice)
+}
+
+func TestCollectedSnapshotsArePruned(t *testing.T) {
+	st := openStorage(t, "mvcc://?init_id=1&retention=0s&gc_interval=0s")
+	ts := newTestService(t, st)
+	token := ts.register("alice")
+	ts.createNote(token, "заметка")
+	for i := 0; i < maxSnapshotsPerUser; i++ {
+		ts.createSnapshot(token)
+	}
+	ts.createNote(token, "вторая")
+
+	time.Sleep(time.Millisecond)
+	st.(*mvcc.MVCC).CollectGarbage()
+
+	// Снимки, освобожденные сборщиком мусора, не занимают место в ограничении и удаляются
+	ts.createSnapshot(token)
+	ts.ns.snapshotsMu.Lock()
+	defer ts.ns.snapshotsMu.Unlock()
+	if n := len(ts.ns.snapshots); n != 1 {
+		t.Fatalf("%d snapshots are kept after garbage collection, want 1", n)
+	}
+}
+
+func TestReadCollectedSnapshot(t *testing.T) {
+	st := openStorage(t, "mvcc://?init_id=1&retention=0s&gc_interval=0s")
+	ts := newTestService(t, st)
+	token := ts.register("alice")
+	id := ts.createNote(token, "первая")
+	snapshot := ts.createSnapshot(token)
+	ts.do(token, http.MethodPut, "/notes/"+itoa(id), `{"name": "Иван", "last_name": "Иванов", "note": "вторая"}`)
+
+	// Окно хранения нулевое: сборщик мусора принудительно освобождает снимок и удаляет его версии
+	time.Sleep(time.Millisecond)
+	st.(*mvcc.MVCC).CollectGarbage()
+
+	for _, path := range []string{"/notes/" + itoa(id), "/notes", "/get-all"} {
+		recorder, resp := ts.do(token, http.MethodGet, path+"?snapshot="+snapshot, "")
+		if recorder.Code != http.StatusGone || resp.Result != "ERROR" {
+			t.Errorf("GET %s from a collected snapshot = %d %+v, want 410", path, recorder.Code, resp)
+		}
+	}
+}
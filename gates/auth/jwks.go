@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Алгоритмы подписи JWT, которые принимает JWTVerifier
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+	algES256 = "ES256"
+)
+
+// Параметры загрузки ключей
+const (
+	jwksTimeout     = 10 * time.Second // время ожидания ответа при загрузке набора ключей по URL
+	minHMACKeyBytes = 32               // минимальная длина секрета HS256 (RFC 7518, раздел 3.2)
+)
+
+// jwk - ключ из набора ключей JWKS (RFC 7517) в том виде, в котором он приходит в JSON
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`   // oct: секрет HS256
+	N   string `json:"n"`   // RSA: модуль
+	E   string `json:"e"`   // RSA: открытая экспонента
+	Crv string `json:"crv"` // EC: кривая (поддерживается только P-256)
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verificationKey - ключ проверки подписи JWT: алгоритм, для которого он предназначен, и сам ключ
+// ([]byte для HS256, *rsa.PublicKey для RS256, *ecdsa.PublicKey для ES256)
+type verificationKey struct {
+	kid string
+	alg string
+	key any
+}
+
+// loadJWKS загружает набор ключей JWKS из файла или по URL (http:// или https://).
+// Ключи, не предназначенные для подписи (use != "sig"), и ключи неподдерживаемых типов пропускаются.
+func loadJWKS(source string) ([]verificationKey, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = fetchJWKS(source)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load jwks from %s: %w", source, err)
+	}
+	return parseJWKS(data)
+}
+
+// fetchJWKS загружает набор ключей по URL
+func fetchJWKS(url string) ([]byte, error) {
+	client := http.Client{Timeout: jwksTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS разбирает набор ключей JWKS: {"keys": [...]}
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse jwks: %w", err)
+	}
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.verificationKey()
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key %d (kid %q): %w", i, k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no supported signing keys")
+	}
+	return keys, nil
+}
+
+// errUnsupportedKey ошибка, возвращаемая для ключей JWKS неподдерживаемого типа или алгоритма
+var errUnsupportedKey = errors.New("unsupported key")
+
+// verificationKey возвращает ключ проверки подписи. Алгоритм определяется типом ключа и,
+// если он указан в ключе, должен с ним совпадать: иначе ключом RSA можно было бы проверить подпись HS256.
+func (k jwk) verificationKey() (verificationKey, error) {
+	var alg string
+	var key any
+	var err error
+	switch k.Kty {
+	case "oct":
+		alg = algHS256
+		var secret []byte
+		secret, err = base64.RawURLEncoding.DecodeString(k.K)
+		if err == nil && len(secret) < minHMACKeyBytes {
+			err = fmt.Errorf("hs256 key must be at least %d bytes", minHMACKeyBytes)
+		}
+		key = secret
+	case "RSA":
+		alg = algRS256
+		key, err = k.rsaPublicKey()
+	case "EC":
+		alg = algES256
+		key, err = k.ecdsaPublicKey()
+	default:
+		return verificationKey{}, errUnsupportedKey
+	}
+	if k.Alg != "" && k.Alg != alg {
+		return verificationKey{}, errUnsupportedKey
+	}
+	if err != nil {
+		return verificationKey{}, err
+	}
+	return verificationKey{kid: k.Kid, alg: alg, key: key}, nil
+}
+
+// rsaPublicKey возвращает открытый ключ RSA
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid n: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid e: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid e: out of range")
+	}
+	modulus := new(big.Int).SetBytes(n)
+	if modulus.BitLen() < 2048 {
+		return nil, errors.New("rsa key must be at least 2048 bits")
+	}
+	return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+}
+
+// ecdsaPublicKey возвращает открытый ключ ECDSA на кривой P-256
+func (k jwk) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	if k.Crv != "P-256" {
+		return nil, errUnsupportedKey
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y: %w", err)
+	}
+	if len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid p-256 coordinates length")
+	}
+	_, err = ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid p-256 point: %w", err)
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+// testSigningKeys - закрытые ключи RS256 и ES256, которыми подписываются токены в тестах.
+// Ключ RSA создается долго, поэтому ключи создаются один раз на все тесты.
+var testSigningKeys struct {
+	once sync.Once
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	err  error
+}
+
+// signingKeys возвращает закрытые ключи RS256 и ES256 для тестов
+func signingKeys(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey) {
+	t.Helper()
+	testSigningKeys.once.Do(func() {
+		testSigningKeys.rsa, testSigningKeys.err = rsa.GenerateKey(rand.Reader, 2048)
+		if testSigningKeys.err == nil {
+			testSigningKeys.ec, testSigningKeys.err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}
+	})
+	if testSigningKeys.err != nil {
+		t.Fatal(testSigningKeys.err)
+	}
+	return testSigningKeys.rsa, testSigningKeys.ec
+}
+
+// b64 кодирует data в base64url без дополнения
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// rsaJWK возвращает открытый ключ RSA в формате JWK
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]any {
+	return map[string]any{"kty": "RSA", "kid": kid, "alg": algRS256, "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+// ecJWK возвращает открытый ключ P-256 в формате JWK
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]any {
+	return map[string]any{"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+// jwksOf возвращает набор ключей JWKS из ключей keys
+func jwksOf(t *testing.T, keys ...map[string]any) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// newJWKSVerifier возвращает проверку JWT с ключами testKid (HS256), "rsa" (RS256) и "ec" (ES256)
+func newJWKSVerifier(t *testing.T, config JWTConfig) *JWTVerifier {
+	t.Helper()
+	rsaKey, ecKey := signingKeys(t)
+	keys, err := parseJWKS(jwksOf(t,
+		map[string]any{"kty": "oct", "kid": testKid, "k": b64(testSecret)},
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		ecJWK("ec", &ecKey.PublicKey),
+	))
+	if err != nil {
+		t.Fatalf("parseJWKS(): %v", err)
+	}
+	verifier := newTestVerifier(config)
+	verifier.keys = keys
+	return verifier
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, ecKey := signingKeys(t)
+	otherEC, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := parseJWKS(jwksOf(t,
+		map[string]any{"kty": "oct", "kid": "hmac", "alg": algHS256, "k": b64(testSecret)},
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		ecJWK("ec", &ecKey.PublicKey),
+		// Пропускаются: ключ шифрования, ключ с чужим алгоритмом, неизвестный тип и кривая
+		map[string]any{"kty": "oct", "kid": "enc", "use": "enc", "k": b64(testSecret)},
+		map[string]any{"kty": "RSA", "kid": "confused", "alg": algHS256, "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		map[string]any{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(make([]byte, 32))},
+		map[string]any{"kty": "EC", "kid": "p384", "crv": "P-384",
+			"x": b64(otherEC.X.Bytes()), "y": b64(otherEC.Y.Bytes())},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"hmac": algHS256, "rsa": algRS256, "ec": algES256}
+	if len(keys) != len(want) {
+		t.Fatalf("parseJWKS() returned %d keys, want %d", len(keys), len(want))
+	}
+	for _, key := range keys {
+		if want[key.kid] != key.alg {
+			t.Errorf("parseJWKS() key %q has alg %q, want %q", key.kid, key.alg, want[key.kid])
+		}
+	}
+}
+
+func TestParseJWKSRejectsWeakKeys(t *testing.T) {
+	weakRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, ecKey := signingKeys(t)
+	invalidPoint := ecJWK("ec", &ecKey.PublicKey)
+	invalidPoint["y"] = b64(make([]byte, 32))
+	sets := map[string][]byte{
+		"short hs256 secret": jwksOf(t, map[string]any{"kty": "oct", "k": b64(testSecret[:31])}),
+		"1024-bit rsa":       jwksOf(t, rsaJWK("rsa", &weakRSA.PublicKey)),
+		"rsa exponent 1":     jwksOf(t, map[string]any{"kty": "RSA", "n": b64(rsaKey.N.Bytes()), "e": "AQ"}),
+		"point off curve":    jwksOf(t, invalidPoint),
+		"no supported keys":  jwksOf(t, map[string]any{"kty": "OKP", "crv": "Ed25519", "x": b64(make([]byte, 32))}),
+		"empty":              []byte(`{"keys": []}`),
+		"not json":           []byte(`keys`),
+	}
+	for name, data := range sets {
+		if keys, err := parseJWKS(data); err == nil {
+			t.Errorf("parseJWKS() of %s = %d keys, want error", name, len(keys))
+		}
+	}
+}
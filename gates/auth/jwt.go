@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры проверки JWT по умолчанию
+const (
+	defaultLoginClaim  = "preferred_username"
+	defaultGroupsClaim = "groups"
+	jwksReloadInterval = time.Minute // не чаще этого набор ключей перезагружается из-за неизвестного kid
+)
+
+// ErrInvalidJWT ошибка, возвращаемая для JWT с неверной подписью, неподходящими утверждениями или истекшим сроком
+var ErrInvalidJWT = errors.New("invalid jwt")
+
+// JWTConfig - параметры проверки JWT, выданных внешним сервером авторизации
+type JWTConfig struct {
+	JWKS        string        // путь к файлу или URL набора ключей JWKS
+	Issuer      string        // ожидаемое значение iss (если пусто, не проверяется)
+	Audience    string        // значение, которое должно быть в aud (если пусто, не проверяется)
+	Leeway      time.Duration // допустимое расхождение часов при проверке exp и nbf
+	LoginClaim  string        // утверждение с логином пользователя (по умолчанию preferred_username, затем sub)
+	GroupsClaim string        // утверждение со списком групп пользователя (по умолчанию groups)
+	AdminGroup  string        // группа из токена, участники которой получают роль администратора
+}
+
+// JWTVerifier проверяет JWT с подписью HS256, RS256 или ES256 ключами из набора JWKS.
+// При неизвестном kid набор ключей перезагружается, поэтому смена ключей на сервере авторизации
+// не требует перезапуска.
+type JWTVerifier struct {
+	config   JWTConfig
+	keys     []verificationKey
+	loadedAt time.Time
+	mu       sync.Mutex
+}
+
+// NewJWTVerifier возвращает проверку JWT с параметрами config, загружая набор ключей config.JWKS
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	if config.JWKS == "" {
+		return nil, errors.New("jwks source is required")
+	}
+	if config.LoginClaim == "" {
+		config.LoginClaim = defaultLoginClaim
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = defaultGroupsClaim
+	}
+	config.AdminGroup = strings.ToLower(config.AdminGroup)
+	keys, err := loadJWKS(config.JWKS)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTVerifier{config: config, keys: keys, loadedAt: time.Now()}, nil
+}
+
+// LooksLikeJWT проверяет, что токен имеет вид JWT (три части через точку), а не API-токена
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && !strings.HasPrefix(token, tokenPrefix)
+}
+
+// jwtHeader - заголовок JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// jwtClaims - утверждения JWT
+type jwtClaims map[string]any
+
+// Verify проверяет подпись и утверждения exp, nbf, iss и aud токена и возвращает его утверждения.
+// Токен без exp не принимается.
+func (v *JWTVerifier) Verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidJWT)
+	}
+
+	// Заголовок и подпись
+	header := jwtHeader{}
+	err := decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidJWT)
+	}
+	if header.Alg != algHS256 && header.Alg != algRS256 && header.Alg != algES256 {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidJWT, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidJWT)
+	}
+	if !v.verifySignature(header, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, fmt.Errorf("%w: signature verification failed", ErrInvalidJWT)
+	}
+
+	// Утверждения
+	claims := jwtClaims{}
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidJWT)
+	}
+	err = v.checkClaims(claims, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJWT, err)
+	}
+	return claims, nil
+}
+
+// Identify проверяет токен и возвращает пользователя, которому он выдан. Пользователь определяется утверждениями
+// iss и sub, его учетная запись создается при первом входе (см. Users.Provision) и не совпадает с учетными записями,
+// зарегистрированными с паролем. К группам пользователя добавляются группы из токена,
+// а участники группы JWTConfig.AdminGroup получают роль администратора.
+func (v *JWTVerifier) Identify(users *Users, token string) (Identity, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return Identity{}, err
+	}
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Identity{}, fmt.Errorf("%w: sub claim is required", ErrInvalidJWT)
+	}
+	login, _ := claims[v.config.LoginClaim].(string)
+	if login == "" {
+		login = subject
+	}
+	identity, err := users.Provision(issuer, subject, login)
+	if errors.Is(err, ErrInvalidLogin) || errors.Is(err, ErrLoginBelongsToAccount) {
+		return Identity{}, fmt.Errorf("%w: %s claim: %w", ErrInvalidJWT, v.config.LoginClaim, err)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+
+	// Группы и роль из токена
+	tokenGroups, _ := claims[v.config.GroupsClaim].([]any)
+	groups := append([]string(nil), identity.Groups...)
+	for _, groupAny := range tokenGroups {
+		group, ok := groupAny.(string)
+		group = strings.ToLower(group)
+		if !ok || !ValidGroup(group) || containsString(groups, group) {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	identity.Groups = groups
+	if v.config.AdminGroup != "" && containsString(groups, v.config.AdminGroup) {
+		identity.Admin = true
+	}
+	return identity, nil
+}
+
+// verifySignature проверяет подпись ключами с подходящим алгоритмом и kid.
+// Если ключа с kid нет, набор ключей перезагружается не чаще раза в jwksReloadInterval.
+func (v *JWTVerifier) verifySignature(header jwtHeader, signed []byte, signature []byte) bool {
+	keys, found := v.keysFor(header)
+	if !found && header.Kid != "" {
+		keys = v.reloadKeysFor(header)
+	}
+	for _, key := range keys {
+		if verifyWithKey(key, signed, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// keysFor возвращает ключи для алгоритма и kid заголовка (без kid - все ключи алгоритма)
+// и признак того, что ключ с таким kid есть в наборе
+func (v *JWTVerifier) keysFor(header jwtHeader) ([]verificationKey, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]verificationKey, 0, 1)
+	found := false
+	for _, key := range v.keys {
+		if header.Kid != "" && key.kid != header.Kid {
+			continue
+		}
+		found = true
+		if key.alg == header.Alg {
+			keys = append(keys, key)
+		}
+	}
+	return keys, found
+}
+
+// reloadKeysFor перезагружает набор ключей и возвращает ключи для заголовка
+func (v *JWTVerifier) reloadKeysFor(header jwtHeader) []verificationKey {
+	v.mu.Lock()
+	if time.Since(v.loadedAt) < jwksReloadInterval {
+		v.mu.Unlock()
+		return nil
+	}
+	v.loadedAt = time.Now()
+	v.mu.Unlock()
+
+	keys, err := loadJWKS(v.config.JWKS)
+	if err != nil {
+		return nil
+	}
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+
+	found, _ := v.keysFor(header)
+	return found
+}
+
+// checkClaims проверяет срок действия, издателя и получателя токена на момент now
+func (v *JWTVerifier) checkClaims(claims jwtClaims, now time.Time) error {
+	exp, ok := claims.numericDate("exp")
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if !now.Before(exp.Add(v.config.Leeway)) {
+		return errors.New("token has expired")
+	}
+	if _, present := claims["nbf"]; present {
+		nbf, ok := claims.numericDate("nbf")
+		if !ok {
+			return errors.New("invalid nbf claim")
+		}
+		if now.Add(v.config.Leeway).Before(nbf) {
+			return errors.New("token is not valid yet")
+		}
+	}
+	if v.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+			return errors.New("unexpected issuer")
+		}
+	}
+	if v.config.Audience != "" && !claims.hasAudience(v.config.Audience) {
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+// numericDate возвращает утверждение name в формате NumericDate (секунды от начала эпохи Unix)
+func (c jwtClaims) numericDate(name string) (time.Time, bool) {
+	seconds, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// hasAudience проверяет, что audience есть в утверждении aud (строка или массив строк)
+func (c jwtClaims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyWithKey проверяет подпись signature данных signed ключом key
+func verifyWithKey(key verificationKey, signed []byte, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch k := key.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// Подпись ES256 - это r и s по 32 байта подряд (RFC 7518, раздел 3.4), а не ASN.1
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	default:
+		return false
+	}
+}
+
+// decodeJWTPart декодирует часть JWT: JSON в base64url без дополнения
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// containsString проверяет, что s есть в values
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
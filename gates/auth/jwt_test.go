@@ -0,0 +1,336 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSecret - секрет HS256 ключа testKid, которым подписываются токены в тестах
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+const testKid = "test-key"
+
+// newTestVerifier возвращает проверку JWT с единственным ключом HS256 testKid
+func newTestVerifier(config JWTConfig) *JWTVerifier {
+	if config.LoginClaim == "" {
+		config.LoginClaim = defaultLoginClaim
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = defaultGroupsClaim
+	}
+	return &JWTVerifier{
+		config:   config,
+		keys:     []verificationKey{{kid: testKid, alg: algHS256, key: testSecret}},
+		loadedAt: time.Now(),
+	}
+}
+
+// encodeJWTPart кодирует часть JWT: JSON в base64url без дополнения
+func encodeJWTPart(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signJWT возвращает токен с заголовком header и утверждениями claims, подписанный HS256 секретом secret
+func signJWT(t *testing.T, header map[string]any, claims map[string]any, secret []byte) string {
+	t.Helper()
+	return signJWTWith(t, header, claims, func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	})
+}
+
+// signJWTWith возвращает токен с заголовком header и утверждениями claims, подписанный функцией sign
+func signJWTWith(t *testing.T, header map[string]any, claims map[string]any, sign func(signed []byte) []byte) string {
+	t.Helper()
+	signed := encodeJWTPart(t, header) + "." + encodeJWTPart(t, claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+// testJWT возвращает действующий час токен с утверждениями claims, подписанный ключом testKid
+func testJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	return signJWT(t, map[string]any{"alg": algHS256, "kid": testKid, "typ": "JWT"}, claims, testSecret)
+}
+
+func TestIdentifyKeepsJWTAccountsSeparate(t *testing.T) {
+	users := NewMemoryUsers(time.Hour)
+	local, err := users.Register("alice", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = users.SetGroups("alice", []string{"finance"}); err != nil {
+		t.Fatal(err)
+	}
+	verifier := newTestVerifier(JWTConfig{})
+
+	// Логин из токена совпадает с логином учетной записи с паролем: вход отклоняется
+	_, err = verifier.Identify(users, testJWT(t, map[string]any{"iss": "https://idp", "sub": "u-1", "preferred_username": "alice"}))
+	if !errors.Is(err, ErrInvalidJWT) || !errors.Is(err, ErrLoginBelongsToAccount) {
+		t.Fatalf("Identify() with a local account login: %v", err)
+	}
+
+	bob, err := verifier.Identify(users, testJWT(t, map[string]any{"iss": "https://idp", "sub": "u-2", "preferred_username": "bob"}))
+	if err != nil {
+		t.Fatalf("Identify(): %v", err)
+	}
+	if bob.ID == local.ID || bob.Admin || len(bob.Groups) != 0 {
+		t.Fatalf("Identify() = %+v: jwt user inherited a local account", bob)
+	}
+
+	// Учетная запись определяется iss и sub: смена логина в токене ее не меняет
+	renamed, err := verifier.Identify(users, testJWT(t, map[string]any{"iss": "https://idp", "sub": "u-2", "preferred_username": "robert"}))
+	if err != nil || renamed.ID != bob.ID {
+		t.Fatalf("Identify() after login change = %+v, %v, want user %d", renamed, err, bob.ID)
+	}
+
+	// Тот же sub другого издателя - другой пользователь, и он не может занять логин bob
+	_, err = verifier.Identify(users, testJWT(t, map[string]any{"iss": "https://other-idp", "sub": "u-2", "preferred_username": "bob"}))
+	if !errors.Is(err, ErrLoginBelongsToAccount) {
+		t.Fatalf("Identify() with the same sub of another issuer: %v", err)
+	}
+
+	// Без sub пользователя определить нельзя
+	_, err = verifier.Identify(users, testJWT(t, map[string]any{"iss": "https://idp", "preferred_username": "carol"}))
+	if !errors.Is(err, ErrInvalidJWT) {
+		t.Fatalf("Identify() without sub: %v", err)
+	}
+
+	// Пользователь JWT не входит по паролю
+	if _, _, err = users.Login("bob", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login() of a jwt user: %v", err)
+	}
+}
+
+// jwtLifetime - утверждения действующего час токена
+func jwtLifetime() map[string]any {
+	return map[string]any{"sub": "u-1", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+// withClaims возвращает jwtLifetime, дополненные утверждениями extra
+func withClaims(extra map[string]any) map[string]any {
+	claims := jwtLifetime()
+	for name, value := range extra {
+		claims[name] = value
+	}
+	return claims
+}
+
+// tamper заменяет часть index токена token на part
+func tamper(token string, index int, part string) string {
+	parts := strings.Split(token, ".")
+	parts[index] = part
+	return strings.Join(parts, ".")
+}
+
+func TestVerifySupportedAlgorithms(t *testing.T) {
+	verifier := newJWKSVerifier(t, JWTConfig{})
+	rsaKey, ecKey := signingKeys(t)
+	tokens := map[string]string{
+		"HS256":             testJWT(t, jwtLifetime()),
+		"HS256 without kid": signJWT(t, map[string]any{"alg": algHS256}, jwtLifetime(), testSecret),
+		"RS256": signJWTWith(t, map[string]any{"alg": algRS256, "kid": "rsa"}, jwtLifetime(), func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signature
+		}),
+		"ES256": signJWTWith(t, map[string]any{"alg": algES256, "kid": "ec"}, jwtLifetime(), func(signed []byte) []byte {
+			return signES256(t, ecKey, signed)
+		}),
+	}
+	for name, token := range tokens {
+		claims, err := verifier.Verify(token)
+		if err != nil || claims["sub"] != "u-1" {
+			t.Errorf("Verify() of %s token = %v, %v", name, claims, err)
+		}
+	}
+}
+
+// signES256 возвращает подпись ES256: r и s по 32 байта подряд
+func signES256(t *testing.T, key *ecdsa.PrivateKey, signed []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(signed)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature
+}
+
+func TestVerifyRejectsAlgorithms(t *testing.T) {
+	verifier := newJWKSVerifier(t, JWTConfig{})
+	rsaKey, ecKey := signingKeys(t)
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := map[string]string{
+		"alg none":            signJWTWith(t, map[string]any{"alg": "none"}, jwtLifetime(), func([]byte) []byte { return nil }),
+		"alg missing":         signJWT(t, map[string]any{"kid": testKid}, jwtLifetime(), testSecret),
+		"alg in lower case":   signJWT(t, map[string]any{"alg": "hs256", "kid": testKid}, jwtLifetime(), testSecret),
+		"alg HS512":           signJWT(t, map[string]any{"alg": "HS512", "kid": testKid}, jwtLifetime(), testSecret),
+		"HS256 with rsa kid":  signJWT(t, map[string]any{"alg": algHS256, "kid": "rsa"}, jwtLifetime(), testSecret),
+		"RS256 with hmac kid": signJWT(t, map[string]any{"alg": algRS256, "kid": testKid}, jwtLifetime(), testSecret),
+		// Подмена алгоритма: открытый ключ RSA как секрет HS256
+		"HS256 signed by rsa public key": signJWT(t, map[string]any{"alg": algHS256, "kid": "rsa"}, jwtLifetime(), rsaPublic),
+		"HS256 signed by rsa modulus":    signJWT(t, map[string]any{"alg": algHS256}, jwtLifetime(), rsaKey.N.Bytes()),
+		"ES256 with asn.1 signature": signJWTWith(t, map[string]any{"alg": algES256, "kid": "ec"}, jwtLifetime(), func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			signature, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signature
+		}),
+	}
+	for name, token := range tokens {
+		if claims, err := verifier.Verify(token); !errors.Is(err, ErrInvalidJWT) {
+			t.Errorf("Verify() of %s token = %v, %v, want ErrInvalidJWT", name, claims, err)
+		}
+	}
+}
+
+func TestVerifyRejectsKeys(t *testing.T) {
+	verifier := newJWKSVerifier(t, JWTConfig{})
+	_, ecKey := signingKeys(t)
+	otherSecret := []byte("another secret of at least 32 bytes")
+	otherEC, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBy := func(key *ecdsa.PrivateKey) func(signed []byte) []byte {
+		return func(signed []byte) []byte { return signES256(t, key, signed) }
+	}
+	tokens := map[string]string{
+		"unknown kid":           signJWT(t, map[string]any{"alg": algHS256, "kid": "unknown"}, jwtLifetime(), testSecret),
+		"foreign secret":        signJWT(t, map[string]any{"alg": algHS256, "kid": testKid}, jwtLifetime(), otherSecret),
+		"foreign ec key":        signJWTWith(t, map[string]any{"alg": algES256, "kid": "ec"}, jwtLifetime(), signedBy(otherEC)),
+		"ec key of another kid": signJWTWith(t, map[string]any{"alg": algES256, "kid": "rsa"}, jwtLifetime(), signedBy(ecKey)),
+	}
+	for name, token := range tokens {
+		if claims, err := verifier.Verify(token); !errors.Is(err, ErrInvalidJWT) {
+			t.Errorf("Verify() of %s token = %v, %v, want ErrInvalidJWT", name, claims, err)
+		}
+	}
+}
+
+func TestVerifyRejectsMalformedTokens(t *testing.T) {
+	verifier := newJWKSVerifier(t, JWTConfig{})
+	token := testJWT(t, jwtLifetime())
+	forged := withClaims(map[string]any{"sub": "admin"})
+	tokens := map[string]string{
+		"two parts":         token[:strings.LastIndex(token, ".")],
+		"header not base64": tamper(token, 0, "!!!"),
+		"header not json":   tamper(token, 0, b64([]byte("alg"))),
+		"signature not b64": tamper(token, 2, "!!!"),
+		"empty signature":   tamper(token, 2, ""),
+		"claims replaced":   tamper(token, 1, encodeJWTPart(t, forged)),
+	}
+
+	// Подписанный токен с утверждениями не в формате JSON
+	signed := encodeJWTPart(t, map[string]any{"alg": algHS256, "kid": testKid}) + "." + b64([]byte("{"))
+	mac := hmac.New(sha256.New, testSecret)
+	mac.Write([]byte(signed))
+	tokens["claims not json"] = signed + "." + b64(mac.Sum(nil))
+
+	for name, token := range tokens {
+		if claims, err := verifier.Verify(token); !errors.Is(err, ErrInvalidJWT) {
+			t.Errorf("Verify() of token with %s = %v, %v, want ErrInvalidJWT", name, claims, err)
+		}
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := newTestVerifier(JWTConfig{Issuer: "https://idp", Audience: "notes", Leeway: 30 * time.Second})
+	valid := func(extra map[string]any) jwtClaims {
+		claims := jwtClaims{"iss": "https://idp", "aud": "notes", "exp": float64(now.Add(time.Minute).Unix())}
+		for name, value := range extra {
+			claims[name] = value
+		}
+		return claims
+	}
+	accepted := map[string]jwtClaims{
+		"valid":                 valid(nil),
+		"expired within leeway": valid(map[string]any{"exp": float64(now.Add(-20 * time.Second).Unix())}),
+		"nbf within leeway":     valid(map[string]any{"nbf": float64(now.Add(20 * time.Second).Unix())}),
+		"nbf in the past":       valid(map[string]any{"nbf": float64(now.Add(-time.Hour).Unix())}),
+		"aud array":             valid(map[string]any{"aud": []any{"other", "notes"}}),
+	}
+	for name, claims := range accepted {
+		if err := verifier.checkClaims(claims, now); err != nil {
+			t.Errorf("checkClaims() of %s claims: %v", name, err)
+		}
+	}
+
+	missingExp := valid(nil)
+	delete(missingExp, "exp")
+	missingIss := valid(nil)
+	delete(missingIss, "iss")
+	missingAud := valid(nil)
+	delete(missingAud, "aud")
+	rejected := map[string]jwtClaims{
+		"missing exp":             missingExp,
+		"exp as string":           valid(map[string]any{"exp": "1700000060"}),
+		"expired":                 valid(map[string]any{"exp": float64(now.Add(-30 * time.Second).Unix())}),
+		"nbf in the future":       valid(map[string]any{"nbf": float64(now.Add(time.Minute).Unix())}),
+		"nbf as string":           valid(map[string]any{"nbf": "0"}),
+		"missing iss":             missingIss,
+		"foreign iss":             valid(map[string]any{"iss": "https://evil"}),
+		"iss with suffix":         valid(map[string]any{"iss": "https://idp/"}),
+		"missing aud":             missingAud,
+		"foreign aud":             valid(map[string]any{"aud": "other"}),
+		"aud array without notes": valid(map[string]any{"aud": []any{"other", 1}}),
+		"aud as object":           valid(map[string]any{"aud": map[string]any{"notes": true}}),
+	}
+	for name, claims := range rejected {
+		if err := verifier.checkClaims(claims, now); err == nil {
+			t.Errorf("checkClaims() of claims with %s: want error", name)
+		}
+	}
+}
+
+// Verify проверяет утверждения после подписи, и ошибка утверждений - тоже ErrInvalidJWT
+func TestVerifyRejectsClaims(t *testing.T) {
+	verifier := newJWKSVerifier(t, JWTConfig{Issuer: "https://idp", Audience: "notes"})
+	base := map[string]any{"iss": "https://idp", "aud": "notes"}
+	if _, err := verifier.Verify(testJWT(t, withClaims(base))); err != nil {
+		t.Fatalf("Verify() of a valid token: %v", err)
+	}
+	tokens := map[string]map[string]any{
+		"expired":     withClaims(map[string]any{"iss": "https://idp", "aud": "notes", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"foreign iss": withClaims(map[string]any{"iss": "https://evil", "aud": "notes"}),
+		"foreign aud": withClaims(map[string]any{"iss": "https://idp", "aud": "other"}),
+	}
+	for name, claims := range tokens {
+		if _, err := verifier.Verify(testJWT(t, claims)); !errors.Is(err, ErrInvalidJWT) {
+			t.Errorf("Verify() of %s token: %v, want ErrInvalidJWT", name, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Параметры хеширования паролей (PBKDF2-HMAC-SHA256, рекомендации OWASP)
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 600000
+	passwordSaltLength = 16
+	passwordHashLength = 32
+)
+
+// hashPassword возвращает хеш пароля со случайной солью в формате pbkdf2-sha256$<итерации>$<соль>$<хеш>
+// (соль и хеш в base64 без дополнения)
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	hash := pbkdf2([]byte(password), salt, passwordIterations, passwordHashLength)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// verifyPassword проверяет, что пароль соответствует хешу, полученному из hashPassword.
+// Количество итераций берется из хеша, поэтому старые хеши остаются действительными при изменении параметров.
+func verifyPassword(password string, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false, errors.New("unknown password hash format")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false, errors.New("invalid password hash iterations")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, errors.New("invalid password hash salt")
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(hash) == 0 {
+		return false, errors.New("invalid password hash")
+	}
+	actual := pbkdf2([]byte(password), salt, iterations, len(hash))
+	return subtle.ConstantTimeCompare(actual, hash) == 1, nil
+}
+
+// pbkdf2 вычисляет ключ длины keyLength из пароля по алгоритму PBKDF2 (RFC 8018) с HMAC-SHA256
+func pbkdf2(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLength+prf.Size())
+	u := make([]byte, prf.Size())
+	for block := uint32(1); len(key) < keyLength; block++ {
+		// U1 = PRF(пароль, соль || номер блока), Ui = PRF(пароль, Ui-1), блок = U1 xor U2 xor ... xor Un
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLength]
+}
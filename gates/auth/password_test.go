@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Векторы PBKDF2-HMAC-SHA256 в стиле RFC 6070 и из раздела 11 RFC 7914
+func TestPBKDF2Vectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		key            string
+	}{
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+		{"pass\x00word", "sa\x00lt", 4096, "89b69d0516f829893c696226650a8687"},
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		want, err := hex.DecodeString(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := pbkdf2([]byte(test.password), []byte(test.salt), test.iterations, len(want))
+		if hex.EncodeToString(key) != test.key {
+			t.Errorf("pbkdf2(%q, %q, %d) = %x, want %s", test.password, test.salt, test.iterations, key, test.key)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, fmt.Sprintf("%s$%d$", passwordScheme, passwordIterations)) {
+		t.Fatalf("hashPassword() = %q, want %s$%d$...", hash, passwordScheme, passwordIterations)
+	}
+	if strings.Contains(hash, testPassword) {
+		t.Fatalf("hashPassword() = %q contains the password", hash)
+	}
+
+	ok, err := verifyPassword(testPassword, hash)
+	if err != nil || !ok {
+		t.Fatalf("verifyPassword() of the right password = %t, %v", ok, err)
+	}
+	for _, wrong := range []string{"", "Correct horse battery", testPassword + " ", testPassword[:len(testPassword)-1]} {
+		if ok, err = verifyPassword(wrong, hash); err != nil || ok {
+			t.Errorf("verifyPassword(%q) = %t, %v, want false", wrong, ok, err)
+		}
+	}
+
+	// Соль случайная: одинаковые пароли дают разные хеши
+	other, err := hashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Fatalf("hashPassword() returned the same hash twice: %q", hash)
+	}
+}
+
+// Количество итераций берется из хеша: хеши со старыми параметрами остаются действительными
+func TestVerifyPasswordUsesHashIterations(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key := pbkdf2([]byte(testPassword), salt, 1000, passwordHashLength)
+	hash := fmt.Sprintf("%s$1000$%s$%s", passwordScheme,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	if ok, err := verifyPassword(testPassword, hash); err != nil || !ok {
+		t.Fatalf("verifyPassword() with 1000 iterations = %t, %v", ok, err)
+	}
+	if ok, err := verifyPassword("wrong password", hash); err != nil || ok {
+		t.Fatalf("verifyPassword() of a wrong password = %t, %v", ok, err)
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedHashes(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, passwordHashLength))
+	hashes := []string{
+		"",
+		testPassword,
+		"pbkdf2-sha256$1000$" + salt,
+		"pbkdf2-sha1$1000$" + salt + "$" + key,
+		"pbkdf2-sha256$0$" + salt + "$" + key,
+		"pbkdf2-sha256$-1$" + salt + "$" + key,
+		"pbkdf2-sha256$x$" + salt + "$" + key,
+		"pbkdf2-sha256$1000$!!!$" + key,
+		"pbkdf2-sha256$1000$" + salt + "$",
+		"pbkdf2-sha256$1000$" + salt + "$" + key + "$",
+	}
+	for _, hash := range hashes {
+		if ok, err := verifyPassword(testPassword, hash); err == nil || ok {
+			t.Errorf("verifyPassword(%q) = %t, %v, want error", hash, ok, err)
+		}
+	}
+}
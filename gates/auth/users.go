@@ -0,0 +1,494 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/mp"
+	"notesServer/models/entity"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Ограничения учетных данных
+const (
+	minLoginLength    = 3
+	maxLoginLength    = 64
+	minPasswordLength = 8
+	maxPasswordLength = 1024
+	tokenPrefix       = "nt_" // префикс API-токенов, чтобы их было легко найти в логах и конфигурациях
+	tokenBytes        = 32
+)
+
+var (
+	// ErrInvalidLogin ошибка, возвращаемая при регистрации с недопустимым логином
+	ErrInvalidLogin = fmt.Errorf("login must be %d to %d characters: latin letters, digits, '.', '_' or '-'", minLoginLength, maxLoginLength)
+	// ErrWeakPassword ошибка, возвращаемая при регистрации со слишком коротким или длинным паролем
+	ErrWeakPassword = fmt.Errorf("password must be %d to %d characters", minPasswordLength, maxPasswordLength)
+	// ErrLoginTaken ошибка, возвращаемая при регистрации с уже занятым логином
+	ErrLoginTaken = errors.New("login is already taken")
+	// ErrInvalidCredentials ошибка, возвращаемая при входе с неизвестным логином или неверным паролем
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	// ErrInvalidToken ошибка, возвращаемая для неизвестного, отозванного или просроченного токена
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrUnknownUser ошибка, возвращаемая для неизвестного логина или ID пользователя
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrLoginBelongsToAccount ошибка, возвращаемая при первом входе по JWT с логином, уже занятым другой учетной записью
+	ErrLoginBelongsToAccount = errors.New("login belongs to another account")
+	// ErrInvalidGroup ошибка, возвращаемая при назначении группы с недопустимым названием
+	ErrInvalidGroup = fmt.Errorf("group name must be %d to %d characters: latin letters, digits, '.', '_' or '-'", minLoginLength, maxLoginLength)
+)
+
+// Identity - аутентифицированный пользователь
+type Identity struct {
+	ID     int64
+	Login  string
+	Admin  bool
+	Groups []string
+}
+
+// Users - учетные записи пользователей и их API-токены. Записи типа entity.User хранятся в отдельном хранилище,
+// по которому при создании строятся индексы логинов и токенов.
+type Users struct {
+	st        storage.Storage
+	tokenTTL  time.Duration
+	byLogin   map[string]int64 // логин -> ID пользователя
+	byToken   map[string]int64 // хеш токена -> ID пользователя
+	bySubject map[string]int64 // iss и sub пользователя, входящего по JWT (см. subjectKey) -> ID пользователя
+	mu        sync.RWMutex
+}
+
+// dummyHash - хеш, проверяемый при входе с неизвестным логином, чтобы время ответа не выдавало наличие логина
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// NewUsers возвращает учетные записи из хранилища st. Выданные при входе токены действуют в течение tokenTTL.
+func NewUsers(st storage.Storage, tokenTTL time.Duration) (*Users, error) {
+	u := newUsers(st, tokenTTL)
+
+	// Построение индексов по записям хранилища
+	allUsers, _ := st.GetAll()
+	for id, userAny := range allUsers {
+		user, ok := userAny.(entity.User)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value in users storage: %T (id %d)", userAny, id)
+		}
+		u.byLogin[user.Login] = id
+		if user.Subject != "" {
+			u.bySubject[subjectKey(user.Issuer, user.Subject)] = id
+		}
+		for _, token := range user.Tokens {
+			u.byToken[token.Hash] = id
+		}
+	}
+	return u, nil
+}
+
+// NewMemoryUsers возвращает пустые учетные записи, хранящиеся только в памяти
+func NewMemoryUsers(tokenTTL time.Duration) *Users {
+	return newUsers(mp.NewMap(1), tokenTTL)
+}
+
+func newUsers(st storage.Storage, tokenTTL time.Duration) *Users {
+	return &Users{
+		st:        st,
+		tokenTTL:  tokenTTL,
+		byLogin:   make(map[string]int64),
+		byToken:   make(map[string]int64),
+		bySubject: make(map[string]int64),
+	}
+}
+
+// Len возвращает количество зарегистрированных пользователей
+func (u *Users) Len() int64 {
+	return u.st.Len()
+}
+
+// Register создает пользователя с логином login и паролем password и возвращает его данные.
+// Логин приводится к нижнему регистру. Пользователь создается без роли администратора (см. BootstrapAdmin).
+func (u *Users) Register(login string, password string) (Identity, error) {
+	login = strings.ToLower(login)
+	if !validLogin(login) {
+		return Identity{}, ErrInvalidLogin
+	}
+	if length := utf8.RuneCountInString(password); length < minPasswordLength || length > maxPasswordLength {
+		return Identity{}, ErrWeakPassword
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.byLogin[login]; ok {
+		return Identity{}, ErrLoginTaken
+	}
+	id, err := u.st.Add(entity.User{Login: login, PasswordHash: passwordHash, CreatedAt: time.Now()})
+	if err != nil {
+		return Identity{}, err
+	}
+	u.byLogin[login] = id
+	return Identity{ID: id, Login: login}, nil
+}
+
+// BootstrapAdmin назначает администратором пользователя с логином login, задаваемым при запуске сервера.
+// Если такого пользователя нет, он создается с паролем password, иначе пароль не используется.
+// Для отсутствующего пользователя без пароля возвращается ErrUnknownUser.
+func (u *Users) BootstrapAdmin(login string, password string) (Identity, error) {
+	identity, err := u.SetAdmin(login, true)
+	if !errors.Is(err, ErrUnknownUser) || password == "" {
+		return identity, err
+	}
+	_, err = u.Register(login, password)
+	if err != nil && !errors.Is(err, ErrLoginTaken) {
+		return Identity{}, err
+	}
+	return u.SetAdmin(login, true)
+}
+
+// Provision возвращает пользователя внешнего сервера авторизации issuer с идентификатором subject
+// (утверждения iss и sub JWT, см. JWTVerifier.Identify), создавая его без пароля с логином login при первом входе.
+// Учетная запись определяется только парой issuer и subject, а не логином: логин из токена не может совпасть
+// с логином другой учетной записи (зарегистрированной с паролем или другого пользователя JWT), иначе
+// пользователь JWT получил бы ее заметки, группы и роль. При таком совпадении возвращается ErrLoginBelongsToAccount.
+func (u *Users) Provision(issuer string, subject string, login string) (Identity, error) {
+	key := subjectKey(issuer, subject)
+	u.mu.RLock()
+	id, ok := u.bySubject[key]
+	u.mu.RUnlock()
+	if ok {
+		return u.Get(id)
+	}
+
+	login = strings.ToLower(login)
+	if !validLogin(login) {
+		return Identity{}, ErrInvalidLogin
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if id, ok := u.bySubject[key]; ok {
+		return u.identityUnsafely(id)
+	}
+	if _, ok := u.byLogin[login]; ok {
+		return Identity{}, ErrLoginBelongsToAccount
+	}
+	id, err := u.st.Add(entity.User{Login: login, CreatedAt: time.Now(), Issuer: issuer, Subject: subject})
+	if err != nil {
+		return Identity{}, err
+	}
+	u.byLogin[login] = id
+	u.bySubject[key] = id
+	return Identity{ID: id, Login: login}, nil
+}
+
+// subjectKey возвращает ключ индекса bySubject. Длина issuer входит в ключ, чтобы разные пары
+// issuer и subject не давали одинаковых ключей.
+func subjectKey(issuer string, subject string) string {
+	return fmt.Sprintf("%d:%s:%s", len(issuer), issuer, subject)
+}
+
+// Login проверяет логин и пароль и выдает пользователю новый API-токен.
+// Возвращает токен и время окончания его действия. Просроченные токены пользователя при этом удаляются.
+// Пользователи, созданные через Provision, не имеют пароля и входят только с JWT.
+func (u *Users) Login(login string, password string) (string, time.Time, error) {
+	login = strings.ToLower(login)
+
+	// Пароль проверяется без блокировки, так как это долго
+	u.mu.RLock()
+	id, ok := u.byLogin[login]
+	u.mu.RUnlock()
+	user, err := u.get(id)
+	if !ok || err != nil || user.PasswordHash == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = hashPassword("dummy password")
+		})
+		_, _ = verifyPassword(password, dummyHash)
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+	matches, err := verifyPassword(password, user.PasswordHash)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !matches {
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+
+	// Выдача токена
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(u.tokenTTL)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, err = u.get(id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	user.Tokens = u.dropExpiredUnsafely(user.Tokens, now)
+	user.Tokens = append(user.Tokens, entity.Token{Hash: tokenHash, CreatedAt: now, ExpiresAt: expiresAt})
+	err = u.save(id, user)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	u.byToken[tokenHash] = id
+	return token, expiresAt, nil
+}
+
+// Authenticate возвращает пользователя, которому выдан токен.
+// Для неизвестного, отозванного или просроченного токена возвращается ErrInvalidToken.
+func (u *Users) Authenticate(token string) (Identity, error) {
+	tokenHash := hashToken(token)
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	id, ok := u.byToken[tokenHash]
+	if !ok {
+		return Identity{}, ErrInvalidToken
+	}
+	user, err := u.get(id)
+	if err != nil {
+		return Identity{}, err
+	}
+	for _, t := range user.Tokens {
+		if t.Hash == tokenHash && time.Now().Before(t.ExpiresAt) {
+			return identityOf(id, user), nil
+		}
+	}
+	return Identity{}, ErrInvalidToken
+}
+
+// SetAdmin назначает пользователю с логином login роль администратора или снимает ее.
+// Для неизвестного логина возвращается ErrUnknownUser.
+func (u *Users) SetAdmin(login string, admin bool) (Identity, error) {
+	login = strings.ToLower(login)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id, ok := u.byLogin[login]
+	if !ok {
+		return Identity{}, ErrUnknownUser
+	}
+	user, err := u.get(id)
+	if err != nil {
+		return Identity{}, err
+	}
+	user.Admin = admin
+	err = u.save(id, user)
+	if err != nil {
+		return Identity{}, err
+	}
+	return identityOf(id, user), nil
+}
+
+// SetGroups задает группы пользователя с логином login. Названия групп приводятся к нижнему регистру.
+// Для неизвестного логина возвращается ErrUnknownUser.
+func (u *Users) SetGroups(login string, groups []string) (Identity, error) {
+	login = strings.ToLower(login)
+	normalized := make([]string, 0, len(groups))
+	seen := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		group = strings.ToLower(group)
+		if !ValidGroup(group) {
+			return Identity{}, ErrInvalidGroup
+		}
+		if !seen[group] {
+			seen[group] = true
+			normalized = append(normalized, group)
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id, ok := u.byLogin[login]
+	if !ok {
+		return Identity{}, ErrUnknownUser
+	}
+	user, err := u.get(id)
+	if err != nil {
+		return Identity{}, err
+	}
+	user.Groups = normalized
+	err = u.save(id, user)
+	if err != nil {
+		return Identity{}, err
+	}
+	return identityOf(id, user), nil
+}
+
+// Lookup возвращает пользователя по логину. Для неизвестного логина возвращается ErrUnknownUser.
+func (u *Users) Lookup(login string) (Identity, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	id, ok := u.byLogin[strings.ToLower(login)]
+	if !ok {
+		return Identity{}, ErrUnknownUser
+	}
+	return u.identityUnsafely(id)
+}
+
+// Get возвращает пользователя по ID. Для неизвестного ID возвращается ErrUnknownUser.
+func (u *Users) Get(id int64) (Identity, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return u.identityUnsafely(id)
+}
+
+// identityUnsafely возвращает пользователя по ID. Вызывается под u.mu.
+func (u *Users) identityUnsafely(id int64) (Identity, error) {
+	user, err := u.get(id)
+	if err != nil {
+		return Identity{}, ErrUnknownUser
+	}
+	return identityOf(id, user), nil
+}
+
+// Revoke отзывает токен. Для неизвестного токена возвращается ErrInvalidToken.
+func (u *Users) Revoke(token string) error {
+	tokenHash := hashToken(token)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id, ok := u.byToken[tokenHash]
+	if !ok {
+		return ErrInvalidToken
+	}
+	user, err := u.get(id)
+	if err != nil {
+		return err
+	}
+	tokens := make([]entity.Token, 0, len(user.Tokens))
+	for _, t := range user.Tokens {
+		if t.Hash != tokenHash {
+			tokens = append(tokens, t)
+		}
+	}
+	user.Tokens = tokens
+	err = u.save(id, user)
+	if err != nil {
+		return err
+	}
+	delete(u.byToken, tokenHash)
+	return nil
+}
+
+// RevokeAll отзывает все токены пользователя и возвращает их количество
+func (u *Users) RevokeAll(id int64) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, err := u.get(id)
+	if err != nil {
+		return 0, err
+	}
+	revoked := user.Tokens
+	user.Tokens = nil
+	err = u.save(id, user)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range revoked {
+		delete(u.byToken, t.Hash)
+	}
+	return len(revoked), nil
+}
+
+// get возвращает пользователя из хранилища
+func (u *Users) get(id int64) (entity.User, error) {
+	userAny, ok := u.st.GetByID(id)
+	if !ok {
+		return entity.User{}, fmt.Errorf("user %d not found", id)
+	}
+	user, ok := userAny.(entity.User)
+	if !ok {
+		return entity.User{}, errors.New("cannot convert interface{} to User")
+	}
+	return user, nil
+}
+
+// save записывает пользователя в хранилище. Вызывается под u.mu.Lock().
+func (u *Users) save(id int64, user entity.User) error {
+	ok, err := u.st.UpdateByID(id, user)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("user %d not found", id)
+	}
+	return nil
+}
+
+// dropExpiredUnsafely удаляет из tokens просроченные токены вместе с их записями в индексе. Вызывается под u.mu.Lock().
+func (u *Users) dropExpiredUnsafely(tokens []entity.Token, now time.Time) []entity.Token {
+	valid := make([]entity.Token, 0, len(tokens)+1)
+	for _, t := range tokens {
+		if now.Before(t.ExpiresAt) {
+			valid = append(valid, t)
+			continue
+		}
+		delete(u.byToken, t.Hash)
+	}
+	return valid
+}
+
+// identityOf возвращает данные пользователя id для обработчиков запросов
+func identityOf(id int64, user entity.User) Identity {
+	return Identity{ID: id, Login: user.Login, Admin: user.Admin, Groups: user.Groups}
+}
+
+// newToken возвращает новый случайный токен и его хеш
+func newToken() (token string, tokenHash string, err error) {
+	random := make([]byte, tokenBytes)
+	_, err = rand.Read(random)
+	if err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return token, hashToken(token), nil
+}
+
+// hashToken возвращает хеш токена, под которым он хранится. Токены случайны и длинны,
+// поэтому для них, в отличие от паролей, достаточно одного SHA-256.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidGroup проверяет название группы пользователей: те же правила, что и для логина
+func ValidGroup(group string) bool {
+	return validLogin(group)
+}
+
+// validLogin проверяет, что логин состоит из строчных латинских букв, цифр, '.', '_' и '-'
+func validLogin(login string) bool {
+	if len(login) < minLoginLength || len(login) > maxLoginLength {
+		return false
+	}
+	for _, r := range login {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '.' && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct horse battery"
+
+func TestRegisterDoesNotGrantAdmin(t *testing.T) {
+	users := NewMemoryUsers(time.Hour)
+	first, err := users.Register("alice", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Admin {
+		t.Fatalf("Register() of the first user = %+v, want no admin role", first)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	users := NewMemoryUsers(time.Hour)
+
+	// Без пароля отсутствующий пользователь не создается
+	if _, err := users.BootstrapAdmin("root", ""); !errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("BootstrapAdmin() of a missing user without password: %v", err)
+	}
+
+	root, err := users.BootstrapAdmin("root", testPassword)
+	if err != nil || !root.Admin {
+		t.Fatalf("BootstrapAdmin() = %+v, %v", root, err)
+	}
+	if _, _, err = users.Login("root", testPassword); err != nil {
+		t.Fatalf("Login() of the bootstrapped admin: %v", err)
+	}
+
+	// Существующий пользователь получает роль, его пароль не меняется
+	if _, err = users.Register("bob", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.BootstrapAdmin("bob", "another password")
+	if err != nil || !bob.Admin {
+		t.Fatalf("BootstrapAdmin() of an existing user = %+v, %v", bob, err)
+	}
+	if _, _, err = users.Login("bob", testPassword); err != nil {
+		t.Fatalf("Login() with the old password: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package history
+
+import (
+	"notesServer/models/entity"
+	"sync"
+	"time"
+)
+
+// History хранит ревизии заметок.
+// Ревизии нумеруются с 1 отдельно для каждой заметки, последняя ревизия соответствует текущему состоянию заметки.
+// Для каждой заметки хранится не больше maxRevisions последних ревизий, более старые удаляются,
+// но номера оставшихся и новых ревизий не меняются.
+type History struct {
+	revisions    map[int64][]entity.Revision // ID заметки -> ревизии в порядке возрастания номера
+	maxRevisions int
+	mu           sync.RWMutex
+}
+
+// NewHistory создает новую пустую историю ревизий, хранящую не больше maxRevisions последних ревизий каждой заметки.
+// Если maxRevisions < 1, хранится только последняя ревизия.
+func NewHistory(maxRevisions int) *History {
+	return &History{revisions: make(map[int64][]entity.Revision), maxRevisions: max(maxRevisions, 1)}
+}
+
+// Add сохраняет новую ревизию заметки и возвращает ее номер.
+// Если ревизий заметки становится больше maxRevisions, самая старая удаляется.
+func (h *History) Add(id int64, note entity.PureNote, author string) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	revisions := h.revisions[id]
+	number := int64(1)
+	if len(revisions) > 0 {
+		number = revisions[len(revisions)-1].Number + 1
+	}
+	revisions = append(revisions, entity.Revision{
+		Number:    number,
+		Note:      note,
+		Timestamp: time.Now(),
+		Author:    author,
+	})
+	// Массив под срезом перевыделяется по мере добавления, поэтому удаленные ревизии не удерживаются в памяти
+	if len(revisions) > h.maxRevisions {
+		revisions = revisions[len(revisions)-h.maxRevisions:]
+	}
+	h.revisions[id] = revisions
+	return number
+}
+
+// List возвращает все ревизии заметки в порядке возрастания номера.
+// Если у заметки нет ревизий, возвращается nil и false.
+func (h *History) List(id int64) ([]entity.Revision, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revisions, ok := h.revisions[id]
+	if !ok {
+		return nil, false
+	}
+
+	// Создание копии, которую можно будет безопасно использовать после разблокировки h.mu
+	revisionsCopy := make([]entity.Revision, len(revisions))
+	copy(revisionsCopy, revisions)
+	return revisionsCopy, true
+}
+
+// Get возвращает ревизию заметки с указанным номером.
+// Если такой ревизии нет или она уже удалена из-за ограничения maxRevisions, возвращается пустая ревизия и false.
+func (h *History) Get(id int64, number int64) (entity.Revision, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revisions := h.revisions[id]
+	if len(revisions) == 0 {
+		return entity.Revision{}, false
+	}
+	i := number - revisions[0].Number
+	if i < 0 || i >= int64(len(revisions)) {
+		return entity.Revision{}, false
+	}
+	return revisions[i], true
+}
+
+// Remove удаляет всю историю ревизий заметки
+func (h *History) Remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.revisions, id)
+}
@@ -0,0 +1,32 @@
+package history
+
+import (
+	"notesServer/models/entity"
+	"testing"
+)
+
+func TestHistoryKeepsLatestRevisions(t *testing.T) {
+	h := NewHistory(3)
+	for i := 1; i <= 5; i++ {
+		if number := h.Add(1, entity.PureNote{}, "автор"); number != int64(i) {
+			t.Fatalf("Add() = %d, want %d", number, i)
+		}
+	}
+
+	revisions, _ := h.List(1)
+	if len(revisions) != 3 || revisions[0].Number != 3 || revisions[2].Number != 5 {
+		t.Fatalf("List() = %+v, want revisions 3..5", revisions)
+	}
+	for number := int64(1); number <= 6; number++ {
+		revision, ok := h.Get(1, number)
+		if want := number >= 3 && number <= 5; ok != want || (ok && revision.Number != number) {
+			t.Errorf("Get(%d) = %+v %t, want found %t", number, revision, ok, want)
+		}
+	}
+
+	// После удаления истории нумерация начинается заново
+	h.Remove(1)
+	if number := h.Add(1, entity.PureNote{}, "автор"); number != 1 {
+		t.Fatalf("Add() after Remove() = %d, want 1", number)
+	}
+}
@@ -0,0 +1,113 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInProgress ошибка, возвращаемая, если запрос с тем же ключом еще выполняется
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrKeyReused ошибка, возвращаемая, если ключ уже использован для запроса с другим содержимым
+	ErrKeyReused = errors.New("idempotency key has already been used for a different request")
+)
+
+// sweepInterval - минимальный промежуток между удалениями устаревших ключей
+const sweepInterval = time.Minute
+
+// Response - сохраненный ответ на запрос, который возвращается при повторе запроса с тем же ключом
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store хранит ответы на запросы по их ключам идемпотентности в течение заданного времени.
+// Запрос идентифицируется ключом, а его содержимое - отпечатком (например, хешем метода, пути и тела),
+// поэтому повтор с тем же ключом и другим содержимым можно отличить от настоящего повтора.
+type Store struct {
+	ttl       time.Duration
+	entries   map[string]*entry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// entry - запрос с ключом идемпотентности
+type entry struct {
+	fingerprint string
+	response    *Response // nil, пока запрос выполняется
+	expires     time.Time
+}
+
+// NewStore создает пустое хранилище ключей, хранящее ответы в течение ttl после выполнения запроса
+func NewStore(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, entries: make(map[string]*entry), lastSweep: time.Now()}
+}
+
+// Begin отмечает начало выполнения запроса с ключом key и отпечатком fingerprint.
+// Если запрос с этим ключом уже выполнен, возвращается сохраненный ответ.
+// Если ключ новый, возвращается nil и nil; тогда вызывающий должен завершить запрос вызовом Complete или Abort.
+// Если запрос с этим ключом еще выполняется, возвращается ErrInProgress,
+// если ключ использован для запроса с другим отпечатком - ErrKeyReused.
+func (s *Store) Begin(key string, fingerprint string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweepUnsafely(now)
+
+	e, ok := s.entries[key]
+	if ok && e.response != nil && now.After(e.expires) {
+		ok = false // срок хранения истек, но ключ еще не удален
+	}
+	if !ok {
+		s.entries[key] = &entry{fingerprint: fingerprint}
+		return nil, nil
+	}
+	if e.fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if e.response == nil {
+		return nil, ErrInProgress
+	}
+	return e.response, nil
+}
+
+// Complete сохраняет ответ на запрос с ключом key, начатый вызовом Begin
+func (s *Store) Complete(key string, response *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	e.response = response
+	e.expires = time.Now().Add(s.ttl)
+}
+
+// Abort освобождает ключ запроса, начатого вызовом Begin, не сохраняя ответ. Повтор запроса выполнит его заново.
+func (s *Store) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if ok && e.response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// sweepUnsafely удаляет ключи с истекшим сроком хранения не чаще, чем раз в sweepInterval. Вызывается под s.mu.
+func (s *Store) sweepUnsafely(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if e.response != nil && now.After(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
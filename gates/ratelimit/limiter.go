@@ -0,0 +1,236 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval - не чаще этого из лимитера удаляются корзины клиентов, которые успели полностью заполниться
+const sweepInterval = time.Minute
+
+// Limit - ограничение частоты запросов: в среднем Rate запросов в секунду, не более Burst подряд
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ErrInvalidLimit ошибка, возвращаемая при разборе ограничения в неверном формате
+var ErrInvalidLimit = errors.New(`invalid rate limit (need "<count>/<s|m|h>[:<burst>]", e.g. "5/s:20")`)
+
+// ParseLimit разбирает ограничение вида "<количество>/<s|m|h>[:<burst>]", например "5/s:20" или "100/m".
+// Если burst не указан, он равен количеству.
+func ParseLimit(s string) (Limit, error) {
+	rateString, burstString, hasBurst := strings.Cut(s, ":")
+	countString, unit, found := strings.Cut(rateString, "/")
+	if !found {
+		return Limit{}, ErrInvalidLimit
+	}
+	count, err := strconv.Atoi(countString)
+	if err != nil || count < 1 {
+		return Limit{}, ErrInvalidLimit
+	}
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, ErrInvalidLimit
+	}
+	limit := Limit{Rate: float64(count) / per.Seconds(), Burst: count}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burstString)
+		if err != nil || limit.Burst < 1 {
+			return Limit{}, ErrInvalidLimit
+		}
+	}
+	return limit, nil
+}
+
+// ParseRouteLimits разбирает ограничения маршрутов вида "/create=5/s:20,/notes/=50/s,*=100/s".
+// Маршрут, оканчивающийся на '/', ограничивает все пути с этим префиксом, "*" - все остальные маршруты.
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		route, limitString, found := strings.Cut(item, "=")
+		if !found || (route != "*" && !strings.HasPrefix(route, "/")) {
+			return nil, fmt.Errorf("invalid route rate limit %q (need \"<route>=<limit>\")", item)
+		}
+		limit, err := ParseLimit(limitString)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route, err)
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// Limiter ограничивает частоту запросов каждого клиента алгоритмом token bucket:
+// у клиента есть корзина на Burst запросов, которая пополняется со скоростью Rate запросов в секунду.
+type Limiter struct {
+	limit     Limit
+	buckets   map[string]*bucket // ключ клиента -> его корзина
+	allowed   uint64
+	limited   uint64
+	lastSweep time.Time
+	now       func() time.Time // часы лимитера, в тестах подменяются
+	mu        sync.Mutex
+}
+
+// bucket - корзина клиента: количество доступных запросов на момент updatedAt
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Stats - состояние лимитера
+type Stats struct {
+	Rate    float64 // запросов в секунду
+	Burst   int
+	Clients int    // клиенты, для которых хранится корзина
+	Allowed uint64 // пропущенные запросы
+	Limited uint64 // отклоненные запросы
+}
+
+// NewLimiter возвращает лимитер с ограничением limit для каждого клиента
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow расходует один запрос из корзины клиента key. Если корзина пуста, возвращает false
+// и время, через которое в ней появится запрос.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweepUnsafely(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updatedAt: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.updatedAt = now
+	if b.tokens < 1 {
+		l.limited++
+		wait := time.Duration(math.Ceil((1 - b.tokens) / l.limit.Rate * float64(time.Second)))
+		return false, wait
+	}
+	b.tokens--
+	l.allowed++
+	return true, 0
+}
+
+// Stats возвращает состояние лимитера
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweepUnsafely(l.now())
+	return Stats{
+		Rate:    l.limit.Rate,
+		Burst:   l.limit.Burst,
+		Clients: len(l.buckets),
+		Allowed: l.allowed,
+		Limited: l.limited,
+	}
+}
+
+// refill возвращает количество запросов в корзине b на момент now
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*l.limit.Rate)
+}
+
+// sweepUnsafely удаляет полные корзины: такая корзина ничем не отличается от новой. Вызывается под l.mu.
+func (l *Limiter) sweepUnsafely(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Routes - лимитеры маршрутов, каждый со своим ограничением (см. ParseRouteLimits)
+type Routes struct {
+	exact    map[string]*Limiter
+	prefixes []string // маршруты, оканчивающиеся на '/', от длинных к коротким
+	prefixed map[string]*Limiter
+	fallback *Limiter // лимитер "*" (nil - остальные маршруты не ограничены)
+}
+
+// NewRoutes возвращает лимитеры для ограничений маршрутов limits
+func NewRoutes(limits map[string]Limit) *Routes {
+	r := &Routes{
+		exact:    make(map[string]*Limiter),
+		prefixed: make(map[string]*Limiter),
+	}
+	for route, limit := range limits {
+		switch {
+		case route == "*":
+			r.fallback = NewLimiter(limit)
+		case strings.HasSuffix(route, "/"):
+			r.prefixed[route] = NewLimiter(limit)
+			r.prefixes = append(r.prefixes, route)
+		default:
+			r.exact[route] = NewLimiter(limit)
+		}
+	}
+	sort.Slice(r.prefixes, func(i, j int) bool { return len(r.prefixes[i]) > len(r.prefixes[j]) })
+	return r
+}
+
+// For возвращает лимитер пути path и маршрут, которому он принадлежит. Точное совпадение важнее
+// префикса, более длинный префикс - более короткого. Если путь не ограничен, возвращается nil.
+func (r *Routes) For(path string) (*Limiter, string) {
+	if limiter, ok := r.exact[path]; ok {
+		return limiter, path
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return r.prefixed[prefix], prefix
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback, "*"
+	}
+	return nil, ""
+}
+
+// Stats возвращает состояние лимитеров по маршрутам
+func (r *Routes) Stats() map[string]Stats {
+	stats := make(map[string]Stats, len(r.exact)+len(r.prefixed)+1)
+	for route, limiter := range r.exact {
+		stats[route] = limiter.Stats()
+	}
+	for route, limiter := range r.prefixed {
+		stats[route] = limiter.Stats()
+	}
+	if r.fallback != nil {
+		stats["*"] = r.fallback.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock - часы, которые идут только по вызову Advance
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestLimiter возвращает лимитер с ограничением limit, работающий по часам clock
+func newTestLimiter(limit Limit, clock *fakeClock) *Limiter {
+	l := NewLimiter(limit)
+	l.now = clock.Now
+	l.lastSweep = clock.Now()
+	return l
+}
+
+// expectAllow проверяет, что Allow(key) возвращает allowed и время ожидания wait
+func expectAllow(t *testing.T, l *Limiter, key string, allowed bool, wait time.Duration) {
+	t.Helper()
+	gotAllowed, gotWait := l.Allow(key)
+	if gotAllowed != allowed || gotWait != wait {
+		t.Fatalf("Allow(%q) = %t, %v, want %t, %v", key, gotAllowed, gotWait, allowed, wait)
+	}
+}
+
+func TestLimiterTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newTestLimiter(Limit{Rate: 2, Burst: 3}, clock)
+
+	// Полная корзина пропускает Burst запросов подряд
+	for i := 0; i < 3; i++ {
+		expectAllow(t, l, "a", true, 0)
+	}
+	expectAllow(t, l, "a", false, 500*time.Millisecond)
+
+	// Корзина пополняется со скоростью Rate
+	clock.Advance(250 * time.Millisecond)
+	expectAllow(t, l, "a", false, 250*time.Millisecond)
+	clock.Advance(250 * time.Millisecond)
+	expectAllow(t, l, "a", true, 0)
+	expectAllow(t, l, "a", false, 500*time.Millisecond)
+
+	// У каждого клиента своя корзина
+	expectAllow(t, l, "b", true, 0)
+
+	// Корзина не наполняется больше Burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		expectAllow(t, l, "a", true, 0)
+	}
+	expectAllow(t, l, "a", false, 500*time.Millisecond)
+
+	stats := l.Stats()
+	// Корзина b за час наполнилась и удалена: полная корзина ничем не отличается от новой
+	if stats.Allowed != 8 || stats.Limited != 4 || stats.Clients != 1 {
+		t.Fatalf("Stats() = %+v, want 8 allowed, 4 limited, 1 client", stats)
+	}
+}
+
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newTestLimiter(Limit{Rate: 1.0 / 30, Burst: 1}, clock)
+	expectAllow(t, l, "a", true, 0)
+	clock.Advance(sweepInterval - time.Second)
+	expectAllow(t, l, "b", true, 0)
+
+	// Через sweepInterval корзина a полна и удаляется, а корзина b еще не успела наполниться
+	clock.Advance(time.Second)
+	if stats := l.Stats(); stats.Clients != 1 {
+		t.Fatalf("Stats().Clients = %d, want 1", stats.Clients)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit Limit
+		err   error
+	}{
+		{s: "5/s:20", limit: Limit{Rate: 5, Burst: 20}},
+		{s: "120/m", limit: Limit{Rate: 2, Burst: 120}},
+		{s: "36/h:1", limit: Limit{Rate: 0.01, Burst: 1}},
+		{s: "5", err: ErrInvalidLimit},
+		{s: "0/s", err: ErrInvalidLimit},
+		{s: "-1/s", err: ErrInvalidLimit},
+		{s: "5/d", err: ErrInvalidLimit},
+		{s: "5/s:0", err: ErrInvalidLimit},
+		{s: "5/s:x", err: ErrInvalidLimit},
+	}
+	for _, test := range tests {
+		limit, err := ParseLimit(test.s)
+		if !errors.Is(err, test.err) || limit != test.limit {
+			t.Errorf("ParseLimit(%q) = %+v, %v, want %+v, %v", test.s, limit, err, test.limit, test.err)
+		}
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	limits, err := ParseRouteLimits(" /create=5/s:20, /notes/=50/s,,*=100/m ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Limit{
+		"/create": {Rate: 5, Burst: 20},
+		"/notes/": {Rate: 50, Burst: 50},
+		"*":       {Rate: 100.0 / 60, Burst: 100},
+	}
+	if len(limits) != len(want) {
+		t.Fatalf("ParseRouteLimits() = %+v, want %+v", limits, want)
+	}
+	for route, limit := range want {
+		if limits[route] != limit {
+			t.Errorf("ParseRouteLimits()[%q] = %+v, want %+v", route, limits[route], limit)
+		}
+	}
+
+	if limits, err = ParseRouteLimits(""); err != nil || len(limits) != 0 {
+		t.Errorf("ParseRouteLimits(\"\") = %+v, %v, want no limits", limits, err)
+	}
+	for _, s := range []string{"/create", "create=5/s", "/create=5/x"} {
+		if _, err = ParseRouteLimits(s); err == nil {
+			t.Errorf("ParseRouteLimits(%q): want error", s)
+		}
+	}
+	if _, err = ParseRouteLimits("/create=5"); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("ParseRouteLimits(\"/create=5\"): %v, want ErrInvalidLimit", err)
+	}
+}
+
+func TestRoutesFor(t *testing.T) {
+	r := NewRoutes(map[string]Limit{
+		"/notes":       {Rate: 1, Burst: 1},
+		"/notes/":      {Rate: 1, Burst: 1},
+		"/notes/tags/": {Rate: 1, Burst: 1},
+		"*":            {Rate: 1, Burst: 1},
+	})
+	tests := map[string]string{
+		"/notes":        "/notes",
+		"/notes/1":      "/notes/",
+		"/notes/tags/x": "/notes/tags/",
+		"/create":       "*",
+	}
+	for path, route := range tests {
+		if limiter, got := r.For(path); limiter == nil || got != route {
+			t.Errorf("For(%q) = %v, %q, want route %q", path, limiter, got, route)
+		}
+	}
+	if limiter, route := NewRoutes(nil).For("/create"); limiter != nil || route != "" {
+		t.Errorf("For() without limits = %v, %q, want nil", limiter, route)
+	}
+}
@@ -0,0 +1,17 @@
+package search
+
+import (
+	"notesServer/pkg/analysis"
+	"unicode/utf8"
+)
+
+// Analyzer разбивает текст на термины. Один и тот же Analyzer используется для индексации и для разбора запросов.
+type Analyzer func(text string) []analysis.Token
+
+// runeBoundary сдвигает смещение offset в тексте text назад к началу символа
+func runeBoundary(text string, offset int) int {
+	for offset > 0 && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	return offset
+}
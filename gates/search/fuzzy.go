@@ -0,0 +1,276 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// FuzzyIndex - индекс коротких текстов (например, имен авторов) для нечеткого поиска с опечатками.
+// Слова документов разбиваются на триграммы; слова, имеющие общие триграммы со словом запроса,
+// проверяются и ранжируются по расстоянию Левенштейна.
+type FuzzyIndex struct {
+	docs     map[int64][]string             // документ -> различные термины документа
+	words    map[string]map[int64]struct{}  // термин -> документы, содержащие термин
+	trigrams map[string]map[string]struct{} // триграмма -> термины, содержащие триграмму
+	analyzer Analyzer
+	mu       sync.RWMutex
+}
+
+// FuzzyHit - документ, найденный нечетким поиском
+type FuzzyHit struct {
+	ID         int64
+	Distance   int     // сумма расстояний Левенштейна от слов запроса до ближайших слов документа
+	Similarity float64 // средняя триграммная схожесть слов запроса с ближайшими словами документа, от 0 до 1
+}
+
+// NewFuzzyIndex возвращает пустой индекс, разбивающий тексты на термины анализатором analyzer
+func NewFuzzyIndex(analyzer Analyzer) *FuzzyIndex {
+	return &FuzzyIndex{
+		docs:     make(map[int64][]string),
+		words:    make(map[string]map[int64]struct{}),
+		trigrams: make(map[string]map[string]struct{}),
+		analyzer: analyzer,
+	}
+}
+
+// Put индексирует документ с указанным ID, заменяя его предыдущую версию
+func (fx *FuzzyIndex) Put(id int64, text string) {
+	terms := uniqueTerms(fx.analyzer, text)
+
+	fx.mu.Lock()
+	defer fx.mu.Unlock()
+
+	fx.removeUnsafely(id)
+	if len(terms) == 0 {
+		return
+	}
+	fx.docs[id] = terms
+	for _, term := range terms {
+		if fx.words[term] == nil {
+			fx.words[term] = make(map[int64]struct{})
+			for _, trigram := range trigramsOf(term) {
+				if fx.trigrams[trigram] == nil {
+					fx.trigrams[trigram] = make(map[string]struct{})
+				}
+				fx.trigrams[trigram][term] = struct{}{}
+			}
+		}
+		fx.words[term][id] = struct{}{}
+	}
+}
+
+// Remove удаляет документ из индекса. Если документа нет, функция не делает ничего.
+func (fx *FuzzyIndex) Remove(id int64) {
+	fx.mu.Lock()
+	defer fx.mu.Unlock()
+
+	fx.removeUnsafely(id)
+}
+
+// Search возвращает документы, в которых для каждого слова запроса queryText есть слово на расстоянии Левенштейна
+// не больше maxDistance, по возрастанию суммарного расстояния и убыванию схожести.
+// Если maxDistance < 0, допустимое расстояние выбирается по длине слова запроса (см. defaultMaxDistance).
+// Для запроса без слов возвращается ошибка ErrInvalidQuery.
+func (fx *FuzzyIndex) Search(queryText string, maxDistance int) ([]FuzzyHit, error) {
+	queryTerms := uniqueTerms(fx.analyzer, queryText)
+	if len(queryTerms) == 0 {
+		return nil, fmt.Errorf("%w: no search terms", ErrInvalidQuery)
+	}
+
+	fx.mu.RLock()
+	defer fx.mu.RUnlock()
+
+	// Документы, подходящие под все слова запроса
+	var hits map[int64]*FuzzyHit
+	for _, queryTerm := range queryTerms {
+		limit := maxDistance
+		if limit < 0 {
+			limit = defaultMaxDistance(queryTerm)
+		}
+		nearest := fx.nearest(queryTerm, limit)
+		if hits == nil {
+			hits = nearest
+			continue
+		}
+		for id, hit := range hits {
+			match, ok := nearest[id]
+			if !ok {
+				delete(hits, id)
+				continue
+			}
+			hit.Distance += match.Distance
+			hit.Similarity += match.Similarity
+		}
+	}
+
+	// Ранжирование
+	result := make([]FuzzyHit, 0, len(hits))
+	for _, hit := range hits {
+		hit.Similarity /= float64(len(queryTerms))
+		result = append(result, *hit)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		if result[i].Similarity != result[j].Similarity {
+			return result[i].Similarity > result[j].Similarity
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// nearest возвращает для каждого документа ближайшее к queryTerm слово документа на расстоянии не больше limit.
+// Вызывается под fx.mu.
+func (fx *FuzzyIndex) nearest(queryTerm string, limit int) map[int64]*FuzzyHit {
+	// Кандидаты - слова, имеющие с queryTerm общую триграмму
+	candidates := make(map[string]struct{})
+	for _, trigram := range trigramsOf(queryTerm) {
+		for term := range fx.trigrams[trigram] {
+			candidates[term] = struct{}{}
+		}
+	}
+
+	nearest := make(map[int64]*FuzzyHit)
+	for term := range candidates {
+		distance := levenshtein(queryTerm, term, limit)
+		if distance > limit {
+			continue
+		}
+		similarity := trigramSimilarity(queryTerm, term)
+		for id := range fx.words[term] {
+			current, ok := nearest[id]
+			if ok && (current.Distance < distance || (current.Distance == distance && current.Similarity >= similarity)) {
+				continue
+			}
+			nearest[id] = &FuzzyHit{ID: id, Distance: distance, Similarity: similarity}
+		}
+	}
+	return nearest
+}
+
+// removeUnsafely удаляет документ из индекса. Вызывается под fx.mu.Lock().
+func (fx *FuzzyIndex) removeUnsafely(id int64) {
+	terms, ok := fx.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		delete(fx.words[term], id)
+		if len(fx.words[term]) != 0 {
+			continue
+		}
+		// Термин больше не встречается ни в одном документе
+		delete(fx.words, term)
+		for _, trigram := range trigramsOf(term) {
+			delete(fx.trigrams[trigram], term)
+			if len(fx.trigrams[trigram]) == 0 {
+				delete(fx.trigrams, trigram)
+			}
+		}
+	}
+	delete(fx.docs, id)
+}
+
+// uniqueTerms возвращает различные термины текста в порядке первого появления
+func uniqueTerms(analyzer Analyzer, text string) []string {
+	var terms []string
+	seen := make(map[string]struct{})
+	for _, token := range analyzer(text) {
+		if _, ok := seen[token.Term]; ok {
+			continue
+		}
+		seen[token.Term] = struct{}{}
+		terms = append(terms, token.Term)
+	}
+	return terms
+}
+
+// defaultMaxDistance возвращает допустимое количество опечаток в слове: в коротких словах меньше, в длинных больше
+func defaultMaxDistance(term string) int {
+	switch length := utf8.RuneCountInString(term); {
+	case length <= 2:
+		return 0
+	case length <= 5:
+		return 1
+	case length <= 10:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// trigramsOf возвращает различные триграммы слова, дополненного двумя пробелами в начале и одним в конце,
+// чтобы начало слова давало больше триграмм, чем середина ("ivan" -> "  i", " iv", "iva", "van", "an ")
+func trigramsOf(term string) []string {
+	runes := []rune("  " + term + " ")
+	trigrams := make([]string, 0, len(runes)-2)
+	seen := make(map[string]struct{}, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if _, ok := seen[trigram]; ok {
+			continue
+		}
+		seen[trigram] = struct{}{}
+		trigrams = append(trigrams, trigram)
+	}
+	return trigrams
+}
+
+// trigramSimilarity возвращает отношение количества общих триграмм слов к количеству всех их триграмм
+func trigramSimilarity(a, b string) float64 {
+	aTrigrams := trigramsOf(a)
+	bTrigrams := make(map[string]struct{})
+	for _, trigram := range trigramsOf(b) {
+		bTrigrams[trigram] = struct{}{}
+	}
+	common := 0
+	for _, trigram := range aTrigrams {
+		if _, ok := bTrigrams[trigram]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(aTrigrams)+len(bTrigrams)-common)
+}
+
+// levenshtein возвращает расстояние Левенштейна между словами (количество вставок, удалений и замен символов).
+// Если расстояние больше limit, возвращается limit+1 без полного вычисления.
+func levenshtein(a, b string, limit int) int {
+	ar, br := []rune(a), []rune(b)
+	if abs(len(ar)-len(br)) > limit {
+		return limit + 1
+	}
+
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(br); j++ {
+			substitution := previous[j-1]
+			if ar[i-1] != br[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return min(previous[len(br)], limit+1)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
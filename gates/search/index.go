@@ -0,0 +1,246 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Параметры ранжирования BM25
+const (
+	bm25K1 = 1.2  // насыщение частоты термина
+	bm25B  = 0.75 // влияние длины документа
+)
+
+// fieldGap - промежуток номеров позиций между полями документа, чтобы фраза не совпадала на стыке полей
+const fieldGap = 1000
+
+// Index - инвертированный индекс документов, состоящих из именованных текстовых полей.
+// Поддерживает запросы с AND, OR и фразами и ранжирует результаты по BM25.
+type Index struct {
+	docs        map[int64]*document
+	postings    map[string]map[int64]struct{} // термин -> документы, содержащие термин
+	totalLength int                           // сумма длин всех документов в терминах
+	analyzer    Analyzer
+	mu          sync.RWMutex
+}
+
+// document - проиндексированный документ
+type document struct {
+	fields    map[string]string // исходный текст полей (для фрагментов)
+	positions map[string][]int  // термин -> позиции в документе по возрастанию
+	length    int               // количество терминов в документе
+}
+
+// Hit - найденный документ
+type Hit struct {
+	ID       int64
+	Score    float64           // релевантность по BM25
+	Snippets map[string]string // поле -> фрагмент текста с выделенными совпадениями
+}
+
+// NewIndex возвращает пустой индекс, разбивающий тексты на термины анализатором analyzer
+func NewIndex(analyzer Analyzer) *Index {
+	return &Index{
+		docs:     make(map[int64]*document),
+		postings: make(map[string]map[int64]struct{}),
+		analyzer: analyzer,
+	}
+}
+
+// Len возвращает количество документов в индексе
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Put индексирует документ с указанным ID, заменяя его предыдущую версию
+func (ix *Index) Put(id int64, fields map[string]string) {
+	// Анализ текста производится до блокировки
+	doc := &document{fields: make(map[string]string, len(fields)), positions: make(map[string][]int)}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	offset := 0
+	for _, name := range names {
+		doc.fields[name] = fields[name]
+		tokens := ix.analyzer(fields[name])
+		for _, token := range tokens {
+			doc.positions[token.Term] = append(doc.positions[token.Term], offset+token.Position)
+		}
+		doc.length += len(tokens)
+		offset += len(tokens) + fieldGap
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeUnsafely(id)
+	ix.docs[id] = doc
+	ix.totalLength += doc.length
+	for term := range doc.positions {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[int64]struct{})
+		}
+		ix.postings[term][id] = struct{}{}
+	}
+}
+
+// Remove удаляет документ из индекса. Если документа нет, функция не делает ничего.
+func (ix *Index) Remove(id int64) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeUnsafely(id)
+}
+
+// Clear удаляет все документы из индекса
+func (ix *Index) Clear() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.docs = make(map[int64]*document)
+	ix.postings = make(map[string]map[int64]struct{})
+	ix.totalLength = 0
+}
+
+// Search возвращает не более limit документов, подходящих под запрос queryText, по убыванию релевантности,
+// и общее количество подходящих документов. Если limit <= 0, количество результатов не ограничивается.
+// Если accept не nil, учитываются только документы, для ID которых он возвращает true.
+// Синтаксис запроса описан в parseQuery. Для неверного запроса возвращается ошибка ErrInvalidQuery.
+func (ix *Index) Search(queryText string, limit int, accept func(id int64) bool) ([]Hit, int, error) {
+	q, err := parseQuery(queryText, ix.analyzer)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Отбор документов и терминов, по которым они совпали
+	matchedTerms := make(map[int64]map[string]struct{})
+	for _, group := range q.groups {
+		for id := range ix.candidates(group) {
+			if !ix.matchesGroup(ix.docs[id], group) || (accept != nil && !accept(id)) {
+				continue
+			}
+			if matchedTerms[id] == nil {
+				matchedTerms[id] = make(map[string]struct{})
+			}
+			for _, p := range group {
+				for _, term := range p {
+					matchedTerms[id][term] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// Ранжирование
+	hits := make([]Hit, 0, len(matchedTerms))
+	for id, terms := range matchedTerms {
+		hits = append(hits, Hit{ID: id, Score: ix.score(ix.docs[id], terms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	total := len(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	// Фрагменты с выделенными совпадениями (только для возвращаемых документов)
+	for i := range hits {
+		hits[i].Snippets = ix.snippets(ix.docs[hits[i].ID], matchedTerms[hits[i].ID])
+	}
+	return hits, total, nil
+}
+
+// candidates возвращает документы, содержащие самый редкий термин группы. Вызывается под ix.mu.
+func (ix *Index) candidates(group []phrase) map[int64]struct{} {
+	var rarest map[int64]struct{}
+	for i, p := range group {
+		for j, term := range p {
+			docs := ix.postings[term]
+			if (i == 0 && j == 0) || len(docs) < len(rarest) {
+				rarest = docs
+			}
+		}
+	}
+	return rarest
+}
+
+// matchesGroup проверяет, что документ содержит все фразы группы. Вызывается под ix.mu.
+func (ix *Index) matchesGroup(doc *document, group []phrase) bool {
+	for _, p := range group {
+		if !doc.containsPhrase(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPhrase проверяет, что термины фразы идут в документе подряд
+func (doc *document) containsPhrase(p phrase) bool {
+	firstPositions, ok := doc.positions[p[0]]
+	if !ok {
+		return false
+	}
+	for _, start := range firstPositions {
+		matched := true
+		for k := 1; k < len(p) && matched; k++ {
+			matched = containsInt(doc.positions[p[k]], start+k)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// containsInt проверяет наличие value в отсортированном срезе values
+func containsInt(values []int, value int) bool {
+	i := sort.SearchInts(values, value)
+	return i < len(values) && values[i] == value
+}
+
+// score вычисляет релевантность документа по BM25 для совпавших терминов. Вызывается под ix.mu.
+func (ix *Index) score(doc *document, terms map[string]struct{}) float64 {
+	docsCount := float64(len(ix.docs))
+	averageLength := float64(ix.totalLength) / docsCount
+	if averageLength == 0 {
+		averageLength = 1
+	}
+
+	score := 0.0
+	for term := range terms {
+		docFrequency := float64(len(ix.postings[term]))
+		idf := math.Log(1 + (docsCount-docFrequency+0.5)/(docFrequency+0.5))
+		termFrequency := float64(len(doc.positions[term]))
+		score += idf * termFrequency * (bm25K1 + 1) /
+			(termFrequency + bm25K1*(1-bm25B+bm25B*float64(doc.length)/averageLength))
+	}
+	return score
+}
+
+// removeUnsafely удаляет документ из индекса. Вызывается под ix.mu.Lock().
+func (ix *Index) removeUnsafely(id int64) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for term := range doc.positions {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.totalLength -= doc.length
+	delete(ix.docs, id)
+}
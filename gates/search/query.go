@@ -0,0 +1,98 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// ErrInvalidQuery ошибка, возвращаемая при разборе синтаксически неверного запроса
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// query - разобранный поисковый запрос: документ подходит, если он подходит хотя бы под одну группу,
+// а под группу - если содержит все ее фразы
+type query struct {
+	groups [][]phrase
+}
+
+// phrase - последовательность терминов, которые должны идти в документе подряд (один термин - отдельное слово)
+type phrase []string
+
+// parseQuery разбирает поисковый запрос вида:
+//
+//	заметка друг           - документы со всеми словами (AND)
+//	заметка OR записка     - документы с любым из слов (AND связывает сильнее, чем OR)
+//	"привет друг" заметка  - документы с фразой (слова подряд) и словом
+//
+// Слова разбиваются на термины тем же анализатором, что и документы.
+func parseQuery(text string, analyzer Analyzer) (query, error) {
+	q := query{}
+	var group []phrase
+	orPending := false // последним было слово OR
+
+	closeGroup := func() error {
+		if len(group) == 0 {
+			return fmt.Errorf("%w: OR without terms on both sides", ErrInvalidQuery)
+		}
+		q.groups = append(q.groups, group)
+		group = nil
+		return nil
+	}
+	addPhrase := func(raw string) {
+		var terms phrase
+		for _, token := range analyzer(raw) {
+			terms = append(terms, token.Term)
+		}
+		if len(terms) != 0 {
+			group = append(group, terms)
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return query{}, fmt.Errorf("%w: unclosed quote", ErrInvalidQuery)
+			}
+			addPhrase(string(runes[i+1 : end]))
+			orPending = false
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			switch word {
+			case "OR":
+				if err := closeGroup(); err != nil {
+					return query{}, err
+				}
+				orPending = true
+			case "AND":
+			default:
+				addPhrase(word)
+				orPending = false
+			}
+			i = end
+		}
+	}
+
+	if orPending {
+		return query{}, fmt.Errorf("%w: OR without terms on both sides", ErrInvalidQuery)
+	}
+	if len(group) != 0 {
+		q.groups = append(q.groups, group)
+	}
+	if len(q.groups) == 0 {
+		return query{}, fmt.Errorf("%w: no search terms", ErrInvalidQuery)
+	}
+	return q, nil
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"notesServer/pkg/analysis"
+	"strings"
+)
+
+// Параметры фрагментов текста
+const (
+	snippetRadius  = 60 // примерное количество байт текста до первого совпадения во фрагменте
+	snippetLength  = 160
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+	ellipsis       = "…"
+)
+
+// snippets возвращает для каждого поля документа, содержащего совпавшие термины,
+// фрагмент текста вокруг первого совпадения с выделенными совпадениями. Вызывается под ix.mu.
+func (ix *Index) snippets(doc *document, terms map[string]struct{}) map[string]string {
+	snippets := make(map[string]string)
+	for name, text := range doc.fields {
+		var matches []analysis.Token
+		for _, token := range ix.analyzer(text) {
+			if _, ok := terms[token.Term]; ok {
+				matches = append(matches, token)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		snippets[name] = highlight(text, matches)
+	}
+	return snippets
+}
+
+// highlight выделяет совпадения matches в окне текста text вокруг первого совпадения
+func highlight(text string, matches []analysis.Token) string {
+	// Границы окна не должны разрезать символ или совпадение
+	start := 0
+	if matches[0].Start > snippetRadius {
+		start = runeBoundary(text, matches[0].Start-snippetRadius)
+	}
+	end := len(text)
+	if end-start > snippetLength {
+		end = max(runeBoundary(text, start+snippetLength), matches[0].End)
+	}
+
+	builder := strings.Builder{}
+	if start > 0 {
+		builder.WriteString(ellipsis)
+	}
+	position := start
+	for _, match := range matches {
+		if match.Start < position || match.End > end {
+			continue
+		}
+		builder.WriteString(text[position:match.Start])
+		builder.WriteString(highlightOpen)
+		builder.WriteString(text[match.Start:match.End])
+		builder.WriteString(highlightClose)
+		position = match.End
+	}
+	builder.WriteString(text[position:end])
+	if end < len(text) {
+		builder.WriteString(ellipsis)
+	}
+	return builder.String()
+}
@@ -0,0 +1,269 @@
+package sharing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/mp"
+	"notesServer/models/entity"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Параметры публичных ссылок
+const (
+	linkSecretBytes = 32
+	linkNonceBytes  = 16
+)
+
+// Ошибки проверки публичной ссылки
+var (
+	ErrLinkMalformed = errors.New("malformed link")
+	ErrLinkInvalid   = errors.New("invalid or revoked link")
+	ErrLinkExpired   = errors.New("link has expired")
+	ErrLinkUsed      = errors.New("one-time link has already been used")
+)
+
+// Link - публичная ссылка на чтение заметки без учетной записи. Ссылка подписана HMAC-SHA256
+// секретом заметки, действует до ExpiresAt и, если задан Nonce, открывается только один раз.
+type Link struct {
+	NoteID    int64
+	ExpiresAt time.Time
+	Nonce     string // пустой для многоразовой ссылки
+	Signature string
+}
+
+// Query возвращает параметры URL ссылки: ?expires=<unix-время>[&nonce=...]&sig=...
+func (l Link) Query() url.Values {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(l.ExpiresAt.Unix(), 10))
+	if l.Nonce != "" {
+		query.Set("nonce", l.Nonce)
+	}
+	query.Set("sig", l.Signature)
+	return query
+}
+
+// ParseLink возвращает ссылку на заметку noteID по параметрам ее URL (см. Link.Query)
+func ParseLink(noteID int64, query url.Values) (Link, error) {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return Link{}, fmt.Errorf("%w: invalid expires parameter", ErrLinkMalformed)
+	}
+	if query.Get("sig") == "" {
+		return Link{}, fmt.Errorf("%w: missing sig parameter", ErrLinkMalformed)
+	}
+	return Link{
+		NoteID:    noteID,
+		ExpiresAt: time.Unix(expires, 0),
+		Nonce:     query.Get("nonce"),
+		Signature: query.Get("sig"),
+	}, nil
+}
+
+// Links - секреты публичных ссылок на заметки. Записи типа entity.LinkSecret хранятся в отдельном
+// хранилище, по одной на заметку; секрет создается при выдаче первой ссылки.
+type Links struct {
+	st     storage.Storage
+	byNote map[int64]int64 // ID заметки -> ID записи секрета
+	mu     sync.Mutex
+}
+
+// NewLinks возвращает секреты публичных ссылок из хранилища st
+func NewLinks(st storage.Storage) (*Links, error) {
+	l := newLinks(st)
+
+	// Построение индекса по записям хранилища
+	allSecrets, _ := st.GetAll()
+	for id, secretAny := range allSecrets {
+		secret, ok := secretAny.(entity.LinkSecret)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value in links storage: %T (id %d)", secretAny, id)
+		}
+		l.byNote[secret.NoteID] = id
+	}
+	return l, nil
+}
+
+// NewMemoryLinks возвращает пустые секреты публичных ссылок, хранящиеся только в памяти
+func NewMemoryLinks() *Links {
+	return newLinks(mp.NewMap(1))
+}
+
+func newLinks(st storage.Storage) *Links {
+	return &Links{
+		st:     st,
+		byNote: make(map[int64]int64),
+	}
+}
+
+// Create выдает ссылку на заметку noteID, действующую ttl. Если once, ссылка одноразовая.
+func (l *Links) Create(noteID int64, ttl time.Duration, once bool) (Link, error) {
+	if ttl <= 0 {
+		return Link{}, errors.New("link ttl must be positive")
+	}
+	link := Link{NoteID: noteID, ExpiresAt: time.Now().Add(ttl).Truncate(time.Second)}
+	if once {
+		nonce, err := randomString(linkNonceBytes)
+		if err != nil {
+			return Link{}, err
+		}
+		link.Nonce = nonce
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	secret, id, err := l.get(noteID)
+	if err != nil {
+		return Link{}, err
+	}
+	if id == 0 {
+		secret, err = newLinkSecret(noteID)
+		if err != nil {
+			return Link{}, err
+		}
+		id, err = l.st.Add(secret)
+		if err != nil {
+			return Link{}, err
+		}
+		l.byNote[noteID] = id
+	}
+	link.Signature = sign(secret.Secret, link)
+	return link, nil
+}
+
+// Use проверяет подпись и срок действия ссылки. Одноразовая ссылка при этом помечается использованной.
+func (l *Links) Use(link Link) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	secret, id, err := l.get(link.NoteID)
+	if err != nil {
+		return err
+	}
+	if id == 0 || !hmac.Equal([]byte(sign(secret.Secret, link)), []byte(link.Signature)) {
+		return ErrLinkInvalid
+	}
+	now := time.Now()
+	if !now.Before(link.ExpiresAt) {
+		return ErrLinkExpired
+	}
+	if link.Nonce == "" {
+		return nil
+	}
+	if _, used := secret.Used[link.Nonce]; used {
+		return ErrLinkUsed
+	}
+
+	// Отметка одноразовой ссылки с удалением истекших отметок. Отметки меняются в копии: карта
+	// принадлежит записи хранилища, и при ошибке сохранения ссылка не должна оказаться использованной
+	used := make(map[string]time.Time, len(secret.Used)+1)
+	for nonce, expiresAt := range secret.Used {
+		if now.Before(expiresAt) {
+			used[nonce] = expiresAt
+		}
+	}
+	used[link.Nonce] = link.ExpiresAt
+	secret.Used = used
+	ok, err := l.st.UpdateByID(id, secret)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("link secret %d not found", id)
+	}
+	return nil
+}
+
+// Rotate заменяет секрет заметки noteID, отзывая все выданные на нее ссылки
+func (l *Links) Rotate(noteID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, id, err := l.get(noteID)
+	if err != nil || id == 0 {
+		return err
+	}
+	secret, err := newLinkSecret(noteID)
+	if err != nil {
+		return err
+	}
+	ok, err := l.st.UpdateByID(id, secret)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("link secret %d not found", id)
+	}
+	return nil
+}
+
+// RemoveNote удаляет секрет заметки noteID (вызывается при ее удалении)
+func (l *Links) RemoveNote(noteID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if id, ok := l.byNote[noteID]; ok {
+		l.st.RemoveByID(id)
+		delete(l.byNote, noteID)
+	}
+}
+
+// get возвращает секрет заметки noteID и ID его записи в хранилище. Если секрета нет, ID равен 0.
+func (l *Links) get(noteID int64) (entity.LinkSecret, int64, error) {
+	id, ok := l.byNote[noteID]
+	if !ok {
+		return entity.LinkSecret{}, 0, nil
+	}
+	secretAny, ok := l.st.GetByID(id)
+	if !ok {
+		return entity.LinkSecret{}, 0, fmt.Errorf("link secret %d not found", id)
+	}
+	secret, ok := secretAny.(entity.LinkSecret)
+	if !ok {
+		return entity.LinkSecret{}, 0, errors.New("cannot convert interface{} to LinkSecret")
+	}
+	if secret.Used == nil {
+		secret.Used = make(map[string]time.Time)
+	}
+	return secret, id, nil
+}
+
+// newLinkSecret возвращает новый случайный секрет заметки noteID
+func newLinkSecret(noteID int64) (entity.LinkSecret, error) {
+	secret := make([]byte, linkSecretBytes)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return entity.LinkSecret{}, err
+	}
+	return entity.LinkSecret{
+		NoteID:    noteID,
+		Secret:    secret,
+		RotatedAt: time.Now(),
+		Used:      make(map[string]time.Time),
+	}, nil
+}
+
+// sign возвращает подпись ссылки: HMAC-SHA256 от ID заметки, времени истечения и nonce в base64 без дополнения
+func sign(secret []byte, link Link) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = fmt.Fprintf(mac, "%d\n%d\n%s", link.NoteID, link.ExpiresAt.Unix(), link.Nonce)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomString возвращает n случайных байт в base64 без дополнения
+func randomString(n int) (string, error) {
+	random := make([]byte, n)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
@@ -0,0 +1,183 @@
+package sharing
+
+import (
+	"errors"
+	"net/url"
+	"notesServer/gates/storage/faulty"
+	"notesServer/gates/storage/mp"
+	"testing"
+	"time"
+)
+
+// createLink выдает ссылку на заметку noteID, действующую час
+func createLink(t *testing.T, links *Links, noteID int64, once bool) Link {
+	t.Helper()
+	link, err := links.Create(noteID, time.Hour, once)
+	if err != nil {
+		t.Fatalf("Create(%d): %v", noteID, err)
+	}
+	return link
+}
+
+// expectUse проверяет, что Use(link) возвращает ошибку want
+func expectUse(t *testing.T, links *Links, link Link, want error) {
+	t.Helper()
+	if err := links.Use(link); !errors.Is(err, want) {
+		t.Fatalf("Use(%+v): %v, want %v", link, err, want)
+	}
+}
+
+func TestLinkQueryRoundTrip(t *testing.T) {
+	links := NewMemoryLinks()
+	for _, once := range []bool{false, true} {
+		link := createLink(t, links, 1, once)
+		parsed, err := ParseLink(1, link.Query())
+		if err != nil {
+			t.Fatalf("ParseLink(%v): %v", link.Query(), err)
+		}
+		if parsed != link {
+			t.Fatalf("ParseLink() = %+v, want %+v", parsed, link)
+		}
+		expectUse(t, links, parsed, nil)
+	}
+}
+
+func TestParseLinkMalformed(t *testing.T) {
+	queries := []string{"", "sig=abc", "expires=soon&sig=abc", "expires=1700000000", "expires=1700000000&sig="}
+	for _, query := range queries {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ParseLink(1, values); !errors.Is(err, ErrLinkMalformed) {
+			t.Errorf("ParseLink(%q): %v, want ErrLinkMalformed", query, err)
+		}
+	}
+}
+
+// Подпись покрывает заметку, срок действия и nonce: измененная ссылка недействительна
+func TestTamperedLinksAreInvalid(t *testing.T) {
+	links := NewMemoryLinks()
+	multi := createLink(t, links, 1, false)
+	once := createLink(t, links, 1, true)
+	createLink(t, links, 2, false)
+
+	otherNote := multi
+	otherNote.NoteID = 2
+	expectUse(t, links, otherNote, ErrLinkInvalid)
+
+	noSecret := multi
+	noSecret.NoteID = 3
+	expectUse(t, links, noSecret, ErrLinkInvalid)
+
+	extended := multi
+	extended.ExpiresAt = extended.ExpiresAt.Add(24 * time.Hour)
+	expectUse(t, links, extended, ErrLinkInvalid)
+
+	// Без nonce одноразовая ссылка стала бы многоразовой
+	reusable := once
+	reusable.Nonce = ""
+	expectUse(t, links, reusable, ErrLinkInvalid)
+
+	otherNonce := once
+	otherNonce.Nonce = "AAAAAAAAAAAAAAAAAAAAAA"
+	expectUse(t, links, otherNonce, ErrLinkInvalid)
+
+	forged := multi
+	forged.Signature = once.Signature
+	expectUse(t, links, forged, ErrLinkInvalid)
+
+	truncated := multi
+	truncated.Signature = multi.Signature[:len(multi.Signature)-1]
+	expectUse(t, links, truncated, ErrLinkInvalid)
+
+	// Исходные ссылки по-прежнему действительны
+	expectUse(t, links, multi, nil)
+	expectUse(t, links, once, nil)
+}
+
+func TestExpiredLink(t *testing.T) {
+	links := NewMemoryLinks()
+	createLink(t, links, 1, false)
+
+	// Ссылка, подписанная секретом заметки, но уже истекшая
+	secret, _, err := links.get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := Link{NoteID: 1, ExpiresAt: time.Now().Add(-time.Second).Truncate(time.Second)}
+	expired.Signature = sign(secret.Secret, expired)
+	expectUse(t, links, expired, ErrLinkExpired)
+
+	if _, err = links.Create(1, 0, false); err == nil {
+		t.Fatal("Create() with zero ttl: want error")
+	}
+}
+
+func TestOneTimeLink(t *testing.T) {
+	links := NewMemoryLinks()
+	once := createLink(t, links, 1, true)
+	expectUse(t, links, once, nil)
+	expectUse(t, links, once, ErrLinkUsed)
+
+	// Другие одноразовые ссылки той же заметки не затронуты, а многоразовая открывается сколько угодно раз
+	expectUse(t, links, createLink(t, links, 1, true), nil)
+	multi := createLink(t, links, 1, false)
+	expectUse(t, links, multi, nil)
+	expectUse(t, links, multi, nil)
+}
+
+func TestRotateRevokesLinks(t *testing.T) {
+	links := NewMemoryLinks()
+	before := createLink(t, links, 1, false)
+	other := createLink(t, links, 2, false)
+	if err := links.Rotate(1); err != nil {
+		t.Fatal(err)
+	}
+	expectUse(t, links, before, ErrLinkInvalid)
+	expectUse(t, links, other, nil)
+	expectUse(t, links, createLink(t, links, 1, false), nil)
+
+	// Заметка без ссылок
+	if err := links.Rotate(3); err != nil {
+		t.Fatalf("Rotate() of a note without links: %v", err)
+	}
+
+	links.RemoveNote(2)
+	expectUse(t, links, other, ErrLinkInvalid)
+}
+
+// Секреты и отметки одноразовых ссылок сохраняются в хранилище и переживают перезапуск
+func TestLinksReload(t *testing.T) {
+	st := mp.NewMap(1)
+	links, err := NewLinks(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi := createLink(t, links, 1, false)
+	once := createLink(t, links, 1, true)
+	expectUse(t, links, once, nil)
+
+	reloaded, err := NewLinks(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectUse(t, reloaded, multi, nil)
+	expectUse(t, reloaded, once, ErrLinkUsed)
+}
+
+// Если отметку одноразовой ссылки не удалось сохранить, ссылка остается неиспользованной
+func TestOneTimeLinkFailedSave(t *testing.T) {
+	st := faulty.NewFaulty(mp.NewMap(1), 1)
+	links, err := NewLinks(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	once := createLink(t, links, 1, true)
+	if err = st.SetFault(faulty.MethodUpdateByID, faulty.Fault{Schedule: []int{1}, Fail: true}); err != nil {
+		t.Fatal(err)
+	}
+	expectUse(t, links, once, faulty.ErrInjected)
+	expectUse(t, links, once, nil)
+	expectUse(t, links, once, ErrLinkUsed)
+}
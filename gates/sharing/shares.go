@@ -0,0 +1,242 @@
+package sharing
+
+import (
+	"errors"
+	"fmt"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/mp"
+	"notesServer/models/entity"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Permission - право доступа к чужой заметке
+type Permission string
+
+const (
+	PermissionNone Permission = ""     // право не выдано
+	PermissionRead Permission = "read" // чтение заметки и ее истории
+	PermissionEdit Permission = "edit" // чтение и изменение заметки
+)
+
+// ErrInvalidPermission ошибка, возвращаемая при выдаче неизвестного права
+var ErrInvalidPermission = fmt.Errorf("invalid permission (need %q or %q)", PermissionRead, PermissionEdit)
+
+// ParsePermission возвращает право по его названию
+func ParsePermission(s string) (Permission, error) {
+	switch Permission(s) {
+	case PermissionRead, PermissionEdit:
+		return Permission(s), nil
+	default:
+		return PermissionNone, ErrInvalidPermission
+	}
+}
+
+// Allows проверяет, что право p включает право required: право на изменение включает право на чтение
+func (p Permission) Allows(required Permission) bool {
+	switch required {
+	case PermissionNone:
+		return true
+	case PermissionRead:
+		return p == PermissionRead || p == PermissionEdit
+	default:
+		return p == required
+	}
+}
+
+// Subject - пользователь или группа, которой выдается право. Задано ровно одно из полей.
+type Subject struct {
+	UserID int64
+	Group  string
+}
+
+// Share - выданное право вместе с ID его записи в хранилище
+type Share struct {
+	ID int64
+	entity.Share
+}
+
+// Shares - права доступа к заметкам, выданные их владельцами. Записи типа entity.Share хранятся в отдельном
+// хранилище, по которому при создании строится индекс по заметкам.
+type Shares struct {
+	st     storage.Storage
+	byNote map[int64][]int64 // ID заметки -> ID записей прав
+	mu     sync.RWMutex
+}
+
+// NewShares возвращает права доступа из хранилища st
+func NewShares(st storage.Storage) (*Shares, error) {
+	s := newShares(st)
+
+	// Построение индекса по записям хранилища
+	allShares, _ := st.GetAll()
+	for id, shareAny := range allShares {
+		share, ok := shareAny.(entity.Share)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value in shares storage: %T (id %d)", shareAny, id)
+		}
+		s.byNote[share.NoteID] = append(s.byNote[share.NoteID], id)
+	}
+	return s, nil
+}
+
+// NewMemoryShares возвращает пустые права доступа, хранящиеся только в памяти
+func NewMemoryShares() *Shares {
+	return newShares(mp.NewMap(1))
+}
+
+func newShares(st storage.Storage) *Shares {
+	return &Shares{
+		st:     st,
+		byNote: make(map[int64][]int64),
+	}
+}
+
+// Grant выдает субъекту subject право permission на заметку noteID или изменяет уже выданное.
+// Возвращает запись права и true, если право выдано впервые.
+func (s *Shares) Grant(noteID int64, subject Subject, permission Permission, grantedBy int64) (Share, bool, error) {
+	if permission != PermissionRead && permission != PermissionEdit {
+		return Share{}, false, ErrInvalidPermission
+	}
+	if (subject.UserID == 0) == (subject.Group == "") {
+		return Share{}, false, errors.New("share subject must be either a user or a group")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Изменение уже выданного права
+	for _, id := range s.byNote[noteID] {
+		share, err := s.get(id)
+		if err != nil {
+			return Share{}, false, err
+		}
+		if share.UserID != subject.UserID || share.Group != subject.Group {
+			continue
+		}
+		share.Permission = string(permission)
+		share.GrantedBy = grantedBy
+		ok, err := s.st.UpdateByID(id, share)
+		if err != nil {
+			return Share{}, false, err
+		}
+		if !ok {
+			return Share{}, false, fmt.Errorf("share %d not found", id)
+		}
+		return Share{ID: id, Share: share}, false, nil
+	}
+
+	// Выдача нового права
+	share := entity.Share{
+		NoteID:     noteID,
+		UserID:     subject.UserID,
+		Group:      subject.Group,
+		Permission: string(permission),
+		GrantedBy:  grantedBy,
+		CreatedAt:  time.Now(),
+	}
+	id, err := s.st.Add(share)
+	if err != nil {
+		return Share{}, false, err
+	}
+	s.byNote[noteID] = append(s.byNote[noteID], id)
+	return Share{ID: id, Share: share}, true, nil
+}
+
+// Revoke отзывает право субъекта subject на заметку noteID. Возвращает false, если право не выдавалось.
+func (s *Shares) Revoke(noteID int64, subject Subject) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := s.byNote[noteID]
+	for i, id := range ids {
+		share, err := s.get(id)
+		if err != nil {
+			return false, err
+		}
+		if share.UserID != subject.UserID || share.Group != subject.Group {
+			continue
+		}
+		s.st.RemoveByID(id)
+		s.byNote[noteID] = append(ids[:i:i], ids[i+1:]...)
+		if len(s.byNote[noteID]) == 0 {
+			delete(s.byNote, noteID)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// RemoveNote отзывает все права на заметку noteID (вызывается при ее удалении)
+func (s *Shares) RemoveNote(noteID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.byNote[noteID] {
+		s.st.RemoveByID(id)
+	}
+	delete(s.byNote, noteID)
+}
+
+// List возвращает права, выданные на заметку noteID, в порядке выдачи
+func (s *Shares) List(noteID int64) ([]Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shares := make([]Share, 0, len(s.byNote[noteID]))
+	for _, id := range s.byNote[noteID] {
+		share, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, Share{ID: id, Share: share})
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].ID < shares[j].ID })
+	return shares, nil
+}
+
+// Permission возвращает наибольшее право на заметку noteID, выданное пользователю userID
+// лично или одной из его групп groups
+func (s *Shares) Permission(noteID int64, userID int64, groups []string) Permission {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	best := PermissionNone
+	for _, id := range s.byNote[noteID] {
+		share, err := s.get(id)
+		if err != nil || !appliesTo(share, userID, groups) {
+			continue
+		}
+		if Permission(share.Permission).Allows(best) {
+			best = Permission(share.Permission)
+		}
+	}
+	return best
+}
+
+// get возвращает запись права из хранилища
+func (s *Shares) get(id int64) (entity.Share, error) {
+	shareAny, ok := s.st.GetByID(id)
+	if !ok {
+		return entity.Share{}, fmt.Errorf("share %d not found", id)
+	}
+	share, ok := shareAny.(entity.Share)
+	if !ok {
+		return entity.Share{}, errors.New("cannot convert interface{} to Share")
+	}
+	return share, nil
+}
+
+// appliesTo проверяет, что право share выдано пользователю userID или одной из его групп groups
+func appliesTo(share entity.Share, userID int64, groups []string) bool {
+	if share.UserID != 0 {
+		return share.UserID == userID
+	}
+	for _, group := range groups {
+		if group == share.Group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package faulty
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"notesServer/gates/storage"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Имена методов storage.Storage, в которые можно внедрять сбои
+const (
+	MethodLen              = "Len"
+	MethodAdd              = "Add"
+	MethodRemoveByID       = "RemoveByID"
+	MethodRemoveByValue    = "RemoveByValue"
+	MethodRemoveAllByValue = "RemoveAllByValue"
+	MethodGetByID          = "GetByID"
+	MethodGetByValue       = "GetByValue"
+	MethodGetAllByValue    = "GetAllByValue"
+	MethodUpdateByID       = "UpdateByID"
+	MethodGetAll           = "GetAll"
+	MethodFind             = "Find"
+	MethodFindAll          = "FindAll"
+	MethodRemoveWhere      = "RemoveWhere"
+	MethodAddMany          = "AddMany"
+	MethodUpdateMany       = "UpdateMany"
+	MethodRemoveMany       = "RemoveMany"
+	MethodClear            = "Clear"
+	MethodPrint            = "Print"
+)
+
+// Имена методов дополнительных интерфейсов хранилища (storage.Ordered, storage.Versioned,
+// storage.Migratable и storage.Scrubber), в которые можно внедрять сбои (см. Faulty.Storage)
+const (
+	MethodMoveBefore      = "MoveBefore"
+	MethodMoveAfter       = "MoveAfter"
+	MethodMoveToFront     = "MoveToFront"
+	MethodMoveToBack      = "MoveToBack"
+	MethodGetAllOrdered   = "GetAllOrdered"
+	MethodSnapshot        = "Snapshot"
+	MethodSnapshotAsOf    = "SnapshotAsOf"
+	MethodSnapshotByToken = "SnapshotByToken"
+	MethodGetByIDAsOf     = "GetByIDAsOf"
+	MethodGetAllAsOf      = "GetAllAsOf"
+	MethodInsert          = "Insert"
+	MethodNextID          = "NextID"
+	MethodSetNextID       = "SetNextID"
+	MethodScrub           = "Scrub"
+)
+
+var methods = []string{
+	MethodLen, MethodAdd, MethodRemoveByID, MethodRemoveByValue, MethodRemoveAllByValue,
+	MethodGetByID, MethodGetByValue, MethodGetAllByValue, MethodUpdateByID, MethodGetAll,
+	MethodFind, MethodFindAll, MethodRemoveWhere, MethodAddMany, MethodUpdateMany, MethodRemoveMany,
+	MethodClear, MethodPrint,
+	MethodMoveBefore, MethodMoveAfter, MethodMoveToFront, MethodMoveToBack, MethodGetAllOrdered,
+	MethodSnapshot, MethodSnapshotAsOf, MethodSnapshotByToken, MethodGetByIDAsOf, MethodGetAllAsOf,
+	MethodInsert, MethodNextID, MethodSetNextID, MethodScrub,
+}
+
+// ErrInjected ошибка, внедряемая по умолчанию, если в Fault не указана другая
+var ErrInjected = errors.New("injected storage fault")
+
+// Fault - правило внедрения сбоя в один метод хранилища.
+// Сбой срабатывает на вызовах из Schedule, на каждом EveryN-м вызове и случайно с вероятностью Probability.
+// Сработавший сбой выдерживает задержку Latency, затем паникует (если Panic) или завершает вызов ошибкой (если Fail).
+// Без Panic и Fail сбой только замедляет вызов, после чего вызов передается хранилищу.
+type Fault struct {
+	Probability float64       // Вероятность срабатывания на каждом вызове (от 0 до 1)
+	Schedule    []int         // Номера вызовов метода (начиная с 1), на которых сбой срабатывает всегда
+	EveryN      int           // Сбой срабатывает на каждом N-м вызове (0 - не используется)
+	Latency     time.Duration // Задержка перед выполнением вызова
+	Panic       bool          // Вызвать панику вместо выполнения вызова
+	Fail        bool          // Завершить вызов ошибкой вместо выполнения
+	Err         error         // Внедряемая ошибка (по умолчанию ErrInjected)
+}
+
+// Faulty - декоратор storage.Storage, внедряющий настраиваемые сбои в вызовы методов.
+// Предназначен для проверки поведения обработчиков при отказах хранилища.
+//
+// Методы, возвращающие ошибку, при сбое возвращают Fault.Err.
+// Остальные методы при сбое ведут себя как при отсутствии данных: возвращают нулевые значения и false,
+// а изменяющие методы ничего не делают.
+//
+// Сам Faulty реализует только storage.Storage. Чтобы обработчики видели и дополнительные интерфейсы
+// декорируемого хранилища (например, порядок элементов list или снимки mvcc), передавайте им Faulty.Storage().
+type Faulty struct {
+	storage storage.Storage
+	faults  map[string]Fault // имя метода -> правило сбоя
+	calls   map[string]int   // имя метода -> количество вызовов
+	rand    *rand.Rand
+	mu      sync.Mutex
+}
+
+// NewFaulty возвращает декоратор над st без настроенных сбоев.
+// seed задает генератор случайных чисел, чтобы сбои с вероятностью воспроизводились между запусками.
+func NewFaulty(st storage.Storage, seed int64) *Faulty {
+	return &Faulty{
+		storage: st,
+		faults:  make(map[string]Fault),
+		calls:   make(map[string]int),
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// SetFault задает правило сбоя для метода с именем method (например, MethodUpdateByID)
+func (f *Faulty) SetFault(method string, fault Fault) error {
+	if !slices.Contains(methods, method) {
+		return fmt.Errorf("unknown storage method: '%s'", method)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if fault.Err == nil {
+		fault.Err = ErrInjected
+	}
+	f.faults[method] = fault
+	return nil
+}
+
+// ClearFaults удаляет все правила сбоев и сбрасывает счетчики вызовов
+func (f *Faulty) ClearFaults() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = make(map[string]Fault)
+	f.calls = make(map[string]int)
+}
+
+// Calls возвращает количество вызовов метода с именем method
+func (f *Faulty) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[method]
+}
+
+// inject учитывает вызов метода и применяет правило сбоя.
+// Возвращает ошибку, если вызов должен завершиться сбоем, иначе nil.
+func (f *Faulty) inject(method string) error {
+	f.mu.Lock()
+	f.calls[method]++
+	call := f.calls[method]
+	fault, ok := f.faults[method]
+	triggered := ok && (slices.Contains(fault.Schedule, call) ||
+		(fault.EveryN > 0 && call%fault.EveryN == 0) ||
+		(fault.Probability > 0 && f.rand.Float64() < fault.Probability))
+	f.mu.Unlock()
+
+	if !triggered {
+		return nil
+	}
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	if fault.Panic {
+		panic(fmt.Sprintf("%v: call %d of %s", fault.Err, call, method))
+	}
+	if fault.Fail {
+		return fault.Err
+	}
+	return nil
+}
+
+// Len возвращает количество элементов в хранилище (0 при сбое)
+func (f *Faulty) Len() int64 {
+	if f.inject(MethodLen) != nil {
+		return 0
+	}
+	return f.storage.Len()
+}
+
+// Add добавляет элемент в хранилище или возвращает внедренную ошибку
+func (f *Faulty) Add(value any) (int64, error) {
+	if err := f.inject(MethodAdd); err != nil {
+		return 0, err
+	}
+	return f.storage.Add(value)
+}
+
+// RemoveByID удаляет элемент по идентификатору (ничего не делает при сбое)
+func (f *Faulty) RemoveByID(id int64) {
+	if f.inject(MethodRemoveByID) != nil {
+		return
+	}
+	f.storage.RemoveByID(id)
+}
+
+// RemoveByValue удаляет один элемент по значению (ничего не делает при сбое)
+func (f *Faulty) RemoveByValue(value any) {
+	if f.inject(MethodRemoveByValue) != nil {
+		return
+	}
+	f.storage.RemoveByValue(value)
+}
+
+// RemoveAllByValue удаляет все элементы по значению (ничего не делает при сбое)
+func (f *Faulty) RemoveAllByValue(value any) {
+	if f.inject(MethodRemoveAllByValue) != nil {
+		return
+	}
+	f.storage.RemoveAllByValue(value)
+}
+
+// GetByID возвращает значение элемента по идентификатору (nil и false при сбое)
+func (f *Faulty) GetByID(id int64) (any, bool) {
+	if f.inject(MethodGetByID) != nil {
+		return nil, false
+	}
+	return f.storage.GetByID(id)
+}
+
+// GetByValue возвращает идентификатор элемента по значению (0 и false при сбое)
+func (f *Faulty) GetByValue(value any) (int64, bool) {
+	if f.inject(MethodGetByValue) != nil {
+		return 0, false
+	}
+	return f.storage.GetByValue(value)
+}
+
+// GetAllByValue возвращает идентификаторы элементов по значению (nil и false при сбое)
+func (f *Faulty) GetAllByValue(value any) ([]int64, bool) {
+	if f.inject(MethodGetAllByValue) != nil {
+		return nil, false
+	}
+	return f.storage.GetAllByValue(value)
+}
+
+// UpdateByID обновляет значение элемента или возвращает false и внедренную ошибку
+func (f *Faulty) UpdateByID(id int64, value any) (bool, error) {
+	if err := f.inject(MethodUpdateByID); err != nil {
+		return false, err
+	}
+	return f.storage.UpdateByID(id, value)
+}
+
+// GetAll возвращает все элементы хранилища (nil и false при сбое)
+func (f *Faulty) GetAll() (map[int64]any, bool) {
+	if f.inject(MethodGetAll) != nil {
+		return nil, false
+	}
+	return f.storage.GetAll()
+}
+
+// Find возвращает найденные элементы (nil и false при сбое)
+func (f *Faulty) Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool) {
+	if f.inject(MethodFind) != nil {
+		return nil, false
+	}
+	return f.storage.Find(predicate, limit)
+}
+
+// FindAll возвращает все найденные элементы (nil и false при сбое)
+func (f *Faulty) FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool) {
+	if f.inject(MethodFindAll) != nil {
+		return nil, false
+	}
+	return f.storage.FindAll(predicate)
+}
+
+// RemoveWhere удаляет найденные элементы (ничего не делает и возвращает 0 при сбое)
+func (f *Faulty) RemoveWhere(predicate func(id int64, v any) bool) int64 {
+	if f.inject(MethodRemoveWhere) != nil {
+		return 0
+	}
+	return f.storage.RemoveWhere(predicate)
+}
+
+// AddMany добавляет элементы или возвращает внедренную ошибку для каждого элемента
+func (f *Faulty) AddMany(values []any) []storage.Result {
+	if err := f.inject(MethodAddMany); err != nil {
+		return failedResults(make([]int64, len(values)), err)
+	}
+	return f.storage.AddMany(values)
+}
+
+// UpdateMany обновляет элементы или возвращает внедренную ошибку для каждого элемента
+func (f *Faulty) UpdateMany(items []storage.Item) []storage.Result {
+	if err := f.inject(MethodUpdateMany); err != nil {
+		ids := make([]int64, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		return failedResults(ids, err)
+	}
+	return f.storage.UpdateMany(items)
+}
+
+// RemoveMany удаляет элементы или возвращает внедренную ошибку для каждого элемента
+func (f *Faulty) RemoveMany(ids []int64) []storage.Result {
+	if err := f.inject(MethodRemoveMany); err != nil {
+		return failedResults(ids, err)
+	}
+	return f.storage.RemoveMany(ids)
+}
+
+// Clear удаляет все элементы из хранилища (ничего не делает при сбое)
+func (f *Faulty) Clear() {
+	if f.inject(MethodClear) != nil {
+		return
+	}
+	f.storage.Clear()
+}
+
+// Print выводит содержимое хранилища в консоль (ничего не делает при сбое)
+func (f *Faulty) Print() {
+	if f.inject(MethodPrint) != nil {
+		return
+	}
+	f.storage.Print()
+}
+
+// failedResults возвращает результаты пакетной операции, завершившейся ошибкой для всех элементов
+func failedResults(ids []int64, err error) []storage.Result {
+	results := make([]storage.Result, len(ids))
+	for i, id := range ids {
+		results[i] = storage.Result{ID: id, Err: err}
+	}
+	return results
+}
@@ -0,0 +1,255 @@
+package faulty
+
+import (
+	"notesServer/gates/storage"
+	"time"
+)
+
+// Storage возвращает декоратор в виде хранилища, реализующего те же дополнительные интерфейсы
+// (storage.Ordered, storage.Versioned, storage.Migratable и storage.Scrubber), что и декорируемое хранилище.
+// Обработчики выбирают поведение по этим интерфейсам, поэтому без них сбои проверялись бы не на том пути:
+// например, /reorder над Faulty поверх list отвечал бы, что хранилище не поддерживает порядок.
+// Сбои в методы дополнительных интерфейсов внедряются так же, как в методы storage.Storage.
+func (f *Faulty) Storage() storage.Storage {
+	ordered, isOrdered := f.storage.(storage.Ordered)
+	versioned, isVersioned := f.storage.(storage.Versioned)
+	migratable, isMigratable := f.storage.(storage.Migratable)
+	scrubber, isScrubber := f.storage.(storage.Scrubber)
+	o := orderedMethods{f: f, ordered: ordered}
+	v := versionedMethods{f: f, versioned: versioned}
+	m := migratableMethods{f: f, migratable: migratable}
+	s := scrubberMethods{f: f, scrubber: scrubber}
+
+	// Тип результата должен реализовывать ровно те интерфейсы, что и декорируемое хранилище,
+	// поэтому для каждого их сочетания - свой тип
+	switch {
+	case isOrdered && isVersioned && isMigratable && isScrubber:
+		return &struct {
+			*Faulty
+			orderedMethods
+			versionedMethods
+			migratableMethods
+			scrubberMethods
+		}{f, o, v, m, s}
+	case isOrdered && isVersioned && isMigratable:
+		return &struct {
+			*Faulty
+			orderedMethods
+			versionedMethods
+			migratableMethods
+		}{f, o, v, m}
+	case isOrdered && isVersioned && isScrubber:
+		return &struct {
+			*Faulty
+			orderedMethods
+			versionedMethods
+			scrubberMethods
+		}{f, o, v, s}
+	case isOrdered && isMigratable && isScrubber:
+		return &struct {
+			*Faulty
+			orderedMethods
+			migratableMethods
+			scrubberMethods
+		}{f, o, m, s}
+	case isVersioned && isMigratable && isScrubber:
+		return &struct {
+			*Faulty
+			versionedMethods
+			migratableMethods
+			scrubberMethods
+		}{f, v, m, s}
+	case isOrdered && isVersioned:
+		return &struct {
+			*Faulty
+			orderedMethods
+			versionedMethods
+		}{f, o, v}
+	case isOrdered && isMigratable:
+		return &struct {
+			*Faulty
+			orderedMethods
+			migratableMethods
+		}{f, o, m}
+	case isOrdered && isScrubber:
+		return &struct {
+			*Faulty
+			orderedMethods
+			scrubberMethods
+		}{f, o, s}
+	case isVersioned && isMigratable:
+		return &struct {
+			*Faulty
+			versionedMethods
+			migratableMethods
+		}{f, v, m}
+	case isVersioned && isScrubber:
+		return &struct {
+			*Faulty
+			versionedMethods
+			scrubberMethods
+		}{f, v, s}
+	case isMigratable && isScrubber:
+		return &struct {
+			*Faulty
+			migratableMethods
+			scrubberMethods
+		}{f, m, s}
+	case isOrdered:
+		return &struct {
+			*Faulty
+			orderedMethods
+		}{f, o}
+	case isVersioned:
+		return &struct {
+			*Faulty
+			versionedMethods
+		}{f, v}
+	case isMigratable:
+		return &struct {
+			*Faulty
+			migratableMethods
+		}{f, m}
+	case isScrubber:
+		return &struct {
+			*Faulty
+			scrubberMethods
+		}{f, s}
+	default:
+		return f
+	}
+}
+
+// orderedMethods - методы storage.Ordered с внедрением сбоев
+type orderedMethods struct {
+	f       *Faulty
+	ordered storage.Ordered
+}
+
+// MoveBefore перемещает элемент перед элементом anchorID (ничего не делает и возвращает false при сбое)
+func (o orderedMethods) MoveBefore(id int64, anchorID int64) bool {
+	if o.f.inject(MethodMoveBefore) != nil {
+		return false
+	}
+	return o.ordered.MoveBefore(id, anchorID)
+}
+
+// MoveAfter перемещает элемент после элемента anchorID (ничего не делает и возвращает false при сбое)
+func (o orderedMethods) MoveAfter(id int64, anchorID int64) bool {
+	if o.f.inject(MethodMoveAfter) != nil {
+		return false
+	}
+	return o.ordered.MoveAfter(id, anchorID)
+}
+
+// MoveToFront перемещает элемент в начало (ничего не делает и возвращает false при сбое)
+func (o orderedMethods) MoveToFront(id int64) bool {
+	if o.f.inject(MethodMoveToFront) != nil {
+		return false
+	}
+	return o.ordered.MoveToFront(id)
+}
+
+// MoveToBack перемещает элемент в конец (ничего не делает и возвращает false при сбое)
+func (o orderedMethods) MoveToBack(id int64) bool {
+	if o.f.inject(MethodMoveToBack) != nil {
+		return false
+	}
+	return o.ordered.MoveToBack(id)
+}
+
+// GetAllOrdered возвращает все элементы в пользовательском порядке (nil и false при сбое)
+func (o orderedMethods) GetAllOrdered() ([]storage.Item, bool) {
+	if o.f.inject(MethodGetAllOrdered) != nil {
+		return nil, false
+	}
+	return o.ordered.GetAllOrdered()
+}
+
+// versionedMethods - методы storage.Versioned с внедрением сбоев
+type versionedMethods struct {
+	f         *Faulty
+	versioned storage.Versioned
+}
+
+// Snapshot фиксирует текущее состояние хранилища. Метод не может завершиться ошибкой,
+// поэтому сбой только задерживает вызов или вызывает панику.
+func (v versionedMethods) Snapshot() storage.Snapshot {
+	_ = v.f.inject(MethodSnapshot)
+	return v.versioned.Snapshot()
+}
+
+// SnapshotAsOf возвращает снимок на момент t или внедренную ошибку
+func (v versionedMethods) SnapshotAsOf(t time.Time) (storage.Snapshot, error) {
+	if err := v.f.inject(MethodSnapshotAsOf); err != nil {
+		return nil, err
+	}
+	return v.versioned.SnapshotAsOf(t)
+}
+
+// SnapshotByToken возвращает снимок по токену или внедренную ошибку
+func (v versionedMethods) SnapshotByToken(token int64) (storage.Snapshot, error) {
+	if err := v.f.inject(MethodSnapshotByToken); err != nil {
+		return nil, err
+	}
+	return v.versioned.SnapshotByToken(token)
+}
+
+// GetByIDAsOf возвращает значение элемента на момент t или внедренную ошибку
+func (v versionedMethods) GetByIDAsOf(id int64, t time.Time) (any, bool, error) {
+	if err := v.f.inject(MethodGetByIDAsOf); err != nil {
+		return nil, false, err
+	}
+	return v.versioned.GetByIDAsOf(id, t)
+}
+
+// GetAllAsOf возвращает все элементы на момент t или внедренную ошибку
+func (v versionedMethods) GetAllAsOf(t time.Time) (map[int64]any, bool, error) {
+	if err := v.f.inject(MethodGetAllAsOf); err != nil {
+		return nil, false, err
+	}
+	return v.versioned.GetAllAsOf(t)
+}
+
+// migratableMethods - методы storage.Migratable с внедрением сбоев
+type migratableMethods struct {
+	f          *Faulty
+	migratable storage.Migratable
+}
+
+// Insert добавляет элемент с указанным ID или возвращает внедренную ошибку
+func (m migratableMethods) Insert(id int64, value any) error {
+	if err := m.f.inject(MethodInsert); err != nil {
+		return err
+	}
+	return m.migratable.Insert(id, value)
+}
+
+// NextID возвращает идентификатор следующего элемента. Метод не может завершиться ошибкой,
+// поэтому сбой только задерживает вызов или вызывает панику.
+func (m migratableMethods) NextID() int64 {
+	_ = m.f.inject(MethodNextID)
+	return m.migratable.NextID()
+}
+
+// SetNextID устанавливает идентификатор следующего элемента (ничего не делает при сбое)
+func (m migratableMethods) SetNextID(id int64) {
+	if m.f.inject(MethodSetNextID) != nil {
+		return
+	}
+	m.migratable.SetNextID(id)
+}
+
+// scrubberMethods - методы storage.Scrubber с внедрением сбоев
+type scrubberMethods struct {
+	f        *Faulty
+	scrubber storage.Scrubber
+}
+
+// Scrub проверяет контрольные суммы записей или возвращает внедренную ошибку
+func (s scrubberMethods) Scrub(quarantine bool) (storage.ScrubReport, error) {
+	if err := s.f.inject(MethodScrub); err != nil {
+		return storage.ScrubReport{}, err
+	}
+	return s.scrubber.Scrub(quarantine)
+}
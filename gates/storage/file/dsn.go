@@ -0,0 +1,28 @@
+package file
+
+import (
+	"errors"
+	"notesServer/gates/storage"
+)
+
+// Регистрация файлового хранилища под схемой "file", путь в строке подключения - каталог данных
+// (например, "file:///var/lib/notes"). Параметры строки подключения:
+//
+//	init_id - идентификатор первого добавляемого элемента, если файла данных еще нет (по умолчанию 1)
+//	fsync   - сбрасывать ли файл на диск при каждом сохранении (по умолчанию true)
+func init() {
+	storage.Register("file", func(path string, opts *storage.Options) (storage.Storage, error) {
+		if path == "" {
+			return nil, errors.New("storage directory is missing (example: file:///var/lib/notes)")
+		}
+		initID, err := opts.Int64("init_id", 1)
+		if err != nil {
+			return nil, err
+		}
+		fsync, err := opts.Bool("fsync", true)
+		if err != nil {
+			return nil, err
+		}
+		return NewFile(path, initID, fsync)
+	})
+}
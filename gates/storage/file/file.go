@@ -0,0 +1,484 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/mp"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	dataFileName  = "storage.gob" // имя файла данных в каталоге хранилища
+	quarantineDir = "quarantine"  // каталог для поврежденных записей, помещенных в карантин
+)
+
+// ErrChecksumMismatch ошибка, означающая, что контрольная сумма записи не совпадает с ее содержимым
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// File - хранилище, которое держит элементы в памяти и после каждого изменения
+// атомарно перезаписывает файл данных (запись во временный файл и переименование).
+// Каждая запись файла хранит контрольную сумму, которая проверяется при загрузке и методом Scrub.
+// Значения сохраняются через encoding/gob, поэтому их типы должны быть зарегистрированы функцией gob.Register.
+//
+// Каждое изменение перезаписывает файл целиком, а с fsync еще и сбрасывает его на диск, поэтому стоит O(N)
+// по числу элементов и ограничено скоростью диска. Хранилище рассчитано на небольшие наборы данных;
+// для пакетных изменений есть AddMany, UpdateMany и RemoveMany, сохраняющие файл один раз.
+//
+// Если сохранить файл не удалось, изменение откатывается в памяти, чтобы она не расходилась с файлом.
+// Ошибка сохранения возвращается методами, у которых есть возвращаемая ошибка, и выводится в лог для остальных.
+type File struct {
+	mem   *mp.Map
+	path  string // путь к файлу данных
+	fsync bool   // сбрасывать ли файл на диск перед переименованием
+	// записи файла данных, которых нет в памяти: не прошедшие проверку контрольной суммы при загрузке
+	// или найденные методом Scrub. Они не видны через методы хранилища, но сохраняются в файле
+	// до помещения в карантин методом Scrub.
+	corrupt map[int64]fileItem
+	mu      sync.Mutex
+}
+
+// fileState - содержимое файла данных
+type fileState struct {
+	NextID int64
+	Items  []fileItem
+}
+
+// fileItem - запись файла данных: закодированное значение элемента и его контрольная сумма
+type fileItem struct {
+	ID       int64
+	Data     []byte // значение, закодированное через encoding/gob
+	Checksum uint32 // CRC-32 (IEEE) идентификатора и Data
+}
+
+// itemValue - обертка значения элемента, позволяющая закодировать значение интерфейсного типа
+type itemValue struct {
+	Value any
+}
+
+// NewFile открывает хранилище в каталоге dir, создавая каталог при необходимости.
+// Если в каталоге уже есть файл данных, элементы и счетчик идентификаторов загружаются из него,
+// иначе первый элемент будет иметь идентификатор initID.
+func NewFile(dir string, initID int64, fsync bool) (*File, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create storage directory: %w", err)
+	}
+
+	f := &File{
+		mem:     mp.NewMap(initID),
+		path:    filepath.Join(dir, dataFileName),
+		fsync:   fsync,
+		corrupt: make(map[int64]fileItem),
+	}
+	err = f.load()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Len возвращает количество элементов в хранилище
+func (f *File) Len() int64 {
+	return f.mem.Len()
+}
+
+// Add добавляет значение в хранилище и возвращает его идентификатор
+func (f *File) Add(value any) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	id, err := f.mem.Add(value)
+	if err != nil {
+		return id, err
+	}
+	err = f.commit(before)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// RemoveByID удаляет элемент из хранилища по идентификатору
+func (f *File) RemoveByID(id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.mem.GetByID(id); !ok {
+		return
+	}
+	before := f.state()
+	f.mem.RemoveByID(id)
+	f.commitAndLog(before)
+}
+
+// RemoveByValue удаляет один элемент из хранилища по значению
+func (f *File) RemoveByValue(value any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, ok := f.mem.GetByValue(value)
+	if !ok {
+		return
+	}
+	before := f.state()
+	f.mem.RemoveByID(id)
+	f.commitAndLog(before)
+}
+
+// RemoveAllByValue удаляет все элементы из хранилища по значению
+func (f *File) RemoveAllByValue(value any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids, ok := f.mem.GetAllByValue(value)
+	if !ok {
+		return
+	}
+	before := f.state()
+	f.mem.RemoveMany(ids)
+	f.commitAndLog(before)
+}
+
+// GetByID возвращает значение элемента по идентификатору
+func (f *File) GetByID(id int64) (any, bool) {
+	return f.mem.GetByID(id)
+}
+
+// GetByValue возвращает идентификатор первого найденного элемента по значению
+func (f *File) GetByValue(value any) (int64, bool) {
+	return f.mem.GetByValue(value)
+}
+
+// GetAllByValue возвращает идентификаторы всех найденных элементов с указанным значением
+func (f *File) GetAllByValue(value any) ([]int64, bool) {
+	return f.mem.GetAllByValue(value)
+}
+
+// UpdateByID обновляет значение элемента по идентификатору
+func (f *File) UpdateByID(id int64, value any) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	ok, err := f.mem.UpdateByID(id, value)
+	if !ok || err != nil {
+		return ok, err
+	}
+	err = f.commit(before)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAll возвращает все элементы хранилища в виде map[int64]any
+func (f *File) GetAll() (map[int64]any, bool) {
+	return f.mem.GetAll()
+}
+
+// Find возвращает не более limit элементов, для которых predicate возвращает true
+func (f *File) Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool) {
+	return f.mem.Find(predicate, limit)
+}
+
+// FindAll возвращает все элементы, для которых predicate возвращает true
+func (f *File) FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool) {
+	return f.mem.FindAll(predicate)
+}
+
+// RemoveWhere удаляет все элементы, для которых predicate возвращает true, и возвращает количество удаленных
+func (f *File) RemoveWhere(predicate func(id int64, v any) bool) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	removed := f.mem.RemoveWhere(predicate)
+	if removed == 0 {
+		return 0
+	}
+	if f.commitAndLog(before) != nil {
+		return 0
+	}
+	return removed
+}
+
+// AddMany добавляет значения в хранилище и сохраняет файл один раз для всех значений
+func (f *File) AddMany(values []any) []storage.Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	results := f.mem.AddMany(values)
+	f.commitResults(results, before)
+	return results
+}
+
+// UpdateMany обновляет элементы хранилища и сохраняет файл один раз для всех элементов
+func (f *File) UpdateMany(items []storage.Item) []storage.Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	results := f.mem.UpdateMany(items)
+	f.commitResults(results, before)
+	return results
+}
+
+// RemoveMany удаляет элементы хранилища и сохраняет файл один раз для всех элементов
+func (f *File) RemoveMany(ids []int64) []storage.Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	results := f.mem.RemoveMany(ids)
+	f.commitResults(results, before)
+	return results
+}
+
+// Insert добавляет значение в хранилище с указанным идентификатором
+func (f *File) Insert(id int64, value any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	err := f.mem.Insert(id, value)
+	if err != nil {
+		return err
+	}
+	return f.commit(before)
+}
+
+// NextID возвращает идентификатор следующего добавляемого элемента
+func (f *File) NextID() int64 {
+	return f.mem.NextID()
+}
+
+// SetNextID устанавливает идентификатор следующего добавляемого элемента. Значение, не превышающее
+// наибольший идентификатор существующего элемента, игнорируется: иначе следующий Add перезаписал бы элемент.
+func (f *File) SetNextID(id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	f.mem.SetNextID(id)
+	f.commitAndLog(before)
+}
+
+// Clear очищает хранилище
+func (f *File) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.state()
+	f.mem.Clear()
+	f.commitAndLog(before)
+}
+
+// Print выводит содержимое хранилища в консоль
+func (f *File) Print() {
+	f.mem.Print()
+}
+
+// Close сохраняет текущее состояние хранилища в файл
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.save()
+}
+
+// load загружает элементы из файла данных, если он существует.
+// Записи с неверной контрольной суммой не загружаются в память и откладываются в f.corrupt.
+func (f *File) load() error {
+	state, err := f.readState()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, item := range state.Items {
+		value, err := item.decode()
+		if err != nil {
+			f.corrupt[item.ID] = item
+			log.Printf("file storage: item %d is corrupt: %s", item.ID, err)
+			continue
+		}
+		err = f.mem.Insert(item.ID, value)
+		if err != nil {
+			return fmt.Errorf("cannot load item %d: %w", item.ID, err)
+		}
+	}
+	f.mem.SetNextID(state.NextID)
+	return nil
+}
+
+// readState читает и декодирует файл данных
+func (f *File) readState() (fileState, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fileState{}, fmt.Errorf("cannot open data file: %w", err)
+	}
+	defer file.Close()
+
+	state := fileState{}
+	err = gob.NewDecoder(file).Decode(&state)
+	if err != nil {
+		return fileState{}, fmt.Errorf("cannot decode data file %s: %w", f.path, err)
+	}
+	return state, nil
+}
+
+// save атомарно перезаписывает файл данных текущим состоянием хранилища. Вызывается под f.mu.
+func (f *File) save() error {
+	all, _ := f.mem.GetAll()
+	state := fileState{NextID: f.mem.NextID(), Items: make([]fileItem, 0, len(all)+len(f.corrupt))}
+	for id, value := range all {
+		item, err := encodeItem(id, value)
+		if err != nil {
+			return fmt.Errorf("cannot encode item %d: %w", id, err)
+		}
+		state.Items = append(state.Items, item)
+	}
+	for id, item := range f.corrupt {
+		// Поврежденная запись заменяется элементом, вставленным с тем же ID
+		if _, ok := all[id]; !ok {
+			state.Items = append(state.Items, item)
+		}
+	}
+	sort.Slice(state.Items, func(i, j int) bool { return state.Items[i].ID < state.Items[j].ID })
+
+	return f.writeFile(f.path, state)
+}
+
+// writeFile атомарно записывает value через encoding/gob в файл path
+func (f *File) writeFile(path string, value any) error {
+	// Запись во временный файл в том же каталоге, чтобы переименование было атомарным
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // после успешного переименования файла уже нет
+
+	err = gob.NewEncoder(tmp).Encode(value)
+	if err == nil && f.fsync {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("cannot replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeItem кодирует значение элемента и вычисляет контрольную сумму записи
+func encodeItem(id int64, value any) (fileItem, error) {
+	buf := bytes.Buffer{}
+	err := gob.NewEncoder(&buf).Encode(itemValue{Value: value})
+	if err != nil {
+		return fileItem{}, err
+	}
+	return fileItem{ID: id, Data: buf.Bytes(), Checksum: checksum(id, buf.Bytes())}, nil
+}
+
+// decode проверяет контрольную сумму записи и декодирует значение элемента
+func (item fileItem) decode() (any, error) {
+	if checksum(item.ID, item.Data) != item.Checksum {
+		return nil, ErrChecksumMismatch
+	}
+	decoded := itemValue{}
+	err := gob.NewDecoder(bytes.NewReader(item.Data)).Decode(&decoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode value: %w", err)
+	}
+	return decoded.Value, nil
+}
+
+// checksum возвращает CRC-32 идентификатора и закодированного значения элемента
+func checksum(id int64, data []byte) uint32 {
+	idBytes := [8]byte{}
+	binary.BigEndian.PutUint64(idBytes[:], uint64(id))
+	crc := crc32.Update(0, crc32.IEEETable, idBytes[:])
+	return crc32.Update(crc, crc32.IEEETable, data)
+}
+
+// memState - копия элементов в памяти и счетчика идентификаторов для отката изменения
+type memState struct {
+	items  map[int64]any
+	nextID int64
+}
+
+// state возвращает копию текущего состояния элементов в памяти. Вызывается под f.mu.
+// Копирование, как и сохранение файла, стоит O(N) по числу элементов.
+func (f *File) state() memState {
+	items, _ := f.mem.GetAll()
+	return memState{items: items, nextID: f.mem.NextID()}
+}
+
+// commit сохраняет файл данных после изменения элементов в памяти. Если сохранить файл не удалось,
+// элементы в памяти возвращаются к состоянию before, снятому до изменения. Вызывается под f.mu.
+func (f *File) commit(before memState) error {
+	err := f.save()
+	if err == nil {
+		return nil
+	}
+	f.mem.Clear()
+	for id, value := range before.items {
+		// Элементы уже были в хранилище вместе, поэтому вставка не может завершиться ошибкой
+		_ = f.mem.Insert(id, value)
+	}
+	f.mem.SetNextID(before.nextID)
+	return err
+}
+
+// commitAndLog сохраняет изменение как commit и выводит ошибку сохранения в лог. Вызывается под f.mu.
+func (f *File) commitAndLog(before memState) error {
+	err := f.commit(before)
+	if err != nil {
+		log.Println("file storage: change rolled back:", err)
+	}
+	return err
+}
+
+// commitResults сохраняет изменение пакетной операции как commit, если она изменила хранилище.
+// Если сохранить файл не удалось, элементы, измененные операцией, отмечаются в результатах
+// как не измененные с ошибкой сохранения. Вызывается под f.mu.
+func (f *File) commitResults(results []storage.Result, before memState) {
+	changed := false
+	for _, result := range results {
+		changed = changed || result.OK
+	}
+	if !changed {
+		return
+	}
+
+	err := f.commit(before)
+	if err == nil {
+		return
+	}
+	for i := range results {
+		if results[i].OK {
+			results[i].OK = false
+			results[i].Err = err
+		}
+	}
+}
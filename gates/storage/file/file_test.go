@@ -0,0 +1,74 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestFile открывает хранилище во временном каталоге без сброса на диск
+func newTestFile(t *testing.T) (*File, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "data")
+	f, err := NewFile(dir, 1, false)
+	if err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+	return f, dir
+}
+
+// breakSaving делает сохранение файла данных невозможным: временный файл негде создать
+func breakSaving(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedSaveRollsBack(t *testing.T) {
+	f, dir := newTestFile(t)
+	first, _ := f.Add("первая")
+	second, _ := f.Add("вторая")
+	breakSaving(t, dir)
+
+	if id, err := f.Add("третья"); err == nil {
+		t.Fatalf("Add() without data directory = %d, nil", id)
+	}
+	if ok, err := f.UpdateByID(first, "изменена"); ok || err == nil {
+		t.Fatalf("UpdateByID() without data directory = %t, %v", ok, err)
+	}
+	f.RemoveByID(second)
+	f.Clear()
+	if removed := f.RemoveWhere(func(int64, any) bool { return true }); removed != 0 {
+		t.Fatalf("RemoveWhere() without data directory = %d", removed)
+	}
+	for _, result := range f.AddMany([]any{"четвертая"}) {
+		if result.OK || result.Err == nil {
+			t.Fatalf("AddMany() without data directory: %+v", result)
+		}
+	}
+
+	// Память совпадает с последним сохраненным состоянием
+	all, _ := f.GetAll()
+	if len(all) != 2 || all[first] != "первая" || all[second] != "вторая" {
+		t.Fatalf("items after failed saves: %v", all)
+	}
+	if next := f.NextID(); next != second+1 {
+		t.Fatalf("NextID() after failed saves = %d, want %d", next, second+1)
+	}
+
+	// После восстановления каталога изменения сохраняются, а идентификаторы продолжаются с сохраненного
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := f.Add("третья"); err != nil || id != second+1 {
+		t.Fatalf("Add() after recovery = %d, %v", id, err)
+	}
+	reopened, err := NewFile(dir, 1, false)
+	if err != nil {
+		t.Fatalf("NewFile after recovery: %v", err)
+	}
+	if n := reopened.Len(); n != 3 {
+		t.Fatalf("reopened storage has %d items, want 3", n)
+	}
+}
@@ -0,0 +1,105 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"notesServer/gates/storage"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Scrub перечитывает файл данных, проверяет контрольную сумму каждой записи и сверяет набор записей
+// с элементами в памяти.
+//
+// Запись считается поврежденной, если ее контрольная сумма не совпадает с содержимым, значение не декодируется
+// или отличается от значения в памяти. Если элемент есть в памяти, поврежденная запись перезаписывается из памяти.
+// Иначе (запись была повреждена уже при загрузке) при quarantine == true она сохраняется в отдельный файл
+// в каталоге quarantine рядом с файлом данных и удаляется из файла данных.
+//
+// Элементы в памяти, записей которых нет в файле данных, записываются заново (MissingOnDisk).
+// Исправные записи файла данных, которых нет в памяти (MissingInMemory), не загружаются, но и не теряются:
+// они остаются в файле данных, как поврежденные, а при quarantine == true переносятся в карантин.
+func (f *File) Scrub(quarantine bool) (storage.ScrubReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	report := storage.ScrubReport{}
+	state, err := f.readState()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return report, err
+	}
+	// Если файла данных нет, в нем не хватает всех элементов из памяти
+
+	unrecoverable := make(map[int64]fileItem)
+	onDisk := make(map[int64]struct{}, len(state.Items))
+	for _, item := range state.Items {
+		report.Checked++
+		onDisk[item.ID] = struct{}{}
+		value, err := item.decode()
+		memValue, inMemory := f.mem.GetByID(item.ID)
+		if err == nil && inMemory && reflect.DeepEqual(value, memValue) {
+			continue
+		}
+		if err == nil && !inMemory {
+			// Исправная запись, о которой память не знает: сохраняется в файле до переноса в карантин
+			report.MissingInMemory = append(report.MissingInMemory, item.ID)
+			f.corrupt[item.ID] = item
+			unrecoverable[item.ID] = item
+			continue
+		}
+
+		report.Corrupt = append(report.Corrupt, item.ID)
+		if inMemory {
+			report.Repaired = append(report.Repaired, item.ID)
+		} else {
+			unrecoverable[item.ID] = item
+		}
+	}
+
+	// Элементы в памяти, которых нет в файле данных
+	all, _ := f.mem.GetAll()
+	for id := range all {
+		if _, ok := onDisk[id]; !ok {
+			report.MissingOnDisk = append(report.MissingOnDisk, id)
+		}
+	}
+
+	// Перенос неисправимых и лишних записей в карантин
+	if quarantine {
+		for id, item := range unrecoverable {
+			err = f.quarantine(item)
+			if err != nil {
+				return report, fmt.Errorf("cannot quarantine item %d: %w", id, err)
+			}
+			delete(f.corrupt, id)
+			report.Quarantined = append(report.Quarantined, id)
+		}
+	}
+
+	// Перезапись файла данных исправными значениями из памяти и без записей, перенесенных в карантин
+	if len(report.Repaired) != 0 || len(report.Quarantined) != 0 || len(report.MissingOnDisk) != 0 {
+		err = f.save()
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, ids := range [][]int64{report.Corrupt, report.Repaired, report.Quarantined, report.MissingOnDisk, report.MissingInMemory} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return report, nil
+}
+
+// quarantine сохраняет поврежденную запись в отдельный файл каталога карантина. Вызывается под f.mu.
+func (f *File) quarantine(item fileItem) error {
+	dir := filepath.Join(filepath.Dir(f.path), quarantineDir)
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%d.gob", item.ID, time.Now().UnixNano())
+	return f.writeFile(filepath.Join(dir, name), item)
+}
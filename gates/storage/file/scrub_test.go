@@ -0,0 +1,94 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// rewriteDataFile заменяет записи файла данных результатом edit, не меняя элементы в памяти
+func rewriteDataFile(t *testing.T, f *File, edit func(items []fileItem) []fileItem) {
+	t.Helper()
+	state, err := f.readState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Items = edit(state.Items)
+	if err = f.writeFile(f.path, state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScrubComparesMemoryAndDisk(t *testing.T) {
+	f, dir := newTestFile(t)
+	first, _ := f.Add("первая")
+	second, _ := f.Add("вторая")
+
+	// На диске пропала запись second и появилась исправная запись, о которой память не знает
+	extra, err := encodeItem(100, "лишняя")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewriteDataFile(t, f, func(items []fileItem) []fileItem {
+		kept := []fileItem{extra}
+		for _, item := range items {
+			if item.ID != second {
+				kept = append(kept, item)
+			}
+		}
+		return kept
+	})
+
+	report, err := f.Scrub(false)
+	if err != nil {
+		t.Fatalf("Scrub(false): %v", err)
+	}
+	if !reflect.DeepEqual(report.MissingOnDisk, []int64{second}) || !reflect.DeepEqual(report.MissingInMemory, []int64{100}) ||
+		len(report.Corrupt) != 0 || len(report.Quarantined) != 0 || report.Checked != 2 {
+		t.Fatalf("Scrub(false) = %+v", report)
+	}
+
+	// Недостающая запись записана заново, лишняя сохранена в файле и не видна через хранилище
+	report, err = f.Scrub(false)
+	if err != nil || len(report.MissingOnDisk) != 0 || !reflect.DeepEqual(report.MissingInMemory, []int64{100}) {
+		t.Fatalf("second Scrub(false) = %+v, %v", report, err)
+	}
+	if _, ok := f.GetByID(100); ok {
+		t.Fatal("record missing in memory is visible through the storage")
+	}
+
+	report, err = f.Scrub(true)
+	if err != nil || !reflect.DeepEqual(report.Quarantined, []int64{100}) {
+		t.Fatalf("Scrub(true) = %+v, %v", report, err)
+	}
+	quarantined, _ := os.ReadDir(filepath.Join(dir, quarantineDir))
+	if len(quarantined) != 1 {
+		t.Fatalf("quarantine has %d files, want 1", len(quarantined))
+	}
+
+	reopened, err := NewFile(dir, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := reopened.GetAll()
+	if len(all) != 2 || all[first] != "первая" || all[second] != "вторая" {
+		t.Fatalf("reopened storage after scrub: %v", all)
+	}
+}
+
+func TestScrubWithoutDataFile(t *testing.T) {
+	f, dir := newTestFile(t)
+	id, _ := f.Add("заметка")
+	if err := os.Remove(filepath.Join(dir, dataFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := f.Scrub(false)
+	if err != nil || !reflect.DeepEqual(report.MissingOnDisk, []int64{id}) {
+		t.Fatalf("Scrub(false) without data file = %+v, %v", report, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, dataFileName)); err != nil {
+		t.Fatalf("data file is not rewritten: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package list
+
+import "notesServer/gates/storage"
+
+// Регистрация списка под схемой "list". Параметры строки подключения:
+//
+//	init_id - идентификатор первого добавляемого элемента (по умолчанию 1)
+func init() {
+	storage.Register("list", func(path string, opts *storage.Options) (storage.Storage, error) {
+		if path != "" {
+			return nil, storage.ErrPathNotSupported
+		}
+		initID, err := opts.Int64("init_id", 1)
+		if err != nil {
+			return nil, err
+		}
+		return NewList(initID), nil
+	})
+}
@@ -8,18 +8,19 @@ import (
 )
 
 type List struct {
-	length    int64 // Текущая длина списка (количество узлов)
-	firstNode *node // Указатель на первый узел
-	lastNode  *node // Указатель на последний узел (для ускорения вставки элемента в конец)
+	length    int64           // Текущая длина списка (количество узлов)
+	firstNode *node           // Указатель на первый узел
+	lastNode  *node           // Указатель на последний узел (для ускорения вставки элемента в конец)
+	index     map[int64]*node // Индекс ID -> узел для доступа к элементу по идентификатору за O(1)
 	idInitial int64
 	idCounter int64
 	V         reflect.Type // фиксируется при добавлении первого элемента, сбрасывается при удалении всех элементов
 	mu        sync.RWMutex
 }
 
-// NewList создает новый пустой односвязный список
+// NewList создает новый пустой двусвязный список с индексом по идентификаторам
 func NewList(initID int64) (l *List) {
-	return &List{length: 0, firstNode: nil, lastNode: nil, idInitial: initID, idCounter: initID, V: nil}
+	return &List{length: 0, firstNode: nil, lastNode: nil, index: make(map[int64]*node), idInitial: initID, idCounter: initID, V: nil}
 }
 
 // Len возвращает количество элементов в списке
@@ -35,6 +36,10 @@ func (l *List) Add(value any) (id int64, err error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	return l.addUnsafely(value)
+}
+
+func (l *List) addUnsafely(value any) (id int64, err error) {
 	// Согласование типа элементов
 	if l.V == nil {
 		l.V = reflect.TypeOf(value)
@@ -45,73 +50,92 @@ func (l *List) Add(value any) (id int64, err error) {
 	// Создание нового узла и добавление его в конец
 	newNode := &node{id: l.idCounter, value: value}
 	l.idCounter++
-	l.length++
+	l.linkLast(newNode)
+	return newNode.id, nil
+}
+
+// linkLast добавляет узел в конец списка и в индекс
+func (l *List) linkLast(n *node) {
+	n.prevNode = l.lastNode
+	n.nextNode = nil
 	// Случай вставки первого элемента, когда не определены первый и последний узлы
 	if l.firstNode == nil {
-		l.firstNode = newNode
-		l.lastNode = newNode
-		return l.idCounter, nil
+		l.firstNode = n
+	} else {
+		l.lastNode.nextNode = n
 	}
-	l.lastNode.nextNode = newNode
-	l.lastNode = l.lastNode.nextNode
-	return l.idCounter - 1, nil
+	l.lastNode = n
+	l.index[n.id] = n
+	l.length++
 }
 
-// RemoveByID удаляет элемент по уникальному идентификатору
-func (l *List) RemoveByID(id int64) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	// Случай out-of-range идентификатора
-	if id > l.idCounter || id < l.idInitial {
-		return
+// unlink исключает узел из списка и из индекса
+func (l *List) unlink(n *node) {
+	l.detach(n)
+	delete(l.index, n.id)
+	l.length--
+	// Сброс типа элементов
+	if l.length == 0 {
+		l.V = nil
 	}
+}
 
-	// Случай попытки удаления из пустого списка
-	if l.firstNode == nil {
-		return
+// detach исключает узел из цепочки, не меняя индекс и длину списка
+func (l *List) detach(n *node) {
+	if n.prevNode == nil {
+		l.firstNode = n.nextNode
+	} else {
+		n.prevNode.nextNode = n.nextNode
 	}
-
-	// Случай удаления первого элемента
-	if l.firstNode.id == id {
-		// Случай удаления первого элемента из двух оставшихся
-		if l.firstNode.nextNode == l.lastNode {
-			l.lastNode = l.firstNode
-		}
-		l.firstNode = l.firstNode.nextNode
-		// Случай удаления единственного элемента
-		if l.firstNode == nil {
-			l.lastNode = nil
-		}
-		l.length--
-		// Сброс типа элементов
-		if l.length == 0 {
-			l.V = nil
-		}
-		return
+	if n.nextNode == nil {
+		l.lastNode = n.prevNode
+	} else {
+		n.nextNode.prevNode = n.prevNode
 	}
+	n.prevNode = nil
+	n.nextNode = nil
+}
 
-	// Проходимся по узлам и останавливаемся на нужном
-	prevNode := l.firstNode
-	for ; prevNode.nextNode != nil && prevNode.nextNode.id != id; prevNode = prevNode.nextNode {
-	}
-	// Прошли через весь список и не нашли нужный узел
-	if prevNode.nextNode == nil {
-		return
+// attachBefore вставляет исключенный из цепочки узел перед узлом anchor
+func (l *List) attachBefore(n *node, anchor *node) {
+	n.prevNode = anchor.prevNode
+	n.nextNode = anchor
+	if anchor.prevNode == nil {
+		l.firstNode = n
+	} else {
+		anchor.prevNode.nextNode = n
 	}
-	// Случай удаления последнего элемента
-	if prevNode.nextNode == l.lastNode {
-		l.lastNode = prevNode
-		l.lastNode.nextNode = nil
+	anchor.prevNode = n
+}
+
+// attachAfter вставляет исключенный из цепочки узел после узла anchor
+func (l *List) attachAfter(n *node, anchor *node) {
+	n.prevNode = anchor
+	n.nextNode = anchor.nextNode
+	if anchor.nextNode == nil {
+		l.lastNode = n
 	} else {
-		prevNode.nextNode = prevNode.nextNode.nextNode
+		anchor.nextNode.prevNode = n
 	}
-	l.length--
-	// Сброс типа элементов
-	if l.length == 0 {
-		l.V = nil
+	anchor.nextNode = n
+}
+
+// RemoveByID удаляет элемент по уникальному идентификатору
+func (l *List) RemoveByID(id int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeByIDUnsafely(id)
+}
+
+// removeByIDUnsafely удаляет элемент по идентификатору и возвращает false, если элемента с таким идентификатором нет
+func (l *List) removeByIDUnsafely(id int64) bool {
+	n, ok := l.index[id]
+	if !ok {
+		return false
 	}
-	return
+	l.unlink(n)
+	return true
 }
 
 // RemoveByValue удаляет первый встретившийся элемент с данным значением
@@ -128,49 +152,12 @@ func (l *List) RemoveByValue(value any) {
 }
 
 func (l *List) removeByValueUnsafely(value any) {
-	// Случай попытки удаления из пустого списка
-	if l.firstNode == nil {
-		return
-	}
-
-	// Случай удаления первого элемента
-	if l.firstNode.value == value {
-		// Случай удаления первого элемента из двух оставшихся
-		if l.firstNode.nextNode == l.lastNode {
-			l.lastNode = l.firstNode
-		}
-		l.firstNode = l.firstNode.nextNode
-		// Случай удаления единственного элемента
-		if l.firstNode == nil {
-			l.lastNode = nil
-		}
-		l.length--
-		// Сброс типа элементов
-		if l.length == 0 {
-			l.V = nil
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
+		if currentNode.value == value {
+			l.unlink(currentNode)
+			return
 		}
-		return
 	}
-
-	// Проходимся по узлам и останавливаемся на нужном
-	currentNode := l.firstNode
-	for ; currentNode.nextNode != nil && currentNode.nextNode.value != value; currentNode = currentNode.nextNode {
-	}
-	// Прошли через весь список и не нашли нужный узел
-	if currentNode.nextNode == nil {
-		return
-	}
-	// Случай удаления предпоследнего элемента
-	if currentNode.nextNode == l.lastNode {
-		l.lastNode = currentNode
-	}
-	currentNode.nextNode = currentNode.nextNode.nextNode
-	l.length--
-	// Сброс типа элементов
-	if l.length == 0 {
-		l.V = nil
-	}
-	return
 }
 
 // RemoveAllByValue удаляет все элементы с данным значением
@@ -178,10 +165,12 @@ func (l *List) RemoveAllByValue(value any) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
+	for currentNode := l.firstNode; currentNode != nil; {
+		nextNode := currentNode.nextNode
 		if currentNode.value == value {
-			l.removeByValueUnsafely(currentNode.value)
+			l.unlink(currentNode)
 		}
+		currentNode = nextNode
 	}
 }
 
@@ -190,17 +179,11 @@ func (l *List) GetByID(id int64) (value any, ok bool) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
-	if id > l.idCounter || id < l.idInitial {
+	n, ok := l.index[id]
+	if !ok {
 		return 0, false
 	}
-
-	currentNode := l.firstNode
-	for ; currentNode.id != id; currentNode = currentNode.nextNode {
-		if currentNode.nextNode == nil {
-			return 0, false
-		}
-	}
-	return currentNode.value, true
+	return n.value, true
 }
 
 // GetByValue возвращает идентификатор первого по порядку элемента с данным значением
@@ -242,6 +225,10 @@ func (l *List) UpdateByID(id int64, value any) (bool, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	return l.updateByIDUnsafely(id, value)
+}
+
+func (l *List) updateByIDUnsafely(id int64, value any) (bool, error) {
 	// Случай пустого списка
 	if l.length == 0 {
 		return false, nil
@@ -252,16 +239,12 @@ func (l *List) UpdateByID(id int64, value any) (bool, error) {
 		return false, storage.ErrMismatchType
 	}
 
-	// Проходимся по узлам
-	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
-		if currentNode.id == id {
-			currentNode.value = value
-			return true, nil
-		}
+	n, ok := l.index[id]
+	if !ok {
+		return false, nil
 	}
-
-	// Прошли по всем узлам и не нашли нужный
-	return false, nil
+	n.value = value
+	return true, nil
 }
 
 // GetAll возвращает все элементы списка в виде map[int64]any. Если список пуст, возвращает nil и false.
@@ -282,6 +265,224 @@ func (l *List) GetAll() (map[int64]any, bool) {
 	return mp, true
 }
 
+// Find возвращает не более limit элементов, для которых predicate возвращает true.
+// Элементы перебираются в порядке списка. Если таких элементов нет, возвращает nil и false.
+func (l *List) Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	found := make(map[int64]any)
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
+		if limit > 0 && len(found) >= limit {
+			break
+		}
+		if predicate(currentNode.id, currentNode.value) {
+			found[currentNode.id] = currentNode.value
+		}
+	}
+	// Случай отсутствия подходящих элементов
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found, true
+}
+
+// FindAll возвращает все элементы, для которых predicate возвращает true.
+// Если таких элементов нет, возвращает nil и false.
+func (l *List) FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool) {
+	return l.Find(predicate, 0)
+}
+
+// RemoveWhere удаляет все элементы, для которых predicate возвращает true, и возвращает количество удаленных
+func (l *List) RemoveWhere(predicate func(id int64, v any) bool) (removed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for currentNode := l.firstNode; currentNode != nil; {
+		nextNode := currentNode.nextNode
+		if predicate(currentNode.id, currentNode.value) {
+			l.unlink(currentNode)
+			removed++
+		}
+		currentNode = nextNode
+	}
+	return removed
+}
+
+// AddMany добавляет элементы в конец списка за одну блокировку и возвращает результат для каждого элемента
+func (l *List) AddMany(values []any) []storage.Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	results := make([]storage.Result, len(values))
+	for i, value := range values {
+		id, err := l.addUnsafely(value)
+		results[i] = storage.Result{ID: id, OK: err == nil, Err: err}
+	}
+	return results
+}
+
+// UpdateMany обновляет элементы списка за одну блокировку и возвращает результат для каждого элемента
+func (l *List) UpdateMany(items []storage.Item) []storage.Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	results := make([]storage.Result, len(items))
+	for i, item := range items {
+		ok, err := l.updateByIDUnsafely(item.ID, item.Value)
+		results[i] = storage.Result{ID: item.ID, OK: ok, Err: err}
+	}
+	return results
+}
+
+// RemoveMany удаляет элементы списка за одну блокировку и возвращает результат для каждого идентификатора
+func (l *List) RemoveMany(ids []int64) []storage.Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	results := make([]storage.Result, len(ids))
+	for i, id := range ids {
+		results[i] = storage.Result{ID: id, OK: l.removeByIDUnsafely(id)}
+	}
+	return results
+}
+
+// MoveBefore перемещает элемент id непосредственно перед элементом anchorID.
+// Если одного из элементов нет, возвращает false.
+func (l *List) MoveBefore(id int64, anchorID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, ok := l.index[id]
+	anchor, anchorOk := l.index[anchorID]
+	if !ok || !anchorOk {
+		return false
+	}
+	// Случай перемещения элемента относительно самого себя
+	if n == anchor {
+		return true
+	}
+	l.detach(n)
+	l.attachBefore(n, anchor)
+	return true
+}
+
+// MoveAfter перемещает элемент id непосредственно после элемента anchorID.
+// Если одного из элементов нет, возвращает false.
+func (l *List) MoveAfter(id int64, anchorID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, ok := l.index[id]
+	anchor, anchorOk := l.index[anchorID]
+	if !ok || !anchorOk {
+		return false
+	}
+	// Случай перемещения элемента относительно самого себя
+	if n == anchor {
+		return true
+	}
+	l.detach(n)
+	l.attachAfter(n, anchor)
+	return true
+}
+
+// MoveToFront перемещает элемент в начало списка. Если элемента нет, возвращает false.
+func (l *List) MoveToFront(id int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, ok := l.index[id]
+	if !ok {
+		return false
+	}
+	if n == l.firstNode {
+		return true
+	}
+	l.detach(n)
+	l.attachBefore(n, l.firstNode)
+	return true
+}
+
+// MoveToBack перемещает элемент в конец списка. Если элемента нет, возвращает false.
+func (l *List) MoveToBack(id int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, ok := l.index[id]
+	if !ok {
+		return false
+	}
+	if n == l.lastNode {
+		return true
+	}
+	l.detach(n)
+	l.attachAfter(n, l.lastNode)
+	return true
+}
+
+// GetAllOrdered возвращает все элементы в порядке списка. Если список пуст, возвращает nil и false.
+func (l *List) GetAllOrdered() ([]storage.Item, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// Случай пустого списка
+	if l.length == 0 {
+		return nil, false
+	}
+
+	items := make([]storage.Item, 0, l.length)
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
+		items = append(items, storage.Item{ID: currentNode.id, Value: currentNode.value})
+	}
+	return items, true
+}
+
+// Insert добавляет элемент с указанным идентификатором в конец списка
+func (l *List) Insert(id int64, value any) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.index[id]; ok {
+		return storage.ErrIDExists
+	}
+
+	// Согласование типа элементов
+	if l.V == nil {
+		l.V = reflect.TypeOf(value)
+	} else if l.V != reflect.TypeOf(value) {
+		return storage.ErrMismatchType
+	}
+
+	l.linkLast(&node{id: id, value: value})
+	if id >= l.idCounter {
+		l.idCounter = id + 1
+	}
+	return nil
+}
+
+// NextID возвращает идентификатор следующего добавляемого элемента
+func (l *List) NextID() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.idCounter
+}
+
+// SetNextID устанавливает идентификатор следующего добавляемого элемента. Значение, не превышающее
+// наибольший идентификатор существующего элемента, игнорируется: иначе следующий Add перезаписал бы элемент.
+func (l *List) SetNextID(id int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for existing := range l.index {
+		if existing >= id {
+			return
+		}
+	}
+	l.idCounter = id
+}
+
 // Clear удаляет все элементы из списка
 func (l *List) Clear() {
 	l.mu.Lock()
@@ -290,6 +491,8 @@ func (l *List) Clear() {
 	l.length = 0
 	l.firstNode = nil
 	l.lastNode = nil
+	l.index = make(map[int64]*node)
+	l.V = nil
 	l.idCounter = l.idInitial
 }
 
@@ -0,0 +1,304 @@
+package list
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Сравнение списка с индексом по идентификаторам и прежнего односвязного списка,
+// в котором доступ к элементу по ID требовал прохода по узлам (O(n)).
+//
+//	go test -run '^$' -bench . ./gates/storage/list/
+
+// benchmarkSizes - размеры списков, на которых сравниваются реализации
+var benchmarkSizes = []int{1_000, 10_000, 100_000}
+
+// legacyNode - узел прежнего односвязного списка
+type legacyNode struct {
+	id       int64
+	value    any
+	nextNode *legacyNode
+}
+
+// legacyList - копия прежней реализации List (без индекса), оставленная для сравнения.
+// Блокировки и согласование типов опущены: они одинаковы в обеих реализациях.
+type legacyList struct {
+	length    int64
+	firstNode *legacyNode
+	lastNode  *legacyNode
+	idCounter int64
+}
+
+func (l *legacyList) Add(value any) int64 {
+	newNode := &legacyNode{id: l.idCounter, value: value}
+	l.idCounter++
+	l.length++
+	if l.firstNode == nil {
+		l.firstNode = newNode
+		l.lastNode = newNode
+		return newNode.id
+	}
+	l.lastNode.nextNode = newNode
+	l.lastNode = newNode
+	return newNode.id
+}
+
+func (l *legacyList) GetByID(id int64) (any, bool) {
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
+		if currentNode.id == id {
+			return currentNode.value, true
+		}
+	}
+	return nil, false
+}
+
+func (l *legacyList) UpdateByID(id int64, value any) bool {
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.nextNode {
+		if currentNode.id == id {
+			currentNode.value = value
+			return true
+		}
+	}
+	return false
+}
+
+// unlink удаляет узел с идентификатором id из списка и возвращает его
+func (l *legacyList) unlink(id int64) *legacyNode {
+	var prevNode *legacyNode
+	for currentNode := l.firstNode; currentNode != nil; prevNode, currentNode = currentNode, currentNode.nextNode {
+		if currentNode.id != id {
+			continue
+		}
+		if prevNode == nil {
+			l.firstNode = currentNode.nextNode
+		} else {
+			prevNode.nextNode = currentNode.nextNode
+		}
+		if currentNode == l.lastNode {
+			l.lastNode = prevNode
+		}
+		currentNode.nextNode = nil
+		l.length--
+		return currentNode
+	}
+	return nil
+}
+
+func (l *legacyList) RemoveByID(id int64) {
+	l.unlink(id)
+}
+
+// MoveAfter перемещает элемент id после элемента anchorID так, как это пришлось бы делать
+// без индекса: поиском обоих узлов проходом по списку
+func (l *legacyList) MoveAfter(id int64, anchorID int64) bool {
+	if id == anchorID {
+		return true
+	}
+	n := l.unlink(id)
+	if n == nil {
+		return false
+	}
+	for anchor := l.firstNode; anchor != nil; anchor = anchor.nextNode {
+		if anchor.id == anchorID {
+			n.nextNode = anchor.nextNode
+			anchor.nextNode = n
+			if anchor == l.lastNode {
+				l.lastNode = n
+			}
+			l.length++
+			return true
+		}
+	}
+	// Опорного элемента нет: узел возвращается в конец
+	l.lastNode.nextNode = n
+	l.lastNode = n
+	l.length++
+	return false
+}
+
+// benchmarkOps - операции, одинаково выраженные для обеих реализаций
+type benchmarkOps struct {
+	add        func(value any) int64
+	getByID    func(id int64) (any, bool)
+	updateByID func(id int64, value any) bool
+	removeByID func(id int64)
+	moveAfter  func(id int64, anchorID int64) bool
+}
+
+func indexedOps() benchmarkOps {
+	l := NewList(1)
+	return benchmarkOps{
+		add: func(value any) int64 {
+			id, _ := l.Add(value)
+			return id
+		},
+		getByID: l.GetByID,
+		updateByID: func(id int64, value any) bool {
+			ok, _ := l.UpdateByID(id, value)
+			return ok
+		},
+		removeByID: l.RemoveByID,
+		moveAfter:  l.MoveAfter,
+	}
+}
+
+func legacyOps() benchmarkOps {
+	l := &legacyList{idCounter: 1}
+	return benchmarkOps{
+		add:        l.Add,
+		getByID:    l.GetByID,
+		updateByID: l.UpdateByID,
+		removeByID: l.RemoveByID,
+		moveAfter:  l.MoveAfter,
+	}
+}
+
+// fill добавляет в список size элементов и возвращает их идентификаторы
+func fill(ops benchmarkOps, size int) []int64 {
+	ids := make([]int64, size)
+	for i := range ids {
+		ids[i] = ops.add(i)
+	}
+	return ids
+}
+
+// pick возвращает i-й элемент псевдослучайной последовательности индексов из [0, size):
+// обращения распределяются по всему списку, а не только к его началу
+func pick(i int, size int) int {
+	return (i*7919 + size/2) % size
+}
+
+// randomIndexes возвращает count случайных индексов из [0, size) с фиксированным зерном.
+// В отличие от pick, последовательность не повторяется с периодом size: иначе в BenchmarkRemoveByID
+// элементы, добавленные в конец на предыдущем проходе, к следующему проходу оказывались бы в начале списка.
+func randomIndexes(count int, size int) []int {
+	random := rand.New(rand.NewSource(1))
+	indexes := make([]int, count)
+	for i := range indexes {
+		indexes[i] = random.Intn(size)
+	}
+	return indexes
+}
+
+// benchmarkIndexes - количество заранее подготовленных случайных индексов (степень двойки)
+const benchmarkIndexes = 1 << 16
+
+// runBenchmark запускает bench для обеих реализаций на всех размерах списков
+func runBenchmark(b *testing.B, bench func(b *testing.B, ops benchmarkOps, size int)) {
+	implementations := []struct {
+		name string
+		ops  func() benchmarkOps
+	}{
+		{"indexed", indexedOps},
+		{"legacy", legacyOps},
+	}
+	for _, size := range benchmarkSizes {
+		for _, impl := range implementations {
+			b.Run(fmt.Sprintf("%s/n=%d", impl.name, size), func(b *testing.B) {
+				bench(b, impl.ops(), size)
+			})
+		}
+	}
+}
+
+func BenchmarkGetByID(b *testing.B) {
+	runBenchmark(b, func(b *testing.B, ops benchmarkOps, size int) {
+		ids := fill(ops, size)
+		indexes := randomIndexes(benchmarkIndexes, size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, ok := ops.getByID(ids[indexes[i%benchmarkIndexes]]); !ok {
+				b.Fatal("element not found")
+			}
+		}
+	})
+}
+
+func BenchmarkUpdateByID(b *testing.B) {
+	runBenchmark(b, func(b *testing.B, ops benchmarkOps, size int) {
+		ids := fill(ops, size)
+		indexes := randomIndexes(benchmarkIndexes, size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !ops.updateByID(ids[indexes[i%benchmarkIndexes]], i) {
+				b.Fatal("element not found")
+			}
+		}
+	})
+}
+
+// BenchmarkRemoveByID удаляет элемент и добавляет новый вместо него, чтобы размер списка не менялся
+func BenchmarkRemoveByID(b *testing.B) {
+	runBenchmark(b, func(b *testing.B, ops benchmarkOps, size int) {
+		ids := fill(ops, size)
+		indexes := randomIndexes(benchmarkIndexes, size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			k := indexes[i%benchmarkIndexes]
+			ops.removeByID(ids[k])
+			ids[k] = ops.add(i)
+		}
+	})
+}
+
+func BenchmarkMoveAfter(b *testing.B) {
+	runBenchmark(b, func(b *testing.B, ops benchmarkOps, size int) {
+		ids := fill(ops, size)
+		indexes := randomIndexes(benchmarkIndexes, size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !ops.moveAfter(ids[indexes[i%benchmarkIndexes]], ids[indexes[(i+1)%benchmarkIndexes]]) {
+				b.Fatal("element not found")
+			}
+		}
+	})
+}
+
+// TestIndexedMatchesLegacy проверяет, что список с индексом хранит те же элементы в том же порядке,
+// что и прежняя реализация, после одинаковой последовательности операций
+func TestIndexedMatchesLegacy(t *testing.T) {
+	const size = 200
+	indexed := NewList(1)
+	legacy := &legacyList{idCounter: 1}
+	ids := make([]int64, size)
+	for i := range ids {
+		ids[i], _ = indexed.Add(i)
+		legacy.Add(i)
+	}
+
+	for i := 0; i < 1000; i++ {
+		k := pick(i, size)
+		id, anchorID := ids[k], ids[pick(i+3, size)]
+		switch i % 3 {
+		case 0:
+			if indexed.MoveAfter(id, anchorID) != legacy.MoveAfter(id, anchorID) {
+				t.Fatalf("MoveAfter(%d, %d) results differ", id, anchorID)
+			}
+		case 1:
+			ok, _ := indexed.UpdateByID(id, -i)
+			if ok != legacy.UpdateByID(id, -i) {
+				t.Fatalf("UpdateByID(%d) results differ", id)
+			}
+		case 2:
+			indexed.RemoveByID(id)
+			legacy.RemoveByID(id)
+			ids[k], _ = indexed.Add(i)
+			if legacyID := legacy.Add(i); legacyID != ids[k] {
+				t.Fatalf("Add() ids differ: %d != %d", ids[k], legacyID)
+			}
+		}
+	}
+
+	items, _ := indexed.GetAllOrdered()
+	if int64(len(items)) != legacy.length {
+		t.Fatalf("got %d items, legacy has %d", len(items), legacy.length)
+	}
+	legacyNode := legacy.firstNode
+	for _, item := range items {
+		if item.ID != legacyNode.id || item.Value != legacyNode.value {
+			t.Fatalf("item {%d %v} differs from legacy {%d %v}", item.ID, item.Value, legacyNode.id, legacyNode.value)
+		}
+		legacyNode = legacyNode.nextNode
+	}
+}
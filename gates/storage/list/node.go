@@ -3,5 +3,6 @@ package list
 type node struct {
 	id       int64 // Уникальный идентификатор узла, может не совпадать с порядковым номером (индексом)
 	value    any
+	prevNode *node
 	nextNode *node
 }
@@ -0,0 +1,375 @@
+package migration
+
+import (
+	"errors"
+	"notesServer/gates/storage"
+	"sync"
+	"time"
+)
+
+// DualWriter - хранилище, которое на время миграции применяет каждое изменение и к источнику, и к цели.
+// Чтение производится из источника. Изменения выполняются по одному, поэтому обе стороны видят их в одном порядке.
+// Ошибки записи в цель не возвращаются вызывающему: расхождения обнаруживаются на шаге проверки миграции.
+type DualWriter struct {
+	source       storage.Migratable
+	target       storage.Migratable
+	targetErrors int // количество неудачных записей в цель
+	mu           sync.Mutex
+}
+
+// NewDualWriter возвращает хранилище, записывающее изменения в source и target
+func NewDualWriter(source storage.Migratable, target storage.Migratable) *DualWriter {
+	return &DualWriter{source: source, target: target}
+}
+
+// TargetErrors возвращает количество изменений, которые не удалось применить к цели
+func (dw *DualWriter) TargetErrors() int {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	return dw.targetErrors
+}
+
+// Len возвращает количество элементов в источнике
+func (dw *DualWriter) Len() int64 {
+	return dw.source.Len()
+}
+
+// Add добавляет элемент в источник, а затем в цель с тем же идентификатором
+func (dw *DualWriter) Add(value any) (int64, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	id, err := dw.source.Add(value)
+	if err != nil {
+		return id, err
+	}
+	dw.upsertTarget(id, value)
+	return id, nil
+}
+
+// RemoveByID удаляет элемент из источника и из цели
+func (dw *DualWriter) RemoveByID(id int64) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.source.RemoveByID(id)
+	dw.target.RemoveByID(id)
+}
+
+// RemoveByValue удаляет из обоих хранилищ элемент, найденный по значению в источнике
+func (dw *DualWriter) RemoveByValue(value any) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	id, ok := dw.source.GetByValue(value)
+	if !ok {
+		return
+	}
+	dw.source.RemoveByID(id)
+	dw.target.RemoveByID(id)
+}
+
+// RemoveAllByValue удаляет из обоих хранилищ все элементы, найденные по значению в источнике
+func (dw *DualWriter) RemoveAllByValue(value any) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	ids, ok := dw.source.GetAllByValue(value)
+	if !ok {
+		return
+	}
+	dw.source.RemoveMany(ids)
+	dw.target.RemoveMany(ids)
+}
+
+// GetByID возвращает значение элемента из источника
+func (dw *DualWriter) GetByID(id int64) (any, bool) {
+	return dw.source.GetByID(id)
+}
+
+// GetByValue возвращает идентификатор элемента из источника
+func (dw *DualWriter) GetByValue(value any) (int64, bool) {
+	return dw.source.GetByValue(value)
+}
+
+// GetAllByValue возвращает идентификаторы элементов из источника
+func (dw *DualWriter) GetAllByValue(value any) ([]int64, bool) {
+	return dw.source.GetAllByValue(value)
+}
+
+// UpdateByID обновляет элемент в источнике, а затем в цели
+func (dw *DualWriter) UpdateByID(id int64, value any) (bool, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	ok, err := dw.source.UpdateByID(id, value)
+	if !ok || err != nil {
+		return ok, err
+	}
+	dw.upsertTarget(id, value)
+	return true, nil
+}
+
+// GetAll возвращает все элементы источника
+func (dw *DualWriter) GetAll() (map[int64]any, bool) {
+	return dw.source.GetAll()
+}
+
+// Find возвращает найденные элементы источника
+func (dw *DualWriter) Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool) {
+	return dw.source.Find(predicate, limit)
+}
+
+// FindAll возвращает все найденные элементы источника
+func (dw *DualWriter) FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool) {
+	return dw.source.FindAll(predicate)
+}
+
+// RemoveWhere удаляет из обоих хранилищ элементы, найденные в источнике
+func (dw *DualWriter) RemoveWhere(predicate func(id int64, v any) bool) int64 {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	found, ok := dw.source.FindAll(predicate)
+	if !ok {
+		return 0
+	}
+	ids := make([]int64, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	dw.source.RemoveMany(ids)
+	dw.target.RemoveMany(ids)
+	return int64(len(ids))
+}
+
+// AddMany добавляет элементы в источник, а затем добавленные - в цель с теми же идентификаторами
+func (dw *DualWriter) AddMany(values []any) []storage.Result {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	results := dw.source.AddMany(values)
+	for i, result := range results {
+		if result.OK {
+			dw.upsertTarget(result.ID, values[i])
+		}
+	}
+	return results
+}
+
+// UpdateMany обновляет элементы в источнике, а затем обновленные - в цели
+func (dw *DualWriter) UpdateMany(items []storage.Item) []storage.Result {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	results := dw.source.UpdateMany(items)
+	for i, result := range results {
+		if result.OK {
+			dw.upsertTarget(result.ID, items[i].Value)
+		}
+	}
+	return results
+}
+
+// RemoveMany удаляет элементы из источника и из цели
+func (dw *DualWriter) RemoveMany(ids []int64) []storage.Result {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	results := dw.source.RemoveMany(ids)
+	dw.target.RemoveMany(ids)
+	return results
+}
+
+// Clear очищает оба хранилища
+func (dw *DualWriter) Clear() {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.source.Clear()
+	dw.target.Clear()
+	dw.target.SetNextID(dw.source.NextID())
+}
+
+// Print выводит содержимое источника в консоль
+func (dw *DualWriter) Print() {
+	dw.source.Print()
+}
+
+// upsertTarget записывает значение элемента в цель: обновляет существующий элемент или вставляет новый.
+// Вызывается под dw.mu.
+func (dw *DualWriter) upsertTarget(id int64, value any) {
+	ok, err := dw.target.UpdateByID(id, value)
+	if err == nil && !ok {
+		err = dw.target.Insert(id, value)
+	}
+	if err != nil {
+		dw.targetErrors++
+	}
+}
+
+// copyItem переносит в цель текущее значение элемента источника, если цель еще не получила его через двойную запись
+func (dw *DualWriter) copyItem(id int64) error {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	// Элемент мог быть удален после начала копирования
+	value, ok := dw.source.GetByID(id)
+	if !ok {
+		return nil
+	}
+	err := dw.target.Insert(id, value)
+	if errors.Is(err, storage.ErrIDExists) {
+		return nil
+	}
+	return err
+}
+
+// syncNextID выставляет цели тот же счетчик идентификаторов, что и у источника
+func (dw *DualWriter) syncNextID() {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.target.SetNextID(dw.source.NextID())
+}
+
+// Storage возвращает DualWriter в виде хранилища, реализующего storage.Ordered и storage.Versioned,
+// если их реализует источник. Без этого на время миграции из списка перестал бы работать
+// пользовательский порядок заметок, а из многоверсионного хранилища - чтение прошлых состояний.
+func (dw *DualWriter) Storage() storage.Storage {
+	sourceOrdered, isOrdered := dw.source.(storage.Ordered)
+	targetOrdered, _ := dw.target.(storage.Ordered)
+	sourceVersioned, isVersioned := dw.source.(storage.Versioned)
+	o := dualOrdered{dw: dw, source: sourceOrdered, target: targetOrdered}
+	v := dualVersioned{source: sourceVersioned}
+
+	switch {
+	case isOrdered && isVersioned:
+		return &struct {
+			*DualWriter
+			dualOrdered
+			dualVersioned
+		}{dw, o, v}
+	case isOrdered:
+		return &struct {
+			*DualWriter
+			dualOrdered
+		}{dw, o}
+	case isVersioned:
+		return &struct {
+			*DualWriter
+			dualVersioned
+		}{dw, v}
+	default:
+		return dw
+	}
+}
+
+// dualOrdered - методы storage.Ordered для DualWriter: перемещение применяется к источнику,
+// а затем к цели, если она тоже хранит порядок. Порядок элементов, которые цель еще не получила,
+// переносится после копирования (см. Migrator.Copy).
+type dualOrdered struct {
+	dw     *DualWriter
+	source storage.Ordered
+	target storage.Ordered // nil, если цель не хранит порядок
+}
+
+// MoveBefore перемещает элемент перед элементом anchorID в источнике и в цели
+func (o dualOrdered) MoveBefore(id int64, anchorID int64) bool {
+	o.dw.mu.Lock()
+	defer o.dw.mu.Unlock()
+
+	moved := o.source.MoveBefore(id, anchorID)
+	if moved && o.target != nil {
+		o.target.MoveBefore(id, anchorID)
+	}
+	return moved
+}
+
+// MoveAfter перемещает элемент после элемента anchorID в источнике и в цели
+func (o dualOrdered) MoveAfter(id int64, anchorID int64) bool {
+	o.dw.mu.Lock()
+	defer o.dw.mu.Unlock()
+
+	moved := o.source.MoveAfter(id, anchorID)
+	if moved && o.target != nil {
+		o.target.MoveAfter(id, anchorID)
+	}
+	return moved
+}
+
+// MoveToFront перемещает элемент в начало источника и цели
+func (o dualOrdered) MoveToFront(id int64) bool {
+	o.dw.mu.Lock()
+	defer o.dw.mu.Unlock()
+
+	moved := o.source.MoveToFront(id)
+	if moved && o.target != nil {
+		o.target.MoveToFront(id)
+	}
+	return moved
+}
+
+// MoveToBack перемещает элемент в конец источника и цели
+func (o dualOrdered) MoveToBack(id int64) bool {
+	o.dw.mu.Lock()
+	defer o.dw.mu.Unlock()
+
+	moved := o.source.MoveToBack(id)
+	if moved && o.target != nil {
+		o.target.MoveToBack(id)
+	}
+	return moved
+}
+
+// GetAllOrdered возвращает все элементы источника в пользовательском порядке
+func (o dualOrdered) GetAllOrdered() ([]storage.Item, bool) {
+	return o.source.GetAllOrdered()
+}
+
+// dualVersioned - методы storage.Versioned для DualWriter: прошлые состояния читаются из источника
+type dualVersioned struct {
+	source storage.Versioned
+}
+
+// Snapshot фиксирует текущее состояние источника
+func (v dualVersioned) Snapshot() storage.Snapshot {
+	return v.source.Snapshot()
+}
+
+// SnapshotAsOf возвращает снимок состояния источника на момент t
+func (v dualVersioned) SnapshotAsOf(t time.Time) (storage.Snapshot, error) {
+	return v.source.SnapshotAsOf(t)
+}
+
+// SnapshotByToken возвращает снимок состояния источника по токену
+func (v dualVersioned) SnapshotByToken(token int64) (storage.Snapshot, error) {
+	return v.source.SnapshotByToken(token)
+}
+
+// GetByIDAsOf возвращает значение элемента источника на момент t
+func (v dualVersioned) GetByIDAsOf(id int64, t time.Time) (any, bool, error) {
+	return v.source.GetByIDAsOf(id, t)
+}
+
+// GetAllAsOf возвращает все элементы источника на момент t
+func (v dualVersioned) GetAllAsOf(t time.Time) (map[int64]any, bool, error) {
+	return v.source.GetAllAsOf(t)
+}
+
+// syncOrder выставляет цели пользовательский порядок источника, если его хранят обе стороны
+func (dw *DualWriter) syncOrder() {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	source, ok := dw.source.(storage.Ordered)
+	target, targetOk := dw.target.(storage.Ordered)
+	if !ok || !targetOk {
+		return
+	}
+	items, _ := source.GetAllOrdered()
+	for _, item := range items {
+		target.MoveToBack(item.ID)
+	}
+}
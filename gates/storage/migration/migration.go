@@ -0,0 +1,146 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"notesServer/gates/storage"
+	"reflect"
+	"sort"
+)
+
+// Migrator переносит данные из одного хранилища в другое с сохранением идентификаторов и счетчика идентификаторов.
+//
+// Порядок миграции без остановки сервера:
+//  1. NewMigrator - создание мигратора;
+//  2. DualWriter().Storage() - хранилище, которым на время миграции заменяется источник: изменения пишутся в обе стороны;
+//  3. Run - копирование существующих элементов и проверка совпадения обеих сторон;
+//  4. если проверка прошла, источник заменяется целью, иначе - обратно источником.
+type Migrator struct {
+	source     storage.Migratable
+	target     storage.Migratable
+	dualWriter *DualWriter
+}
+
+// Report - результат проверки миграции
+type Report struct {
+	Copied       int     `json:"copied"`               // Количество скопированных элементов
+	SourceCount  int64   `json:"source_count"`         // Количество элементов в источнике
+	TargetCount  int64   `json:"target_count"`         // Количество элементов в цели
+	SourceNextID int64   `json:"source_next_id"`       // Идентификатор следующего элемента источника
+	TargetNextID int64   `json:"target_next_id"`       // Идентификатор следующего элемента цели
+	Missing      []int64 `json:"missing,omitempty"`    // Есть в источнике, но нет в цели
+	Extra        []int64 `json:"extra,omitempty"`      // Есть в цели, но нет в источнике
+	Mismatched   []int64 `json:"mismatched,omitempty"` // Есть в обоих хранилищах, но значения различаются
+	TargetErrors int     `json:"target_errors"`        // Количество неудачных записей в цель при двойной записи
+}
+
+// OK возвращает true, если обе стороны миграции совпадают
+func (r Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0 &&
+		r.SourceNextID == r.TargetNextID && r.TargetErrors == 0
+}
+
+// ErrVerificationFailed ошибка, возвращаемая методом Run, если после копирования хранилища различаются
+var ErrVerificationFailed = errors.New("migration verification failed: source and target differ")
+
+// NewMigrator возвращает мигратор из source в target.
+// Если source или target не поддерживают вставку с заданным ID, возвращается ошибка.
+func NewMigrator(source storage.Storage, target storage.Storage) (*Migrator, error) {
+	migratableSource, ok := source.(storage.Migratable)
+	if !ok {
+		return nil, errors.New("source storage does not support migration")
+	}
+	migratableTarget, ok := target.(storage.Migratable)
+	if !ok {
+		return nil, errors.New("target storage does not support migration")
+	}
+	if target.Len() != 0 {
+		return nil, errors.New("target storage is not empty")
+	}
+	return &Migrator{
+		source:     migratableSource,
+		target:     migratableTarget,
+		dualWriter: NewDualWriter(migratableSource, migratableTarget),
+	}, nil
+}
+
+// DualWriter возвращает хранилище, которое нужно использовать вместо источника на время миграции
+func (m *Migrator) DualWriter() *DualWriter {
+	return m.dualWriter
+}
+
+// Copy копирует в цель все элементы источника, которые цель еще не получила через двойную запись,
+// и переносит счетчик идентификаторов и пользовательский порядок элементов (если его хранят обе стороны).
+// Возвращает количество просмотренных элементов.
+func (m *Migrator) Copy() (int, error) {
+	all, _ := m.source.GetAll()
+	ids := make([]int64, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for i, id := range ids {
+		err := m.dualWriter.copyItem(id)
+		if err != nil {
+			return i, fmt.Errorf("cannot copy item %d: %w", id, err)
+		}
+	}
+	m.dualWriter.syncNextID()
+	m.dualWriter.syncOrder()
+	return len(ids), nil
+}
+
+// Verify сравнивает источник и цель. Значения сравниваются reflect.DeepEqual: элементы хранилищ
+// (например, пользователи и секреты ссылок) могут содержать срезы и карты, которые нельзя сравнить через ==.
+// На время сравнения изменения через DualWriter приостанавливаются.
+func (m *Migrator) Verify() Report {
+	m.dualWriter.mu.Lock()
+	defer m.dualWriter.mu.Unlock()
+
+	report := Report{
+		SourceCount:  m.source.Len(),
+		TargetCount:  m.target.Len(),
+		SourceNextID: m.source.NextID(),
+		TargetNextID: m.target.NextID(),
+		TargetErrors: m.dualWriter.targetErrors,
+	}
+
+	sourceAll, _ := m.source.GetAll()
+	targetAll, _ := m.target.GetAll()
+	for id, sourceValue := range sourceAll {
+		targetValue, ok := targetAll[id]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, id)
+		case !reflect.DeepEqual(targetValue, sourceValue):
+			report.Mismatched = append(report.Mismatched, id)
+		}
+	}
+	for id := range targetAll {
+		if _, ok := sourceAll[id]; !ok {
+			report.Extra = append(report.Extra, id)
+		}
+	}
+
+	for _, ids := range [][]int64{report.Missing, report.Extra, report.Mismatched} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return report
+}
+
+// Run копирует данные и проверяет результат.
+// Если хранилища после копирования различаются, возвращается отчет и ErrVerificationFailed.
+func (m *Migrator) Run() (Report, error) {
+	copied, err := m.Copy()
+	if err != nil {
+		return Report{Copied: copied}, err
+	}
+
+	report := m.Verify()
+	report.Copied = copied
+	if !report.OK() {
+		return report, ErrVerificationFailed
+	}
+	return report, nil
+}
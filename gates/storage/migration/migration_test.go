@@ -0,0 +1,79 @@
+package migration
+
+import (
+	"notesServer/gates/storage"
+	"notesServer/gates/storage/list"
+	"notesServer/gates/storage/mp"
+	"notesServer/gates/storage/mvcc"
+	"notesServer/models/entity"
+	"testing"
+	"time"
+)
+
+// Элементы с картами и срезами нельзя сравнить через ==, проверка миграции не должна на них паниковать
+func TestVerifyRecordsWithMaps(t *testing.T) {
+	source := mp.NewMap(1)
+	for noteID := int64(1); noteID <= 3; noteID++ {
+		_, err := source.Add(entity.LinkSecret{
+			NoteID:    noteID,
+			Secret:    []byte{byte(noteID)},
+			RotatedAt: time.Unix(1700000000, 0),
+			Used:      map[string]time.Time{"nonce": time.Unix(1700000600, 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := list.NewList(1)
+	migrator, err := NewMigrator(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := migrator.Run()
+	if err != nil || report.Copied != 3 {
+		t.Fatalf("Run() = %+v, %v", report, err)
+	}
+
+	// Различие внутри карты обнаруживается
+	changed, _ := target.GetByID(2)
+	secret := changed.(entity.LinkSecret)
+	secret.Used = map[string]time.Time{"other": time.Unix(1700000600, 0)}
+	if _, err = target.UpdateByID(2, secret); err != nil {
+		t.Fatal(err)
+	}
+	report = migrator.Verify()
+	if report.OK() || len(report.Mismatched) != 1 || report.Mismatched[0] != 2 {
+		t.Fatalf("Verify() after changing a map = %+v, want item 2 mismatched", report)
+	}
+}
+
+// Счетчик идентификаторов нельзя опустить до существующего элемента: следующий Add перезаписал бы его
+func TestSetNextIDBelowExistingIDs(t *testing.T) {
+	backends := map[string]storage.Migratable{
+		"map":  mp.NewMap(1),
+		"list": list.NewList(1),
+		"mvcc": mvcc.NewMVCC(1, time.Hour),
+	}
+	for name, st := range backends {
+		for _, note := range []string{"a", "b", "c"} {
+			if _, err := st.Add(note); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, id := range []int64{1, 3} {
+			st.SetNextID(id)
+			if next := st.NextID(); next != 4 {
+				t.Errorf("%s: NextID() after SetNextID(%d) = %d, want 4", name, id, next)
+			}
+		}
+		id, _ := st.Add("d")
+		if value, _ := st.GetByID(3); id != 4 || value != "c" {
+			t.Errorf("%s: Add() after SetNextID() = %d, item 3 = %v", name, id, value)
+		}
+
+		st.SetNextID(10)
+		if id, _ = st.Add("e"); id != 10 {
+			t.Errorf("%s: Add() after SetNextID(10) = %d, want 10", name, id)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"notesServer/gates/storage"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -189,6 +190,180 @@ func (m *Map) GetAll() (map[int64]any, bool) {
 	return mpCopy, true
 }
 
+// Find возвращает не более limit элементов, для которых predicate возвращает true.
+// При ограничении количества элементы перебираются по возрастанию идентификатора.
+// Если таких элементов нет, возвращается nil и false.
+func (m *Map) Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := make(map[int64]any)
+	if limit <= 0 {
+		for k, v := range m.mp {
+			if predicate(k, v) {
+				found[k] = v
+			}
+		}
+	} else {
+		ids := make([]int64, 0, len(m.mp))
+		for k := range m.mp {
+			ids = append(ids, k)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, k := range ids {
+			if len(found) >= limit {
+				break
+			}
+			if predicate(k, m.mp[k]) {
+				found[k] = m.mp[k]
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found, true
+}
+
+// FindAll возвращает все элементы, для которых predicate возвращает true.
+// Если таких элементов нет, возвращается nil и false.
+func (m *Map) FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool) {
+	return m.Find(predicate, 0)
+}
+
+// RemoveWhere удаляет все элементы, для которых predicate возвращает true, и возвращает количество удаленных
+func (m *Map) RemoveWhere(predicate func(id int64, v any) bool) (removed int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, v := range m.mp {
+		if predicate(k, v) {
+			delete(m.mp, k)
+			removed++
+		}
+	}
+
+	// Сброс типа элементов
+	if len(m.mp) == 0 {
+		m.V = nil
+	}
+	return removed
+}
+
+// AddMany добавляет значения в таблицу за одну блокировку и возвращает результат для каждого значения
+func (m *Map) AddMany(values []any) []storage.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]storage.Result, len(values))
+	for i, value := range values {
+		// Согласование типа элементов
+		if m.V == nil {
+			m.V = reflect.TypeOf(value)
+		} else if m.V != reflect.TypeOf(value) {
+			results[i] = storage.Result{Err: storage.ErrMismatchType}
+			continue
+		}
+
+		m.mp[m.idCounter] = value
+		results[i] = storage.Result{ID: m.idCounter, OK: true}
+		m.idCounter++
+	}
+	return results
+}
+
+// UpdateMany обновляет элементы таблицы за одну блокировку и возвращает результат для каждого элемента
+func (m *Map) UpdateMany(items []storage.Item) []storage.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]storage.Result, len(items))
+	for i, item := range items {
+		results[i].ID = item.ID
+
+		// Согласование типа элементов
+		if m.V != reflect.TypeOf(item.Value) {
+			results[i].Err = storage.ErrMismatchType
+			continue
+		}
+
+		if _, ok := m.mp[item.ID]; !ok {
+			continue
+		}
+		m.mp[item.ID] = item.Value
+		results[i].OK = true
+	}
+	return results
+}
+
+// RemoveMany удаляет элементы таблицы за одну блокировку и возвращает результат для каждого идентификатора
+func (m *Map) RemoveMany(ids []int64) []storage.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]storage.Result, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+		if _, ok := m.mp[id]; !ok {
+			continue
+		}
+		delete(m.mp, id)
+		results[i].OK = true
+	}
+
+	// Сброс типа элементов
+	if len(m.mp) == 0 {
+		m.V = nil
+	}
+	return results
+}
+
+// Insert добавляет значение в таблицу с указанным идентификатором
+func (m *Map) Insert(id int64, value any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.mp[id]; ok {
+		return storage.ErrIDExists
+	}
+
+	// Согласование типа элементов
+	if m.V == nil {
+		m.V = reflect.TypeOf(value)
+	} else if m.V != reflect.TypeOf(value) {
+		return storage.ErrMismatchType
+	}
+
+	m.mp[id] = value
+	if id >= m.idCounter {
+		m.idCounter = id + 1
+	}
+	return nil
+}
+
+// NextID возвращает идентификатор следующего добавляемого элемента
+func (m *Map) NextID() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.idCounter
+}
+
+// SetNextID устанавливает идентификатор следующего добавляемого элемента. Значение, не превышающее
+// наибольший идентификатор существующего элемента, игнорируется: иначе следующий Add перезаписал бы элемент.
+func (m *Map) SetNextID(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for existing := range m.mp {
+		if existing >= id {
+			return
+		}
+	}
+	m.idCounter = id
+}
+
 // Clear очищает таблицу
 func (m *Map) Clear() {
 	m.mu.Lock()
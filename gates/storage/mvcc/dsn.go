@@ -0,0 +1,44 @@
+package mvcc
+
+import (
+	"errors"
+	"notesServer/gates/storage"
+	"time"
+)
+
+// Регистрация многоверсионного хранилища под схемой "mvcc". Параметры строки подключения:
+//
+//	init_id     - идентификатор первого добавляемого элемента (по умолчанию 1)
+//	retention   - окно хранения старых версий (по умолчанию 24h)
+//	gc_interval - интервал сборки мусора (по умолчанию 1m, 0 - сборка мусора не запускается)
+func init() {
+	storage.Register("mvcc", func(path string, opts *storage.Options) (storage.Storage, error) {
+		if path != "" {
+			return nil, storage.ErrPathNotSupported
+		}
+		initID, err := opts.Int64("init_id", 1)
+		if err != nil {
+			return nil, err
+		}
+		retention, err := opts.Duration("retention", 24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		if retention < 0 {
+			return nil, errors.New("option retention must not be negative")
+		}
+		gcInterval, err := opts.Duration("gc_interval", time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		if gcInterval < 0 {
+			return nil, errors.New("option gc_interval must not be negative")
+		}
+
+		m := NewMVCC(initID, retention)
+		if gcInterval > 0 {
+			m.stopGC = m.StartGarbageCollector(gcInterval)
+		}
+		return m, nil
+	})
+}
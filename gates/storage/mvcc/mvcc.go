@@ -0,0 +1,651 @@
+package mvcc
+
+import (
+	"fmt"
+	"notesServer/gates/storage"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MVCC - многоверсионное хранилище.
+// Каждая запись не перезаписывает значение, а добавляет новую версию элемента с номером изменения (seq),
+// поэтому состояние хранилища можно прочитать на любой момент в пределах окна хранения (retention).
+type MVCC struct {
+	versions  map[int64][]version // ID -> версии элемента в порядке возрастания seq
+	commits   []commit            // изменения в порядке возрастания seq (для поиска изменения по времени)
+	snapshots map[*Snapshot]struct{}
+	length    int64         // количество присутствующих (не удаленных) элементов
+	seq       int64         // номер последнего изменения
+	gcSeq     int64         // состояния с номером меньше gcSeq удалены сборщиком мусора
+	retention time.Duration // окно хранения старых версий
+	idInitial int64         // идентификатор первого добавляемого элемента
+	idCounter int64         // идентификатор следующего добавляемого элемента
+	V         reflect.Type  // фиксируется при добавлении первого элемента, сбрасывается при удалении последнего элемента
+	stopGC    func()        // останавливает сборку мусора, запущенную при открытии через storage.Open
+	mu        sync.RWMutex
+}
+
+type version struct {
+	seq     int64 // номер изменения, создавшего версию
+	value   any
+	deleted bool // версия-надгробие, означающая удаление элемента
+}
+
+type commit struct {
+	seq  int64
+	time time.Time
+}
+
+// NewMVCC возвращает новое многоверсионное хранилище, первый элемент которого будет иметь идентификатор initID.
+// Версии, замененные более новыми раньше чем retention назад, удаляются методом CollectGarbage.
+func NewMVCC(initID int64, retention time.Duration) (m *MVCC) {
+	return &MVCC{
+		versions:  make(map[int64][]version),
+		snapshots: make(map[*Snapshot]struct{}),
+		retention: retention,
+		idInitial: initID,
+		idCounter: initID,
+		V:         nil,
+	}
+}
+
+// Len возвращает количество элементов в хранилище
+func (m *MVCC) Len() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.length
+}
+
+// Add добавляет значение в хранилище и возвращает его идентификатор
+func (m *MVCC) Add(value any) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Согласование типа элементов
+	if m.V == nil {
+		m.V = reflect.TypeOf(value)
+	} else if m.V != reflect.TypeOf(value) {
+		return 0, storage.ErrMismatchType
+	}
+
+	seq := m.newCommit()
+	m.put(m.idCounter, seq, value)
+	m.idCounter++
+	return m.idCounter - 1, nil
+}
+
+// RemoveByID удаляет элемент из хранилища по идентификатору
+func (m *MVCC) RemoveByID(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.latest(id); !ok {
+		return
+	}
+	m.remove(id, m.newCommit())
+}
+
+// RemoveByValue удаляет один элемент из хранилища по значению
+func (m *MVCC) RemoveByValue(value any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.versions {
+		if v, ok := m.latest(id); ok && v == value {
+			m.remove(id, m.newCommit())
+			return
+		}
+	}
+}
+
+// RemoveAllByValue удаляет все элементы из хранилища по значению
+func (m *MVCC) RemoveAllByValue(value any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seq := int64(0)
+	for id := range m.versions {
+		if v, ok := m.latest(id); ok && v == value {
+			// Все удаления выполняются одним изменением
+			if seq == 0 {
+				seq = m.newCommit()
+			}
+			m.remove(id, seq)
+		}
+	}
+}
+
+// GetByID возвращает текущее значение элемента по идентификатору.
+// Если элемента с таким идентификатором нет, то возвращается nil и false.
+func (m *MVCC) GetByID(id int64) (value any, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.latest(id)
+}
+
+// GetByValue возвращает идентификатор первого найденного элемента по значению.
+// Если элемента с таким значением нет, то возвращается 0 и false.
+func (m *MVCC) GetByValue(value any) (id int64, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Согласование типа элементов
+	if m.V != reflect.TypeOf(value) {
+		return 0, false
+	}
+
+	for id = range m.versions {
+		if v, ok := m.latest(id); ok && v == value {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// GetAllByValue возвращает идентификаторы всех найденных элементов с указанным значением.
+// Если элементов с таким значением нет, возвращается nil и false.
+func (m *MVCC) GetAllByValue(value any) ([]int64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Согласование типа элементов
+	if reflect.TypeOf(value) != m.V {
+		return nil, false
+	}
+
+	var ids []int64
+	for id := range m.versions {
+		if v, ok := m.latest(id); ok && v == value {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, false
+	}
+	return ids, true
+}
+
+// UpdateByID добавляет новую версию элемента с указанным идентификатором.
+// Если элемента с таким ID нет, функция возвращает false и nil.
+// Если тип value отличается от типов уже присутствующих в хранилище элементов, возвращается false и ErrMismatchType.
+func (m *MVCC) UpdateByID(id int64, value any) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Согласование типа элементов
+	if m.V != reflect.TypeOf(value) {
+		return false, storage.ErrMismatchType
+	}
+
+	if _, ok := m.latest(id); !ok {
+		return false, nil
+	}
+
+	m.put(id, m.newCommit(), value)
+	return true, nil
+}
+
+// GetAll возвращает текущие значения всех элементов хранилища в виде map[int64]any.
+// Если хранилище пусто, возвращается nil и false.
+func (m *MVCC) GetAll() (map[int64]any, bool) {
+	// Версии копируются под блокировкой, а значения выбираются из них без нее, чтобы не задерживать запись
+	m.mu.RLock()
+	chains, seq := m.chains(), m.seq
+	m.mu.RUnlock()
+
+	return allAt(chains, seq)
+}
+
+// Find возвращает не более limit текущих элементов, для которых predicate возвращает true.
+// При ограничении количества элементы перебираются по возрастанию идентификатора.
+// Если таких элементов нет, возвращается nil и false.
+func (m *MVCC) Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]int64, 0, len(m.versions))
+	for id := range m.versions {
+		ids = append(ids, id)
+	}
+	if limit > 0 {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+
+	found := make(map[int64]any)
+	for _, id := range ids {
+		if limit > 0 && len(found) >= limit {
+			break
+		}
+		if value, ok := m.latest(id); ok && predicate(id, value) {
+			found[id] = value
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found, true
+}
+
+// FindAll возвращает все текущие элементы, для которых predicate возвращает true.
+// Если таких элементов нет, возвращается nil и false.
+func (m *MVCC) FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool) {
+	return m.Find(predicate, 0)
+}
+
+// RemoveWhere удаляет все элементы, для которых predicate возвращает true, и возвращает количество удаленных.
+// Все удаления выполняются одним изменением.
+func (m *MVCC) RemoveWhere(predicate func(id int64, v any) bool) (removed int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seq := int64(0)
+	for id := range m.versions {
+		if value, ok := m.latest(id); ok && predicate(id, value) {
+			if seq == 0 {
+				seq = m.newCommit()
+			}
+			m.remove(id, seq)
+			removed++
+		}
+	}
+	return removed
+}
+
+// AddMany добавляет значения в хранилище одним изменением и возвращает результат для каждого значения
+func (m *MVCC) AddMany(values []any) []storage.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]storage.Result, len(values))
+	seq := int64(0)
+	for i, value := range values {
+		// Согласование типа элементов
+		if m.V == nil {
+			m.V = reflect.TypeOf(value)
+		} else if m.V != reflect.TypeOf(value) {
+			results[i] = storage.Result{Err: storage.ErrMismatchType}
+			continue
+		}
+
+		if seq == 0 {
+			seq = m.newCommit()
+		}
+		m.put(m.idCounter, seq, value)
+		results[i] = storage.Result{ID: m.idCounter, OK: true}
+		m.idCounter++
+	}
+	return results
+}
+
+// UpdateMany добавляет новые версии элементов одним изменением и возвращает результат для каждого элемента
+func (m *MVCC) UpdateMany(items []storage.Item) []storage.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]storage.Result, len(items))
+	seq := int64(0)
+	for i, item := range items {
+		results[i].ID = item.ID
+
+		// Согласование типа элементов
+		if m.V != reflect.TypeOf(item.Value) {
+			results[i].Err = storage.ErrMismatchType
+			continue
+		}
+
+		if _, ok := m.latest(item.ID); !ok {
+			continue
+		}
+		if seq == 0 {
+			seq = m.newCommit()
+		}
+		m.put(item.ID, seq, item.Value)
+		results[i].OK = true
+	}
+	return results
+}
+
+// RemoveMany удаляет элементы одним изменением и возвращает результат для каждого идентификатора
+func (m *MVCC) RemoveMany(ids []int64) []storage.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]storage.Result, len(ids))
+	seq := int64(0)
+	for i, id := range ids {
+		results[i].ID = id
+		if _, ok := m.latest(id); !ok {
+			continue
+		}
+		if seq == 0 {
+			seq = m.newCommit()
+		}
+		m.remove(id, seq)
+		results[i].OK = true
+	}
+	return results
+}
+
+// Insert добавляет значение в хранилище с указанным идентификатором
+func (m *MVCC) Insert(id int64, value any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.latest(id); ok {
+		return storage.ErrIDExists
+	}
+
+	// Согласование типа элементов
+	if m.V == nil {
+		m.V = reflect.TypeOf(value)
+	} else if m.V != reflect.TypeOf(value) {
+		return storage.ErrMismatchType
+	}
+
+	m.put(id, m.newCommit(), value)
+	if id >= m.idCounter {
+		m.idCounter = id + 1
+	}
+	return nil
+}
+
+// NextID возвращает идентификатор следующего добавляемого элемента
+func (m *MVCC) NextID() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.idCounter
+}
+
+// SetNextID устанавливает идентификатор следующего добавляемого элемента. Значение, не превышающее
+// наибольший идентификатор существующего элемента, игнорируется: иначе следующий Add перезаписал бы элемент.
+func (m *MVCC) SetNextID(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for existing := range m.versions {
+		if _, ok := m.latest(existing); ok && existing >= id {
+			return
+		}
+	}
+	m.idCounter = id
+}
+
+// Clear удаляет все элементы из хранилища. Прошлые версии элементов остаются доступными до сборки мусора.
+func (m *MVCC) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seq := m.newCommit()
+	for id := range m.versions {
+		if _, ok := m.latest(id); ok {
+			m.remove(id, seq)
+		}
+	}
+	m.V = nil
+	m.idCounter = m.idInitial
+}
+
+// Print выводит текущее состояние хранилища в консоль
+func (m *MVCC) Print() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all, _ := allAt(m.versions, m.seq)
+	ids := make([]int64, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	fmt.Printf("seq: %d, gc seq: %d\n", m.seq, m.gcSeq)
+	fmt.Println(strings.Repeat("-", 20))
+	for _, id := range ids {
+		fmt.Printf("%v | %v\n", id, all[id])
+	}
+}
+
+// Snapshot фиксирует текущее состояние хранилища
+func (m *MVCC) Snapshot() storage.Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.pin(m.seq)
+}
+
+// SnapshotAsOf возвращает снимок состояния хранилища на момент t
+func (m *MVCC) SnapshotAsOf(t time.Time) (storage.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Поиск последнего изменения, сделанного не позже t
+	i := sort.Search(len(m.commits), func(i int) bool { return m.commits[i].time.After(t) })
+	if i == 0 {
+		// Момент t раньше всех сохраненных изменений
+		if m.gcSeq > 0 {
+			return nil, storage.ErrVersionCollected
+		}
+		return m.pin(0), nil
+	}
+	seq := m.commits[i-1].seq
+	if seq < m.gcSeq {
+		return nil, storage.ErrVersionCollected
+	}
+	return m.pin(seq), nil
+}
+
+// SnapshotByToken возвращает снимок состояния хранилища по его токену
+func (m *MVCC) SnapshotByToken(token int64) (storage.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token > m.seq {
+		return nil, fmt.Errorf("invalid snapshot token: %d", token)
+	}
+	if token < m.gcSeq {
+		return nil, storage.ErrVersionCollected
+	}
+	return m.pin(token), nil
+}
+
+// GetByIDAsOf возвращает значение элемента с указанным ID на момент t
+func (m *MVCC) GetByIDAsOf(id int64, t time.Time) (any, bool, error) {
+	snapshot, err := m.SnapshotAsOf(t)
+	if err != nil {
+		return nil, false, err
+	}
+	defer snapshot.Release()
+
+	value, ok := snapshot.GetByID(id)
+	return value, ok, nil
+}
+
+// GetAllAsOf возвращает все элементы хранилища на момент t
+func (m *MVCC) GetAllAsOf(t time.Time) (map[int64]any, bool, error) {
+	snapshot, err := m.SnapshotAsOf(t)
+	if err != nil {
+		return nil, false, err
+	}
+	defer snapshot.Release()
+
+	all, ok := snapshot.GetAll()
+	return all, ok, nil
+}
+
+// CollectGarbage удаляет версии, которые не видны ни из одного состояния в пределах окна хранения
+// и ни из одного активного снимка. Снимки старше окна хранения освобождаются принудительно.
+// Возвращает количество удаленных версий.
+func (m *MVCC) CollectGarbage() (collected int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	horizon := time.Now().Add(-m.retention)
+
+	// Освобождение устаревших снимков
+	for snapshot := range m.snapshots {
+		if snapshot.created.Before(horizon) {
+			delete(m.snapshots, snapshot)
+		}
+	}
+
+	// Номер последнего изменения, сделанного до горизонта
+	i := sort.Search(len(m.commits), func(i int) bool { return m.commits[i].time.After(horizon) })
+	if i == 0 {
+		return 0
+	}
+	collectSeq := m.commits[i-1].seq
+
+	// Состояния, видимые активным снимкам, удалять нельзя
+	for snapshot := range m.snapshots {
+		if snapshot.seq < collectSeq {
+			collectSeq = snapshot.seq
+		}
+	}
+	if collectSeq <= m.gcSeq {
+		return 0
+	}
+
+	// Версия не нужна, если следующая за ней версия элемента создана не позже collectSeq
+	for id, versions := range m.versions {
+		keepFrom := 0
+		for j := 1; j < len(versions) && versions[j].seq <= collectSeq; j++ {
+			keepFrom = j
+		}
+		// Надгробие, созданное не позже collectSeq, тоже не нужно: без него элемент так же отсутствует
+		if versions[keepFrom].deleted && versions[keepFrom].seq <= collectSeq {
+			keepFrom++
+		}
+		if keepFrom == 0 {
+			continue
+		}
+		collected += keepFrom
+		if keepFrom == len(versions) {
+			delete(m.versions, id)
+			continue
+		}
+		m.versions[id] = append([]version(nil), versions[keepFrom:]...)
+	}
+
+	// Сохраняется изменение collectSeq, чтобы по-прежнему находить его по времени
+	j := sort.Search(len(m.commits), func(j int) bool { return m.commits[j].seq >= collectSeq })
+	m.commits = append([]commit(nil), m.commits[j:]...)
+	m.gcSeq = collectSeq
+	return collected
+}
+
+// StartGarbageCollector запускает периодическую сборку мусора с интервалом interval.
+// Возвращает функцию, останавливающую сборку мусора.
+func (m *MVCC) StartGarbageCollector(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				m.CollectGarbage()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Close останавливает периодическую сборку мусора, запущенную при открытии хранилища через storage.Open
+func (m *MVCC) Close() error {
+	if m.stopGC != nil {
+		m.stopGC()
+	}
+	return nil
+}
+
+// newCommit регистрирует новое изменение и возвращает его номер. Вызывается под m.mu.Lock().
+func (m *MVCC) newCommit() int64 {
+	m.seq++
+	m.commits = append(m.commits, commit{seq: m.seq, time: time.Now()})
+	return m.seq
+}
+
+// put добавляет новую версию элемента. Вызывается под m.mu.Lock().
+func (m *MVCC) put(id int64, seq int64, value any) {
+	versions := m.versions[id]
+	if len(versions) == 0 || versions[len(versions)-1].deleted {
+		m.length++
+	}
+	m.versions[id] = append(versions, version{seq: seq, value: value})
+}
+
+// remove добавляет версию-надгробие элемента. Вызывается под m.mu.Lock() для присутствующего элемента.
+func (m *MVCC) remove(id int64, seq int64) {
+	m.versions[id] = append(m.versions[id], version{seq: seq, deleted: true})
+	m.length--
+
+	// Сброс типа элементов
+	if m.length == 0 {
+		m.V = nil
+	}
+}
+
+// latest возвращает текущее значение элемента
+func (m *MVCC) latest(id int64) (any, bool) {
+	versions := m.versions[id]
+	if len(versions) == 0 || versions[len(versions)-1].deleted {
+		return nil, false
+	}
+	return versions[len(versions)-1].value, true
+}
+
+// at возвращает значение элемента в состоянии seq
+func (m *MVCC) at(id int64, seq int64) (any, bool) {
+	return valueAt(m.versions[id], seq)
+}
+
+// chains возвращает копию версий всех элементов. Вызывается под m.mu.RLock().
+// Копируются только заголовки срезов: версии элемента лишь дописываются в конец его среза,
+// а сборщик мусора заменяет срез новым, поэтому копию можно читать после снятия блокировки.
+func (m *MVCC) chains() map[int64][]version {
+	chains := make(map[int64][]version, len(m.versions))
+	for id, versions := range m.versions {
+		chains[id] = versions
+	}
+	return chains
+}
+
+// valueAt возвращает значение элемента в состоянии seq по его версиям
+func valueAt(versions []version, seq int64) (any, bool) {
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].seq > seq })
+	if i == 0 || versions[i-1].deleted {
+		return nil, false
+	}
+	return versions[i-1].value, true
+}
+
+// allAt возвращает все элементы в состоянии seq по версиям chains, полученным методом chains
+func allAt(chains map[int64][]version, seq int64) (map[int64]any, bool) {
+	all := make(map[int64]any)
+	for id, versions := range chains {
+		if value, ok := valueAt(versions, seq); ok {
+			all[id] = value
+		}
+	}
+	if len(all) == 0 {
+		return nil, false
+	}
+	return all, true
+}
+
+// pin создает и регистрирует снимок состояния seq. Вызывается под m.mu.Lock().
+func (m *MVCC) pin(seq int64) *Snapshot {
+	snapshot := &Snapshot{mvcc: m, seq: seq, created: time.Now()}
+	m.snapshots[snapshot] = struct{}{}
+	return snapshot
+}
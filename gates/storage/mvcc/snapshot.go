@@ -0,0 +1,67 @@
+package mvcc
+
+import (
+	"notesServer/gates/storage"
+	"time"
+)
+
+// Snapshot - снимок состояния многоверсионного хранилища с номером изменения seq.
+// Пока снимок не освобожден, сборщик мусора не удаляет нужные ему версии.
+type Snapshot struct {
+	mvcc    *MVCC
+	seq     int64
+	created time.Time
+}
+
+// Token возвращает токен снимка
+func (s *Snapshot) Token() int64 {
+	return s.seq
+}
+
+// GetByID возвращает значение элемента в снимке по идентификатору.
+// Если элемента с таким идентификатором нет или снимок освобожден сборщиком мусора, то возвращается nil и false.
+func (s *Snapshot) GetByID(id int64) (any, bool) {
+	s.mvcc.mu.RLock()
+	defer s.mvcc.mu.RUnlock()
+
+	// Снимок освобожден сборщиком мусора
+	if s.seq < s.mvcc.gcSeq {
+		return nil, false
+	}
+	return s.mvcc.at(id, s.seq)
+}
+
+// GetAll возвращает все элементы снимка в виде map[int64]any.
+// Если снимок пуст или освобожден сборщиком мусора, возвращается nil и false.
+func (s *Snapshot) GetAll() (map[int64]any, bool) {
+	// Версии копируются под блокировкой, а значения выбираются из них без нее, чтобы не задерживать запись
+	s.mvcc.mu.RLock()
+	if s.seq < s.mvcc.gcSeq {
+		// Снимок освобожден сборщиком мусора
+		s.mvcc.mu.RUnlock()
+		return nil, false
+	}
+	chains := s.mvcc.chains()
+	s.mvcc.mu.RUnlock()
+
+	return allAt(chains, s.seq)
+}
+
+// Err возвращает storage.ErrVersionCollected, если снимок освобожден сборщиком мусора
+func (s *Snapshot) Err() error {
+	s.mvcc.mu.RLock()
+	defer s.mvcc.mu.RUnlock()
+
+	if s.seq < s.mvcc.gcSeq {
+		return storage.ErrVersionCollected
+	}
+	return nil
+}
+
+// Release освобождает снимок
+func (s *Snapshot) Release() {
+	s.mvcc.mu.Lock()
+	defer s.mvcc.mu.Unlock()
+
+	delete(s.mvcc.snapshots, s)
+}
@@ -0,0 +1,164 @@
+package mvcc
+
+import (
+	"errors"
+	"notesServer/gates/storage"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCollectedByGarbageCollector(t *testing.T) {
+	m := NewMVCC(1, 0)
+	id, _ := m.Add("первая")
+	snapshot := m.Snapshot()
+	defer snapshot.Release()
+	if err := snapshot.Err(); err != nil {
+		t.Fatalf("Err() of a fresh snapshot = %v", err)
+	}
+	m.UpdateByID(id, "вторая")
+
+	// Снимок старше окна хранения освобождается принудительно, его версии удаляются
+	time.Sleep(time.Millisecond)
+	if collected := m.CollectGarbage(); collected == 0 {
+		t.Fatal("CollectGarbage() collected nothing")
+	}
+
+	if value, ok := snapshot.GetByID(id); ok {
+		t.Fatalf("GetByID() from a collected snapshot = %v", value)
+	}
+	if all, ok := snapshot.GetAll(); ok {
+		t.Fatalf("GetAll() from a collected snapshot = %v", all)
+	}
+	if err := snapshot.Err(); !errors.Is(err, storage.ErrVersionCollected) {
+		t.Fatalf("Err() of a collected snapshot = %v, want ErrVersionCollected", err)
+	}
+	if _, err := m.SnapshotByToken(snapshot.Token()); !errors.Is(err, storage.ErrVersionCollected) {
+		t.Fatalf("SnapshotByToken() of a collected snapshot: %v, want ErrVersionCollected", err)
+	}
+}
+
+func TestSnapshotGetAllDuringWrites(t *testing.T) {
+	m := NewMVCC(1, time.Hour)
+	for i := 0; i < 100; i++ {
+		m.Add(0)
+	}
+	snapshot := m.Snapshot()
+	defer snapshot.Release()
+
+	// Запись идет параллельно с чтением снимка: версии читаются после снятия блокировки
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 1; n <= 100; n++ {
+			for id := int64(1); id <= 100; id++ {
+				m.UpdateByID(id, n)
+			}
+			m.Add(n)
+			m.RemoveByID(int64(100 + n))
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		all, _ := snapshot.GetAll()
+		if len(all) != 100 {
+			t.Fatalf("snapshot has %d items, want 100", len(all))
+		}
+		for id, value := range all {
+			if value != 0 {
+				t.Fatalf("snapshot item %d = %v, want 0", id, value)
+			}
+		}
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	m := NewMVCC(1, time.Hour)
+	a, _ := m.Add("a")
+	b, _ := m.Add("b")
+	snapshot := m.Snapshot()
+	defer snapshot.Release()
+
+	// Изменения после создания снимка в нем не видны
+	m.UpdateByID(a, "a2")
+	m.RemoveByID(b)
+	c, _ := m.Add("c")
+
+	want := map[int64]any{a: "a", b: "b"}
+	all, _ := snapshot.GetAll()
+	if len(all) != len(want) || all[a] != want[a] || all[b] != want[b] {
+		t.Fatalf("snapshot GetAll() = %v, want %v", all, want)
+	}
+	if value, ok := snapshot.GetByID(b); !ok || value != "b" {
+		t.Fatalf("snapshot GetByID(%d) = %v, %t, want the removed item", b, value, ok)
+	}
+	if value, ok := snapshot.GetByID(c); ok {
+		t.Fatalf("snapshot GetByID(%d) = %v, want no item added later", c, value)
+	}
+
+	// Хранилище и новый снимок видят текущее состояние
+	if value, _ := m.GetByID(a); value != "a2" {
+		t.Fatalf("GetByID(%d) = %v, want a2", a, value)
+	}
+	current := m.Snapshot()
+	defer current.Release()
+	all, _ = current.GetAll()
+	if len(all) != 2 || all[a] != "a2" || all[c] != "c" {
+		t.Fatalf("new snapshot GetAll() = %v", all)
+	}
+
+	// Снимок по токену видит то же состояние, что и исходный
+	byToken, err := m.SnapshotByToken(snapshot.Token())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer byToken.Release()
+	if value, _ := byToken.GetByID(a); value != "a" {
+		t.Fatalf("SnapshotByToken() GetByID(%d) = %v, want a", a, value)
+	}
+	if _, err = m.SnapshotByToken(current.Token() + 1); err == nil {
+		t.Fatal("SnapshotByToken() of a future state: want error")
+	}
+}
+
+// Активный снимок удерживает свои версии от сборки мусора, а освобожденный - нет
+func TestSnapshotPinsVersionsUntilRelease(t *testing.T) {
+	const retention = 200 * time.Millisecond
+	m := NewMVCC(1, retention)
+	id, _ := m.Add("v1")
+	first := m.Snapshot()
+	token := first.Token()
+	m.UpdateByID(id, "v2")
+
+	// Снимок того же состояния, созданный позже, моложе окна хранения во время сборки мусора
+	time.Sleep(retention * 3 / 5)
+	pinned, err := m.SnapshotByToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Release()
+	first.Release()
+	time.Sleep(retention * 3 / 5)
+
+	m.CollectGarbage()
+	if err = pinned.Err(); err != nil {
+		t.Fatalf("Err() of an active snapshot after CollectGarbage() = %v", err)
+	}
+	if value, ok := pinned.GetByID(id); !ok || value != "v1" {
+		t.Fatalf("GetByID() of an active snapshot after CollectGarbage() = %v, %t, want v1", value, ok)
+	}
+
+	pinned.Release()
+	if collected := m.CollectGarbage(); collected == 0 {
+		t.Fatal("CollectGarbage() after Release() collected nothing")
+	}
+	if _, err = m.SnapshotByToken(token); !errors.Is(err, storage.ErrVersionCollected) {
+		t.Fatalf("SnapshotByToken() of a released state: %v, want ErrVersionCollected", err)
+	}
+	if value, _ := m.GetByID(id); value != "v2" {
+		t.Fatalf("GetByID() after CollectGarbage() = %v, want v2", value)
+	}
+}
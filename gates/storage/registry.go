@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opener создает хранилище по пути и параметрам из строки подключения.
+// Перед возвратом хранилища Opener должен прочитать все поддерживаемые параметры из opts:
+// оставшиеся непрочитанными параметры считаются неизвестными, и Open возвращает ошибку.
+type Opener func(path string, opts *Options) (Storage, error)
+
+var (
+	openers   = make(map[string]Opener)
+	openersMu sync.RWMutex
+)
+
+// Register регистрирует Opener для схемы строки подключения (например, "map" для "map://?init_id=1").
+// Обычно вызывается из функции init пакета хранилища. Повторная регистрация схемы вызывает панику.
+func Register(scheme string, opener Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+
+	if opener == nil {
+		panic("storage: Register opener is nil")
+	}
+	if _, ok := openers[scheme]; ok {
+		panic("storage: Register called twice for scheme " + scheme)
+	}
+	openers[scheme] = opener
+}
+
+// Schemes возвращает отсортированный список зарегистрированных схем
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open создает хранилище по строке подключения вида "схема://путь?параметр=значение", например:
+//
+//	map://?init_id=1
+//	list://
+//	file:///var/lib/notes
+//
+// Пакет хранилища должен быть импортирован, чтобы его схема была зарегистрирована.
+// Если хранилище реализует io.Closer, после использования его нужно закрыть функцией Close.
+func Open(dsn string) (Storage, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage dsn %q: %w", dsn, err)
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("invalid storage dsn %q: scheme is missing (registered: %s)", dsn, strings.Join(Schemes(), ", "))
+	}
+
+	openersMu.RLock()
+	opener, ok := openers[u.Scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage scheme %q (registered: %s)", u.Scheme, strings.Join(Schemes(), ", "))
+	}
+
+	opts := &Options{values: u.Query(), used: make(map[string]bool)}
+	st, err := opener(u.Host+u.Path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s storage: %w", u.Scheme, err)
+	}
+
+	// Проверка неизвестных параметров
+	if unknown := opts.unused(); len(unknown) != 0 {
+		if closer, ok := st.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, fmt.Errorf("unknown %s storage options: %s", u.Scheme, strings.Join(unknown, ", "))
+	}
+	return st, nil
+}
+
+// Options - параметры из строки подключения к хранилищу
+type Options struct {
+	values url.Values
+	used   map[string]bool // прочитанные параметры
+}
+
+// ErrPathNotSupported ошибка, возвращаемая Opener хранилища, которое не использует путь из строки подключения
+var ErrPathNotSupported = errors.New("path is not supported by this storage")
+
+// String возвращает значение строкового параметра name или def, если параметр не указан
+func (o *Options) String(name string, def string) string {
+	o.used[name] = true
+	if !o.values.Has(name) {
+		return def
+	}
+	return o.values.Get(name)
+}
+
+// Int64 возвращает значение целочисленного параметра name или def, если параметр не указан
+func (o *Options) Int64(name string, def int64) (int64, error) {
+	o.used[name] = true
+	if !o.values.Has(name) {
+		return def, nil
+	}
+	value, err := strconv.ParseInt(o.values.Get(name), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid option %s=%q: need an integer", name, o.values.Get(name))
+	}
+	return value, nil
+}
+
+// Bool возвращает значение логического параметра name или def, если параметр не указан
+func (o *Options) Bool(name string, def bool) (bool, error) {
+	o.used[name] = true
+	if !o.values.Has(name) {
+		return def, nil
+	}
+	value, err := strconv.ParseBool(o.values.Get(name))
+	if err != nil {
+		return false, fmt.Errorf("invalid option %s=%q: need true or false", name, o.values.Get(name))
+	}
+	return value, nil
+}
+
+// Duration возвращает значение параметра-длительности name (например, "24h") или def, если параметр не указан
+func (o *Options) Duration(name string, def time.Duration) (time.Duration, error) {
+	o.used[name] = true
+	if !o.values.Has(name) {
+		return def, nil
+	}
+	value, err := time.ParseDuration(o.values.Get(name))
+	if err != nil {
+		return 0, fmt.Errorf("invalid option %s=%q: need a duration like 90s or 24h", name, o.values.Get(name))
+	}
+	return value, nil
+}
+
+// unused возвращает отсортированный список параметров, не прочитанных Opener
+func (o *Options) unused() []string {
+	var unknown []string
+	for name := range o.values {
+		if !o.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
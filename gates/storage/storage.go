@@ -1,6 +1,9 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // Storage - интерфейс, представляющий обобщенное хранилище данных.
 // Тип данных хранящихся элементов фиксируется при добавлении первого элемента и сбрасывается при удалении последнего.
@@ -50,6 +53,32 @@ type Storage interface {
 	// Если хранилище пусто, возвращается nil и false.
 	GetAll() (map[int64]any, bool)
 
+	// Find возвращает не более limit элементов, для которых predicate возвращает true, в виде map[int64]any.
+	// Если limit <= 0, количество найденных элементов не ограничивается.
+	// Если таких элементов нет, возвращается nil и false.
+	// predicate вызывается под блокировкой хранилища, поэтому не должен обращаться к самому хранилищу.
+	Find(predicate func(id int64, v any) bool, limit int) (map[int64]any, bool)
+
+	// FindAll возвращает все элементы, для которых predicate возвращает true, в виде map[int64]any.
+	// Если таких элементов нет, возвращается nil и false.
+	FindAll(predicate func(id int64, v any) bool) (map[int64]any, bool)
+
+	// RemoveWhere удаляет все элементы, для которых predicate возвращает true, и возвращает количество удаленных.
+	RemoveWhere(predicate func(id int64, v any) bool) int64
+
+	// AddMany добавляет элементы в хранилище за одну блокировку и возвращает результат для каждого элемента
+	// в порядке values. Элемент, тип которого не совпадает с типом хранилища, не добавляется
+	// и получает ошибку ErrMismatchType, остальные элементы при этом добавляются.
+	AddMany(values []any) []Result
+
+	// UpdateMany обновляет элементы за одну блокировку и возвращает результат для каждого элемента в порядке items.
+	// Для несуществующего элемента результат содержит OK == false, для элемента другого типа - ErrMismatchType.
+	UpdateMany(items []Item) []Result
+
+	// RemoveMany удаляет элементы с указанными ID за одну блокировку и возвращает результат для каждого ID в порядке ids.
+	// Для несуществующего элемента результат содержит OK == false.
+	RemoveMany(ids []int64) []Result
+
 	// Clear удаляет все элементы из хранилища.
 	Clear()
 
@@ -64,6 +93,139 @@ type Storage interface {
 	//// todo | (мб поискать способы сериализации без json, как pickle в питоне?)
 }
 
+// Ordered - хранилище, хранящее пользовательский порядок элементов независимо от их идентификаторов.
+// Новые элементы добавляются в конец.
+type Ordered interface {
+	Storage
+
+	// MoveBefore перемещает элемент с ID id непосредственно перед элементом с ID anchorID.
+	// Если одного из элементов нет, возвращается false.
+	MoveBefore(id int64, anchorID int64) bool
+
+	// MoveAfter перемещает элемент с ID id непосредственно после элемента с ID anchorID.
+	// Если одного из элементов нет, возвращается false.
+	MoveAfter(id int64, anchorID int64) bool
+
+	// MoveToFront перемещает элемент с указанным ID в начало. Если элемента нет, возвращается false.
+	MoveToFront(id int64) bool
+
+	// MoveToBack перемещает элемент с указанным ID в конец. Если элемента нет, возвращается false.
+	MoveToBack(id int64) bool
+
+	// GetAllOrdered возвращает все элементы хранилища в пользовательском порядке.
+	// Если хранилище пусто, возвращается nil и false.
+	GetAllOrdered() ([]Item, bool)
+}
+
+// Migratable - хранилище, данные которого можно перенести в другое хранилище с сохранением идентификаторов.
+type Migratable interface {
+	Storage
+
+	// Insert добавляет элемент с указанным ID, не меняя идентификаторы других элементов.
+	// Если элемент с таким ID уже есть, возвращается ErrIDExists, при несовпадении типа - ErrMismatchType.
+	// Если id не меньше идентификатора следующего добавляемого элемента, счетчик идентификаторов сдвигается за id.
+	Insert(id int64, value any) error
+
+	// NextID возвращает идентификатор, который получит следующий добавленный элемент.
+	NextID() int64
+
+	// SetNextID устанавливает идентификатор, который получит следующий добавленный элемент.
+	// Значение, не превышающее наибольший идентификатор существующего элемента, игнорируется.
+	SetNextID(id int64)
+}
+
+// Scrubber - хранилище, записи которого хранят контрольные суммы и могут быть проверены на целостность.
+type Scrubber interface {
+	Storage
+
+	// Scrub проверяет контрольные суммы всех записей хранилища и сверяет набор записей с элементами в памяти.
+	// Возвращает отчет о поврежденных записях и о записях, которых нет на одной из сторон.
+	// Записи, для которых в хранилище есть исправное значение, перезаписываются.
+	// Если quarantine == true, остальные поврежденные и лишние записи переносятся в карантин и удаляются из хранилища.
+	Scrub(quarantine bool) (ScrubReport, error)
+}
+
+// ScrubReport - результат проверки целостности хранилища
+type ScrubReport struct {
+	Checked     int64   `json:"checked"`               // Количество проверенных записей
+	Corrupt     []int64 `json:"corrupt,omitempty"`     // Идентификаторы поврежденных записей
+	Repaired    []int64 `json:"repaired,omitempty"`    // Поврежденные записи, перезаписанные исправным значением
+	Quarantined []int64 `json:"quarantined,omitempty"` // Поврежденные и лишние записи, перенесенные в карантин
+
+	MissingOnDisk   []int64 `json:"missing_on_disk,omitempty"`   // Элементы в памяти без записи на диске (записываются заново)
+	MissingInMemory []int64 `json:"missing_in_memory,omitempty"` // Исправные записи на диске, которых нет в памяти
+}
+
+// Item - элемент хранилища вместе с его идентификатором
+type Item struct {
+	ID    int64
+	Value any
+}
+
+// Result - результат пакетной операции над одним элементом
+type Result struct {
+	ID  int64 // ID элемента (для AddMany - присвоенный ID, если элемент добавлен)
+	OK  bool  // true, если операция над элементом выполнена
+	Err error // ошибка операции над элементом
+}
+
 // ErrMismatchType ошибка, возвращаемая методами Add и Update,
 // если тип нового элемента не соответствует типу уже присутствующих в хранилище элементов.
 var ErrMismatchType = errors.New("mismatched type: the type of the provided value does not match the type of items already in the storage")
+
+// Versioned - хранилище, хранящее прошлые версии элементов.
+// Позволяет читать состояние хранилища на заданный момент времени или по токену снимка.
+type Versioned interface {
+	Storage
+
+	// Snapshot фиксирует текущее состояние хранилища.
+	// Чтение из снимка не блокирует запись в хранилище, снимок нужно освободить методом Release.
+	Snapshot() Snapshot
+
+	// SnapshotAsOf возвращает снимок состояния хранилища на момент t.
+	// Если версии на этот момент уже удалены сборщиком мусора, возвращается ошибка ErrVersionCollected.
+	SnapshotAsOf(t time.Time) (Snapshot, error)
+
+	// SnapshotByToken возвращает снимок состояния хранилища, токен которого был получен методом Snapshot.Token.
+	// Если версии для этого токена уже удалены сборщиком мусора, возвращается ошибка ErrVersionCollected.
+	SnapshotByToken(token int64) (Snapshot, error)
+
+	// GetByIDAsOf возвращает значение элемента с указанным ID на момент t.
+	// Если элемента с таким ID в тот момент не было, возвращается nil, false и nil.
+	GetByIDAsOf(id int64, t time.Time) (any, bool, error)
+
+	// GetAllAsOf возвращает все элементы хранилища на момент t в виде map[int64]any.
+	// Если в тот момент хранилище было пусто, возвращается nil, false и nil.
+	GetAllAsOf(t time.Time) (map[int64]any, bool, error)
+}
+
+// Snapshot - согласованный снимок состояния хранилища, неизменный при последующих записях.
+type Snapshot interface {
+	// Token возвращает токен снимка, по которому его можно открыть повторно.
+	Token() int64
+
+	// GetByID возвращает значение элемента с указанным ID в снимке.
+	// Если элемента с таким ID нет, возвращается nil и false.
+	// Если снимок освобожден сборщиком мусора, тоже возвращается nil и false, а Err возвращает ошибку.
+	GetByID(id int64) (any, bool)
+
+	// GetAll возвращает все элементы снимка в виде map[int64]any.
+	// Если снимок пуст, возвращается nil и false.
+	// Если снимок освобожден сборщиком мусора, тоже возвращается nil и false, а Err возвращает ошибку.
+	GetAll() (map[int64]any, bool)
+
+	// Err возвращает ErrVersionCollected, если снимок был принудительно освобожден сборщиком мусора
+	// и версии, нужные для чтения из него, удалены. Ошибку нужно проверять после чтения: пустой результат
+	// GetByID и GetAll иначе неотличим от отсутствия элементов в снимке.
+	Err() error
+
+	// Release освобождает снимок, после чего его версии могут быть удалены сборщиком мусора.
+	// Повторный вызов ничего не делает.
+	Release()
+}
+
+// ErrIDExists ошибка, возвращаемая методом Insert, если элемент с таким ID уже есть в хранилище.
+var ErrIDExists = errors.New("id exists: an item with the provided id is already in the storage")
+
+// ErrVersionCollected ошибка, возвращаемая при попытке прочитать версии, уже удаленные сборщиком мусора.
+var ErrVersionCollected = errors.New("version collected: the requested state is older than the retention window")
@@ -1,19 +1,169 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
 	"notesServer/controllers/notesService"
+	"notesServer/gates/auth"
+	"notesServer/gates/ratelimit"
+	"notesServer/gates/sharing"
 	"notesServer/gates/storage"
-	"notesServer/gates/storage/mp"
+	_ "notesServer/gates/storage/file"
+	_ "notesServer/gates/storage/list"
+	_ "notesServer/gates/storage/mp"
+	_ "notesServer/gates/storage/mvcc"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
 func main() {
-	var st storage.Storage
-	st = mp.NewMap(1)
+	if err := run(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run запускает сервер и возвращается после его остановки. Ошибки запуска возвращаются, а не завершают
+// процесс на месте, чтобы отложенные вызовы успели закрыть уже открытые хранилища.
+func run() error {
+	// Хранилище выбирается строкой подключения, например: map://?init_id=1, list://, file:///var/lib/notes.
+	// Чтение прошлых состояний (?as_of=, /snapshot) поддерживает только многоверсионное хранилище,
+	// оно включается явно: -storage 'mvcc://?init_id=1&retention=24h&gc_interval=1m'
+	dsn := flag.String("storage", "map://?init_id=1", "storage dsn (schemes: "+strings.Join(storage.Schemes(), ", ")+
+		"), e.g. mvcc://?init_id=1&retention=24h&gc_interval=1m for point-in-time reads and snapshots")
+	usersDsn := flag.String("users-storage", "map://?init_id=1", "storage dsn for user accounts and api tokens, e.g. file:///var/lib/notes-users")
+	sharesDsn := flag.String("shares-storage", "map://?init_id=1", "storage dsn for note shares, e.g. file:///var/lib/notes-shares")
+	linksDsn := flag.String("links-storage", "map://?init_id=1", "storage dsn for public link secrets, e.g. file:///var/lib/notes-links")
+	jwks := flag.String("jwt-jwks", "", "file path or url of the jwks used to verify jwt bearer tokens (empty disables jwt)")
+	jwtIssuer := flag.String("jwt-issuer", "", "required jwt iss claim (empty skips the check)")
+	jwtAudience := flag.String("jwt-audience", "", "required jwt aud claim value (empty skips the check)")
+	jwtLeeway := flag.Duration("jwt-leeway", 30*time.Second, "allowed clock skew when checking jwt exp and nbf")
+	jwtLoginClaim := flag.String("jwt-login-claim", "preferred_username", "jwt claim with the user login (sub is used if it is missing)")
+	jwtAdminGroup := flag.String("jwt-admin-group", "", "group from the jwt groups claim whose members are administrators")
+	// Первый администратор задается при запуске, пароль нового администратора - только через окружение,
+	// чтобы он не попадал в список процессов
+	adminLogin := flag.String("admin", os.Getenv("NOTES_ADMIN"),
+		"login made administrator on startup, created with the NOTES_ADMIN_PASSWORD password if missing (default $NOTES_ADMIN)")
+	tokenTTL := flag.Duration("token-ttl", 30*24*time.Hour, "lifetime of api tokens issued by /login")
+	addr := flag.String("addr", ":8080", "listen address")
+	maxRevisions := flag.Int("max-revisions", 100, "how many latest revisions of each note are kept in its history")
+	idempotencyTTL := flag.Duration("idempotency-ttl", 24*time.Hour, "how long responses to note creation with an Idempotency-Key are kept (0 disables keys)")
+	rateLimits := flag.String("rate-limit", "/create=5/s:20,/create-many=1/s:5,/login=1/s:5,/register=1/m:5",
+		"per client ip rate limits: comma-separated <route>=<count>/<s|m|h>[:<burst>]; a route ending in '/' is a prefix, '*' matches all other routes")
+	accessLog := flag.String("access-log", "", "file to append the json access log to (empty writes it to stderr)")
+	scrubMode := flag.String("scrub", "report", "integrity check on startup for storages with checksums: off, report or quarantine")
+	flag.Parse()
+
+	// Хранилище заметок закрывается после остановки сервера (см. ns.CloseStorage): за время работы
+	// оно может быть заменено другим через /admin/migrate
+	st, err := storage.Open(*dsn)
+	if err != nil {
+		return fmt.Errorf("cannot open storage: %w", err)
+	}
+	// Если запуск прервется раньше создания сервиса, хранилище заметок закрывается здесь
+	serverStarted := false
+	defer func() {
+		if !serverStarted {
+			closeStorage("notes", st)
+		}
+	}()
+
+	// Хранилище пользователей
+	usersSt, err := storage.Open(*usersDsn)
+	if err != nil {
+		return fmt.Errorf("cannot open users storage: %w", err)
+	}
+	defer closeStorage("users", usersSt)
+	users, err := auth.NewUsers(usersSt, *tokenTTL)
+	if err != nil {
+		return fmt.Errorf("cannot load users: %w", err)
+	}
+	if *adminLogin != "" {
+		admin, err := users.BootstrapAdmin(*adminLogin, os.Getenv("NOTES_ADMIN_PASSWORD"))
+		if err != nil {
+			return fmt.Errorf("cannot make %q administrator: %w", *adminLogin, err)
+		}
+		log.Printf("user %q (id %d) is administrator", admin.Login, admin.ID)
+	}
+
+	// Хранилище прав доступа к заметкам
+	sharesSt, err := storage.Open(*sharesDsn)
+	if err != nil {
+		return fmt.Errorf("cannot open shares storage: %w", err)
+	}
+	defer closeStorage("shares", sharesSt)
+	shares, err := sharing.NewShares(sharesSt)
+	if err != nil {
+		return fmt.Errorf("cannot load shares: %w", err)
+	}
+
+	// Хранилище секретов публичных ссылок
+	linksSt, err := storage.Open(*linksDsn)
+	if err != nil {
+		return fmt.Errorf("cannot open links storage: %w", err)
+	}
+	defer closeStorage("links", linksSt)
+	links, err := sharing.NewLinks(linksSt)
+	if err != nil {
+		return fmt.Errorf("cannot load links: %w", err)
+	}
 
-	ns := notesService.NewNotesService(":8080", st)
+	// Проверка целостности записей перед запуском сервера
+	if *scrubMode != "off" && *scrubMode != "report" && *scrubMode != "quarantine" {
+		return fmt.Errorf("invalid -scrub mode %q (need off, report or quarantine)", *scrubMode)
+	}
+	if scrubber, ok := st.(storage.Scrubber); ok && *scrubMode != "off" {
+		report, err := scrubber.Scrub(*scrubMode == "quarantine")
+		if err != nil {
+			return fmt.Errorf("cannot scrub storage: %w", err)
+		}
+		log.Printf("storage scrub: checked %d, corrupt %v, repaired %v, quarantined %v, missing on disk %v, missing in memory %v",
+			report.Checked, report.Corrupt, report.Repaired, report.Quarantined, report.MissingOnDisk, report.MissingInMemory)
+	}
+
+	// Журнал запросов
+	accessLogWriter := io.Writer(os.Stderr)
+	if *accessLog != "" {
+		accessLogFile, err := os.OpenFile(*accessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot open access log: %w", err)
+		}
+		defer accessLogFile.Close()
+		accessLogWriter = accessLogFile
+	}
+
+	// Ограничения частоты запросов
+	routeLimits, err := ratelimit.ParseRouteLimits(*rateLimits)
+	if err != nil {
+		return fmt.Errorf("invalid -rate-limit: %w", err)
+	}
+
+	opts := []notesService.Option{notesService.WithIdempotencyTTL(*idempotencyTTL), notesService.WithMaxRevisions(*maxRevisions),
+		notesService.WithUsers(users), notesService.WithShares(shares), notesService.WithLinks(links),
+		notesService.WithRateLimits(routeLimits), notesService.WithAccessLog(accessLogWriter)}
+
+	// Вход по JWT внешнего сервера авторизации
+	if *jwks != "" {
+		verifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+			JWKS:       *jwks,
+			Issuer:     *jwtIssuer,
+			Audience:   *jwtAudience,
+			Leeway:     *jwtLeeway,
+			LoginClaim: *jwtLoginClaim,
+			AdminGroup: *jwtAdminGroup,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot configure jwt: %w", err)
+		}
+		opts = append(opts, notesService.WithJWT(verifier))
+	}
+
+	ns := notesService.NewNotesService(*addr, st, opts...)
+	serverStarted = true
 
 	signalCh := make(chan os.Signal, 1)     // канал для получения сигнала
 	signal.Notify(signalCh, syscall.SIGINT) // привязываем его к сигналу SIGINT
@@ -23,4 +173,17 @@ func main() {
 	}()
 
 	ns.Start()
+	if err := ns.CloseStorage(); err != nil {
+		log.Println("cannot close storage:", err)
+	}
+	return nil
+}
+
+// closeStorage закрывает хранилище name, если оно это поддерживает (например, файловое)
+func closeStorage(name string, st storage.Storage) {
+	if closer, ok := st.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("cannot close", name, "storage:", err)
+		}
+	}
 }
@@ -0,0 +1,10 @@
+package dto
+
+// AuthorMatch - автор заметок, найденный нечетким поиском по имени и фамилии
+type AuthorMatch struct {
+	Name       string  `json:"name"`
+	LastName   string  `json:"last_name"`
+	Distance   int     `json:"distance"`   // Количество опечаток (расстояние Левенштейна) между запросом и именем автора
+	Similarity float64 `json:"similarity"` // Триграммная схожесть запроса с именем автора, от 0 до 1
+	NoteIDs    []int64 `json:"note_ids"`   // ID заметок автора по возрастанию
+}
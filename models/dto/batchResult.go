@@ -0,0 +1,7 @@
+package dto
+
+// BatchResult - результат пакетной операции над одной заметкой
+type BatchResult struct {
+	ID    int64  `json:"id"`
+	Error string `json:"error,omitempty"` // пустая строка, если операция над заметкой выполнена
+}
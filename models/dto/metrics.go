@@ -0,0 +1,17 @@
+package dto
+
+// Metrics - состояние сервиса заметок для мониторинга
+type Metrics struct {
+	Notes      int64                     `json:"notes"`
+	Users      int64                     `json:"users"`
+	RateLimits map[string]RateLimitStats `json:"rate_limits"` // маршрут -> состояние его лимитера
+}
+
+// RateLimitStats - состояние лимитера частоты запросов к маршруту
+type RateLimitStats struct {
+	Rate    float64 `json:"rate"` // запросов в секунду на клиента
+	Burst   int     `json:"burst"`
+	Clients int     `json:"clients"` // клиенты, для которых хранится корзина запросов
+	Allowed uint64  `json:"allowed"`
+	Limited uint64  `json:"limited"` // запросы, отклоненные со статусом 429
+}
@@ -5,6 +5,7 @@ type Note struct {
 	Name     string `json:"name,omitempty"`
 	LastName string `json:"last_name,omitempty"`
 	Content  string `json:"note,omitempty"`
+	OwnerID  int64  `json:"owner_id,omitempty"` // задается сервером: клиент не может выбрать или сменить владельца
 }
 
 func NewNote() *Note {
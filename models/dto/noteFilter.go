@@ -0,0 +1,13 @@
+package dto
+
+// NoteFilter - условия поиска заметок. Пустые поля не участвуют в поиске.
+type NoteFilter struct {
+	Name     string `json:"name,omitempty"`      // Имя автора (без учета регистра и различия ё/е)
+	LastName string `json:"last_name,omitempty"` // Фамилия автора (без учета регистра и различия ё/е)
+	Content  string `json:"note,omitempty"`      // Подстрока содержимого заметки (без учета регистра и различия ё/е)
+	Limit    int    `json:"limit,omitempty"`     // Максимальное количество заметок в ответе (0 - без ограничения)
+}
+
+func NewNoteFilter() *NoteFilter {
+	return &NoteFilter{}
+}
@@ -0,0 +1,14 @@
+package dto
+
+// ReorderRequest - запрос на перемещение заметки в пользовательском порядке.
+// Задается ровно одно из полей Before, After или Position.
+type ReorderRequest struct {
+	ID       int64  `json:"id"`
+	Before   int64  `json:"before,omitempty"`   // ID заметки, перед которой нужно поставить заметку
+	After    int64  `json:"after,omitempty"`    // ID заметки, после которой нужно поставить заметку
+	Position string `json:"position,omitempty"` // "front" - в начало, "back" - в конец
+}
+
+func NewReorderRequest() *ReorderRequest {
+	return &ReorderRequest{ID: -1}
+}
@@ -6,6 +6,13 @@ type Response struct {
 	Result string          `json:"result"`
 	Data   json.RawMessage `json:"data"`
 	Error  string          `json:"error"`
+	Status int             `json:"-"` // HTTP-статус ответа (0 - 200 OK)
+
+	RequestID string `json:"request_id,omitempty"` // Идентификатор запроса (X-Request-ID), только в ответе на панику в обработчике
+
+	// Поля постраничного вывода списков (заполняются только списками заметок)
+	Total      *int64 `json:"total,omitempty"`       // Количество записей, удовлетворяющих фильтрам, без учета страницы
+	NextCursor string `json:"next_cursor,omitempty"` // Курсор следующей страницы, пустой на последней странице
 }
 
 func (r *Response) Update(result string, data json.RawMessage, error string) {
@@ -13,3 +20,15 @@ func (r *Response) Update(result string, data json.RawMessage, error string) {
 	r.Data = data
 	r.Error = error
 }
+
+// UpdateWithStatus обновляет ответ и устанавливает его HTTP-статус
+func (r *Response) UpdateWithStatus(status int, result string, data json.RawMessage, error string) {
+	r.Update(result, data, error)
+	r.Status = status
+}
+
+// SetPage устанавливает общее количество записей и курсор следующей страницы
+func (r *Response) SetPage(total int64, nextCursor string) {
+	r.Total = &total
+	r.NextCursor = nextCursor
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+type Revision struct {
+	ID        int64     `json:"id"`
+	Revision  int64     `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	Name      string    `json:"name,omitempty"`
+	LastName  string    `json:"last_name,omitempty"`
+	Content   string    `json:"note,omitempty"`
+}
+
+// RevisionRequest - содержимое запросов к истории ревизий заметки
+type RevisionRequest struct {
+	ID       int64 `json:"id"`
+	Revision int64 `json:"revision,omitempty"`
+	From     int64 `json:"from,omitempty"`
+	To       int64 `json:"to,omitempty"`
+}
+
+func NewRevisionRequest() *RevisionRequest {
+	return &RevisionRequest{ID: -1}
+}
+
+type DiffLine struct {
+	Op   string `json:"op"` // "=" - строка без изменений, "-" - удаленная строка, "+" - добавленная строка
+	Line string `json:"line"`
+}
+
+type Diff struct {
+	ID    int64       `json:"id"`
+	From  int64       `json:"from"`
+	To    int64       `json:"to"`
+	Lines []*DiffLine `json:"lines"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// SearchResult - заметка, найденная полнотекстовым поиском
+type SearchResult struct {
+	Note
+	Score    float64           `json:"score"`              // Релевантность заметки запросу (больше - релевантнее)
+	Snippets map[string]string `json:"snippets,omitempty"` // Поле -> фрагмент с совпадениями, выделенными <mark></mark>
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// Share - право доступа к заметке, выданное пользователю (User) или группе (Group)
+type Share struct {
+	User       string    `json:"user,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	Permission string    `json:"permission"`
+	GrantedBy  string    `json:"granted_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShareRequest - содержимое запроса на выдачу права доступа к заметке
+type ShareRequest struct {
+	Permission string `json:"permission"`
+}
+
+// Link - публичная ссылка на чтение заметки
+type Link struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Once      bool      `json:"once"`
+}
+
+// LinkRequest - содержимое запроса на создание публичной ссылки на заметку
+type LinkRequest struct {
+	TTL  string `json:"ttl"`  // срок действия, например "72h"
+	Once bool   `json:"once"` // ссылка открывается только один раз
+}
@@ -0,0 +1,40 @@
+package dto
+
+import "time"
+
+// Credentials - логин и пароль пользователя в запросах /register и /login
+type Credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// User - данные пользователя в ответах
+type User struct {
+	ID     int64    `json:"id"`
+	Login  string   `json:"login"`
+	Admin  bool     `json:"admin,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Token - API-токен, выданный при входе. Передается в заголовке Authorization: Bearer <token>.
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LogoutRequest - содержимое запроса /logout
+type LogoutRequest struct {
+	All bool `json:"all,omitempty"` // Отозвать все токены пользователя, а не только текущий
+}
+
+// GroupsRequest - запрос на назначение групп пользователя
+type GroupsRequest struct {
+	Login  string   `json:"login"`
+	Groups []string `json:"groups"`
+}
+
+// RoleRequest - запрос на назначение или снятие роли администратора
+type RoleRequest struct {
+	Login string `json:"login"`
+	Admin bool   `json:"admin"`
+}
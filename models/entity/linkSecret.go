@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"encoding/gob"
+	"time"
+)
+
+// Регистрация LinkSecret для хранилищ, сохраняющих значения через encoding/gob
+func init() {
+	gob.Register(LinkSecret{})
+}
+
+// LinkSecret - секрет, которым подписываются публичные ссылки на заметку, и одноразовые ссылки, по которым
+// уже открывали заметку. Смена секрета отзывает все выданные ссылки.
+type LinkSecret struct {
+	NoteID    int64
+	Secret    []byte
+	RotatedAt time.Time
+	Used      map[string]time.Time // nonce использованной одноразовой ссылки -> время ее истечения
+}
@@ -1,12 +1,22 @@
 package entity
 
-import "notesServer/models/dto"
+import (
+	"bytes"
+	"encoding/gob"
+	"notesServer/models/dto"
+)
+
+// Регистрация PureNote для хранилищ, сохраняющих значения через encoding/gob
+func init() {
+	gob.Register(PureNote{})
+}
 
 // PureNote это тот же dto.Note, но без ID. Это нужно для хранения заметок в storage.
 type PureNote struct {
 	name     string
 	lastName string
 	content  string
+	owner    int64 // ID пользователя-владельца, 0 - заметка создана до появления пользователей
 }
 
 // GetPureNote преобразует dto.Note в PureNote
@@ -15,6 +25,7 @@ func GetPureNote(note *dto.Note) PureNote {
 		name:     note.Name,
 		lastName: note.LastName,
 		content:  note.Content,
+		owner:    note.OwnerID,
 	}
 }
 
@@ -25,5 +36,52 @@ func (pn PureNote) ToNoteWithID(id int64) *dto.Note {
 		Name:     pn.name,
 		LastName: pn.lastName,
 		Content:  pn.content,
+		OwnerID:  pn.owner,
+	}
+}
+
+// Name возвращает имя автора заметки
+func (pn PureNote) Name() string {
+	return pn.name
+}
+
+// LastName возвращает фамилию автора заметки
+func (pn PureNote) LastName() string {
+	return pn.lastName
+}
+
+// Content возвращает содержимое заметки
+func (pn PureNote) Content() string {
+	return pn.content
+}
+
+// Owner возвращает ID пользователя-владельца заметки
+func (pn PureNote) Owner() int64 {
+	return pn.owner
+}
+
+// pureNoteGob - представление PureNote с экспортируемыми полями для encoding/gob
+type pureNoteGob struct {
+	Name     string
+	LastName string
+	Content  string
+	Owner    int64
+}
+
+// GobEncode кодирует заметку для encoding/gob
+func (pn PureNote) GobEncode() ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := gob.NewEncoder(&buf).Encode(pureNoteGob{Name: pn.name, LastName: pn.lastName, Content: pn.content, Owner: pn.owner})
+	return buf.Bytes(), err
+}
+
+// GobDecode декодирует заметку, закодированную методом GobEncode
+func (pn *PureNote) GobDecode(data []byte) error {
+	decoded := pureNoteGob{}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded)
+	if err != nil {
+		return err
 	}
+	pn.name, pn.lastName, pn.content, pn.owner = decoded.Name, decoded.LastName, decoded.Content, decoded.Owner
+	return nil
 }
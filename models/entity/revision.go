@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"notesServer/models/dto"
+	"time"
+)
+
+// Revision это сохраненное состояние заметки на момент создания или обновления.
+type Revision struct {
+	Number    int64     // Порядковый номер ревизии (начиная с 1) в истории конкретной заметки
+	Note      PureNote  // Содержимое заметки в этой ревизии
+	Timestamp time.Time // Время создания ревизии
+	Author    string    // Автор изменения
+}
+
+// ToRevisionWithID возвращает dto.Revision для заметки с указанным ID
+func (r Revision) ToRevisionWithID(id int64) *dto.Revision {
+	return &dto.Revision{
+		ID:        id,
+		Revision:  r.Number,
+		Timestamp: r.Timestamp,
+		Author:    r.Author,
+		Name:      r.Note.name,
+		LastName:  r.Note.lastName,
+		Content:   r.Note.content,
+	}
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"encoding/gob"
+	"time"
+)
+
+// Регистрация Share для хранилищ, сохраняющих значения через encoding/gob
+func init() {
+	gob.Register(Share{})
+}
+
+// Share - выданное владельцем заметки право доступа к ней пользователю или группе пользователей.
+// Задано ровно одно из полей UserID и Group.
+type Share struct {
+	NoteID     int64
+	UserID     int64  // ID пользователя, которому выдано право
+	Group      string // группа пользователей, которой выдано право
+	Permission string // "read" или "edit", см. sharing.Permission
+	GrantedBy  int64  // ID пользователя, выдавшего право
+	CreatedAt  time.Time
+}
@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"encoding/gob"
+	"time"
+)
+
+// Регистрация User для хранилищ, сохраняющих значения через encoding/gob
+func init() {
+	gob.Register(User{})
+}
+
+// User - учетная запись пользователя в хранилище пользователей. ID пользователя - ID записи в хранилище.
+type User struct {
+	Login        string
+	PasswordHash string // хеш пароля с солью, см. auth.hashPassword (пусто у пользователей, входящих по JWT)
+	Tokens       []Token
+	CreatedAt    time.Time
+	Admin        bool     // администратор видит и изменяет заметки всех пользователей
+	Groups       []string // группы, которым владельцы заметок могут выдавать права доступа
+	Issuer       string   // iss JWT пользователя, входящего по JWT (см. auth.Users.Provision)
+	Subject      string   // sub JWT пользователя, входящего по JWT: вместе с Issuer определяет учетную запись
+}
+
+// Token - выданный пользователю API-токен. Сам токен не хранится, только его хеш.
+type Token struct {
+	Hash      string // SHA-256 токена в шестнадцатеричном виде
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
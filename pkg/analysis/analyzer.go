@@ -0,0 +1,89 @@
+// Package analysis разбивает текст на термины для полнотекстового поиска:
+// выделяет слова, приводит их к единому регистру, заменяет ё на е и отсекает окончания (стемминг)
+// по алгоритмам Snowball для русского и английского языков, транслитерирует кириллицу для поиска имен.
+package analysis
+
+// Token - термин текста вместе с его порядковым номером и положением в исходном тексте
+type Token struct {
+	Term     string // нормализованный термин, по которому производится поиск
+	Position int    // порядковый номер термина в тексте
+	Start    int    // смещение начала слова в исходном тексте в байтах
+	End      int    // смещение конца слова в исходном тексте в байтах
+}
+
+// Tokenizer разбивает текст на слова. Term возвращаемых токенов содержит слово в исходном виде.
+type Tokenizer func(text string) []Token
+
+// Filter преобразует термин. Если Filter возвращает пустую строку, термин отбрасывается.
+type Filter func(term string) string
+
+// Analyzer - цепочка из токенизатора и фильтров терминов
+type Analyzer struct {
+	tokenizer Tokenizer
+	filters   []Filter
+}
+
+// NewAnalyzer возвращает анализатор, разбивающий текст tokenizer и применяющий к каждому слову filters по порядку
+func NewAnalyzer(tokenizer Tokenizer, filters ...Filter) *Analyzer {
+	return &Analyzer{tokenizer: tokenizer, filters: filters}
+}
+
+// Analyze разбивает текст на термины. Позиции отброшенных терминов не занимаются,
+// поэтому слова по обе стороны от отброшенного считаются соседними.
+func (a *Analyzer) Analyze(text string) []Token {
+	tokens := a.tokenizer(text)
+	result := tokens[:0]
+	for _, token := range tokens {
+		term := token.Term
+		for _, filter := range a.filters {
+			term = filter(term)
+			if term == "" {
+				break
+			}
+		}
+		if term == "" {
+			continue
+		}
+		token.Term = term
+		token.Position = len(result)
+		result = append(result, token)
+	}
+	return result
+}
+
+// Russian возвращает анализатор русского текста: слова из букв и цифр, приведение регистра, ё -> е
+// и стемминг по алгоритму Snowball для русского языка
+func Russian() *Analyzer {
+	return NewAnalyzer(WordTokenizer, Fold, NormalizeYo, StemRussian)
+}
+
+// English возвращает анализатор английского текста: слова с апострофами, приведение регистра
+// и стемминг по алгоритму Snowball (Porter2) для английского языка
+func English() *Analyzer {
+	return NewAnalyzer(EnglishTokenizer, Fold, StemEnglish)
+}
+
+// Multilingual возвращает анализатор текста, смешивающего русский и английский языки:
+// к словам из кириллицы применяется русский стеммер, к словам из латиницы - английский
+func Multilingual() *Analyzer {
+	return NewAnalyzer(EnglishTokenizer, Fold, NormalizeYo, StemByScript)
+}
+
+// Names возвращает анализатор имен людей: слова из букв и цифр, приведение регистра
+// и транслитерация кириллицы латиницей без стемминга, чтобы "Иванов" и "Ivanov" совпадали
+func Names() *Analyzer {
+	return NewAnalyzer(WordTokenizer, Fold, Transliterate)
+}
+
+// StemByScript применяет к термину стеммер языка его алфавита: русский для кириллицы, английский для латиницы.
+// Термины из других алфавитов и смешанные термины не изменяются.
+func StemByScript(term string) string {
+	switch scriptOf(term) {
+	case scriptCyrillic:
+		return StemRussian(term)
+	case scriptLatin:
+		return StemEnglish(term)
+	default:
+		return term
+	}
+}
@@ -0,0 +1,293 @@
+package analysis
+
+import "strings"
+
+// Исключения английского стеммера Snowball (https://snowballstem.org/algorithms/english/stemmer.html)
+var (
+	enExceptions = map[string]string{
+		"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+		"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+		"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+	}
+	enExceptionsAfterStep1a = map[string]bool{
+		"inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+		"proceed": true, "exceed": true, "succeed": true,
+	}
+	enR1Prefixes = []string{"gener", "commun", "arsen"}
+	enDoubles    = []string{"bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt"}
+	enLiEndings  = "cdeghkmnrt"
+)
+
+// Замены шагов 2 и 3: суффикс -> замена, применяются к самому длинному подходящему суффиксу
+var (
+	enStep2 = map[string]string{
+		"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+		"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+		"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous", "ousness": "ous",
+		"iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble", "fulli": "ful", "lessli": "less",
+		"ogi": "og", "li": "",
+	}
+	enStep3 = map[string]string{
+		"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic", "ical": "ic",
+		"ful": "", "ness": "", "ative": "",
+	}
+	enStep4 = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+		"ism", "ate", "iti", "ous", "ive", "ize", "ion",
+	}
+)
+
+// StemEnglish отсекает окончание английского слова по алгоритму Snowball (Porter2).
+// Слово должно быть в нижнем регистре. Слова с символами вне a-z и апострофа не изменяются.
+func StemEnglish(word string) string {
+	for _, r := range word {
+		if (r < 'a' || r > 'z') && r != '\'' {
+			return word
+		}
+	}
+	if len(word) <= 2 {
+		return word
+	}
+	if stem, ok := enExceptions[word]; ok {
+		return stem
+	}
+
+	s := &enStemmer{w: []byte(strings.TrimPrefix(word, "'"))}
+	s.markConsonantY()
+	s.computeRegions()
+
+	s.step0()
+	s.step1a()
+	if enExceptionsAfterStep1a[string(s.w)] {
+		return string(s.w)
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return strings.ReplaceAll(string(s.w), "Y", "y")
+}
+
+// enStemmer - слово в процессе стемминга. Согласная "y" обозначается как "Y".
+type enStemmer struct {
+	w      []byte
+	r1, r2 int
+}
+
+func isEnVowel(b byte) bool {
+	return strings.IndexByte("aeiouy", b) >= 0
+}
+
+// markConsonantY заменяет "y" в начале слова и после гласной на "Y"
+func (s *enStemmer) markConsonantY() {
+	for i := range s.w {
+		if s.w[i] == 'y' && (i == 0 || isEnVowel(s.w[i-1])) {
+			s.w[i] = 'Y'
+		}
+	}
+}
+
+// computeRegions вычисляет начало областей R1 и R2
+func (s *enStemmer) computeRegions() {
+	afterVowelConsonant := func(from int) int {
+		for i := from + 1; i < len(s.w); i++ {
+			if !isEnVowel(s.w[i]) && isEnVowel(s.w[i-1]) {
+				return i + 1
+			}
+		}
+		return len(s.w)
+	}
+
+	s.r1 = -1
+	for _, prefix := range enR1Prefixes {
+		if strings.HasPrefix(string(s.w), prefix) {
+			s.r1 = len(prefix)
+		}
+	}
+	if s.r1 < 0 {
+		s.r1 = afterVowelConsonant(0)
+	}
+	s.r2 = afterVowelConsonant(s.r1)
+}
+
+func (s *enStemmer) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.w), suffix)
+}
+
+// replace заменяет суффикс длины suffixLength на replacement
+func (s *enStemmer) replace(suffixLength int, replacement string) {
+	s.w = append(s.w[:len(s.w)-suffixLength], replacement...)
+}
+
+// inR1 и inR2 проверяют, что суффикс длины suffixLength целиком лежит в области
+func (s *enStemmer) inR1(suffixLength int) bool { return len(s.w)-suffixLength >= s.r1 }
+func (s *enStemmer) inR2(suffixLength int) bool { return len(s.w)-suffixLength >= s.r2 }
+
+// containsVowel проверяет наличие гласной в первых n буквах слова
+func (s *enStemmer) containsVowel(n int) bool {
+	for _, b := range s.w[:n] {
+		if isEnVowel(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsWithShortSyllable проверяет, что первые n букв слова оканчиваются коротким слогом:
+// согласная, гласная и согласная кроме w, x и Y, либо гласная и согласная в начале слова
+func (s *enStemmer) endsWithShortSyllable(n int) bool {
+	w := s.w[:n]
+	if n == 2 {
+		return isEnVowel(w[0]) && !isEnVowel(w[1])
+	}
+	return n >= 3 && !isEnVowel(w[n-3]) && isEnVowel(w[n-2]) && !isEnVowel(w[n-1]) && strings.IndexByte("wxY", w[n-1]) < 0
+}
+
+// isShort проверяет, что слово короткое: R1 пусто и слово оканчивается коротким слогом
+func (s *enStemmer) isShort() bool {
+	return s.r1 >= len(s.w) && s.endsWithShortSyllable(len(s.w))
+}
+
+// longestSuffix возвращает самый длинный из суффиксов слова
+func (s *enStemmer) longestSuffix(suffixes []string) string {
+	longest := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(longest) && s.hasSuffix(suffix) {
+			longest = suffix
+		}
+	}
+	return longest
+}
+
+// step0 удаляет притяжательные окончания
+func (s *enStemmer) step0() {
+	if suffix := s.longestSuffix([]string{"'s'", "'s", "'"}); suffix != "" {
+		s.replace(len(suffix), "")
+	}
+}
+
+// step1a обрабатывает окончания множественного числа
+func (s *enStemmer) step1a() {
+	switch suffix := s.longestSuffix([]string{"sses", "ied", "ies", "us", "ss", "s"}); suffix {
+	case "sses":
+		s.replace(4, "ss")
+	case "ied", "ies":
+		if len(s.w) > 4 {
+			s.replace(3, "i")
+		} else {
+			s.replace(3, "ie")
+		}
+	case "s":
+		// "s" удаляется, если перед предпоследней буквой есть гласная
+		if s.containsVowel(len(s.w) - 2) {
+			s.replace(1, "")
+		}
+	}
+}
+
+// step1b обрабатывает окончания -ed и -ing
+func (s *enStemmer) step1b() {
+	suffix := s.longestSuffix([]string{"eed", "eedly", "ed", "edly", "ing", "ingly"})
+	switch suffix {
+	case "":
+		return
+	case "eed", "eedly":
+		if s.inR1(len(suffix)) {
+			s.replace(len(suffix), "ee")
+		}
+		return
+	}
+	if !s.containsVowel(len(s.w) - len(suffix)) {
+		return
+	}
+	s.replace(len(suffix), "")
+
+	switch {
+	case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+		s.replace(0, "e")
+	case s.longestSuffix(enDoubles) != "":
+		s.replace(1, "")
+	case s.isShort():
+		s.replace(0, "e")
+	}
+}
+
+// step1c заменяет конечную "y" после согласной (не первой буквы) на "i"
+func (s *enStemmer) step1c() {
+	n := len(s.w)
+	if n > 2 && (s.w[n-1] == 'y' || s.w[n-1] == 'Y') && !isEnVowel(s.w[n-2]) {
+		s.w[n-1] = 'i'
+	}
+}
+
+// step2 заменяет словообразовательные суффиксы в R1
+func (s *enStemmer) step2() {
+	suffix := s.longestSuffix(mapKeys(enStep2))
+	if suffix == "" || !s.inR1(len(suffix)) {
+		return
+	}
+	switch suffix {
+	case "ogi":
+		if len(s.w) < 4 || s.w[len(s.w)-4] != 'l' {
+			return
+		}
+	case "li":
+		if len(s.w) < 3 || strings.IndexByte(enLiEndings, s.w[len(s.w)-3]) < 0 {
+			return
+		}
+	}
+	s.replace(len(suffix), enStep2[suffix])
+}
+
+// step3 заменяет суффиксы в R1, "ative" удаляется только в R2
+func (s *enStemmer) step3() {
+	suffix := s.longestSuffix(mapKeys(enStep3))
+	if suffix == "" || !s.inR1(len(suffix)) {
+		return
+	}
+	if suffix == "ative" && !s.inR2(len(suffix)) {
+		return
+	}
+	s.replace(len(suffix), enStep3[suffix])
+}
+
+// step4 удаляет суффиксы в R2, "ion" - только после "s" или "t"
+func (s *enStemmer) step4() {
+	suffix := s.longestSuffix(enStep4)
+	if suffix == "" || !s.inR2(len(suffix)) {
+		return
+	}
+	if suffix == "ion" {
+		n := len(s.w)
+		if n < 4 || (s.w[n-4] != 's' && s.w[n-4] != 't') {
+			return
+		}
+	}
+	s.replace(len(suffix), "")
+}
+
+// step5 удаляет конечные "e" и "l"
+func (s *enStemmer) step5() {
+	n := len(s.w)
+	switch {
+	case s.w[n-1] == 'e':
+		if s.inR2(1) || (s.inR1(1) && !s.endsWithShortSyllable(n-1)) {
+			s.replace(1, "")
+		}
+	case s.w[n-1] == 'l':
+		if s.inR2(1) && n >= 2 && s.w[n-2] == 'l' {
+			s.replace(1, "")
+		}
+	}
+}
+
+// mapKeys возвращает ключи таблицы замен
+func mapKeys(replacements map[string]string) []string {
+	keys := make([]string, 0, len(replacements))
+	for key := range replacements {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,116 @@
+package analysis
+
+import "testing"
+
+// Пары слово -> основа из эталонного словаря английского стеммера Snowball (Porter2)
+// (https://snowballstem.org/algorithms/english/stemmer.html, voc.txt и output.txt)
+var englishStems = []struct {
+	word string
+	stem string
+}{
+	{"consign", "consign"},
+	{"consigned", "consign"},
+	{"consigning", "consign"},
+	{"consignment", "consign"},
+	{"consistency", "consist"},
+	{"consistent", "consist"},
+	{"consistently", "consist"},
+	{"consolation", "consol"},
+	{"consolations", "consol"},
+	{"consolatory", "consolatori"},
+	{"consolidate", "consolid"},
+	{"consolingly", "consol"},
+	{"conspicuous", "conspicu"},
+	{"conspiracy", "conspiraci"},
+	{"conspirator", "conspir"},
+	{"constable", "constabl"},
+	{"constance", "constanc"},
+	{"constancy", "constanc"},
+	{"knackeries", "knackeri"},
+	{"knaves", "knave"},
+	{"knavish", "knavish"},
+	{"kneaded", "knead"},
+	{"knees", "knee"},
+	{"knightly", "knight"},
+	{"knitting", "knit"},
+	{"knives", "knive"},
+	{"knocker", "knocker"},
+	// Шаг 1
+	{"caresses", "caress"},
+	{"ponies", "poni"},
+	{"ties", "tie"},
+	{"cried", "cri"},
+	{"agreed", "agre"},
+	{"feed", "feed"},
+	{"running", "run"},
+	{"hopping", "hop"},
+	{"happily", "happili"},
+	{"luxuriated", "luxuri"},
+	{"hopeful", "hope"},
+	// Исключения и особые начала R1
+	{"skies", "sky"},
+	{"dying", "die"},
+	{"news", "news"},
+	{"gently", "gentl"},
+	{"inning", "inning"},
+	{"proceed", "proceed"},
+	{"generously", "generous"},
+	{"communication", "communic"},
+	{"arsenal", "arsenal"},
+	{"'tis", "tis"},
+}
+
+func TestStemEnglish(t *testing.T) {
+	for _, tt := range englishStems {
+		if stem := StemEnglish(tt.word); stem != tt.stem {
+			t.Errorf("StemEnglish(%q) = %q, want %q", tt.word, stem, tt.stem)
+		}
+	}
+}
+
+func TestStemEnglishKeepsNonLatinWords(t *testing.T) {
+	for _, word := range []string{"заметки", "naïve", "x86"} {
+		if stem := StemEnglish(word); stem != word {
+			t.Errorf("StemEnglish(%q) = %q, want the word unchanged", word, stem)
+		}
+	}
+}
+
+// TestEnglishAnalyzerMatchesWordForms проверяет, что формы слова в разном регистре дают один термин
+func TestEnglishAnalyzerMatchesWordForms(t *testing.T) {
+	tests := []struct {
+		query    string
+		document string
+	}{
+		{"notes", "Note"},
+		{"RUNNING", "run"},
+		{"Consistently", "CONSISTENT"},
+		{"knives", "Knives"},
+	}
+	for _, analyzer := range []struct {
+		name     string
+		analyzer *Analyzer
+	}{{"English", English()}, {"Multilingual", Multilingual()}} {
+		for _, tt := range tests {
+			query := analyzeTerms(analyzer.analyzer, tt.query)
+			document := analyzeTerms(analyzer.analyzer, tt.document)
+			if len(query) != 1 || len(document) != 1 || query[0] != document[0] {
+				t.Errorf("%s: %q -> %q and %q -> %q must be the same term", analyzer.name, tt.query, query, tt.document, document)
+			}
+		}
+	}
+}
+
+// TestMultilingualStemsByScript проверяет, что в смешанном тексте каждое слово обрабатывается стеммером своего языка
+func TestMultilingualStemsByScript(t *testing.T) {
+	terms := analyzeTerms(Multilingual(), "Заметки about RUNNING тестов")
+	want := []string{"заметк", "about", "run", "тест"}
+	if len(terms) != len(want) {
+		t.Fatalf("Multilingual terms = %q, want %q", terms, want)
+	}
+	for i := range want {
+		if terms[i] != want[i] {
+			t.Fatalf("Multilingual terms = %q, want %q", terms, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fold приводит термин к единому регистру по правилам Unicode case folding:
+// в отличие от strings.ToLower, совпадают все варианты написания одной буквы (например, "ς" и "σ", "ß" и "ss").
+// Комбинирующие символы (ударения) удаляются.
+func Fold(term string) string {
+	builder := strings.Builder{}
+	builder.Grow(len(term))
+	for _, r := range term {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Комбинирующий символ не меняет слово: "заме́тка" и "заметка" - одно слово
+		case r == 'ß' || r == 'ẞ':
+			builder.WriteString("ss")
+		default:
+			builder.WriteRune(foldRune(r))
+		}
+	}
+	return builder.String()
+}
+
+// foldRune возвращает свернутый вариант буквы: строчную форму ее прописной формы
+func foldRune(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+// NormalizeYo заменяет ё на е, так как в русских текстах ё часто пишут как е
+func NormalizeYo(term string) string {
+	if !strings.ContainsAny(term, "ёЁ") {
+		return term
+	}
+	return strings.NewReplacer("ё", "е", "Ё", "Е").Replace(term)
+}
+
+// Normalize приводит строку к виду для сравнения без учета регистра и различия ё/е
+func Normalize(s string) string {
+	return NormalizeYo(Fold(s))
+}
+
+// Алфавиты терминов
+const (
+	scriptOther = iota
+	scriptCyrillic
+	scriptLatin
+)
+
+// scriptOf возвращает алфавит, которым записаны все буквы термина.
+// Если в термине нет букв или есть буквы разных алфавитов, возвращается scriptOther.
+func scriptOf(term string) int {
+	script := scriptOther
+	for _, r := range term {
+		current := scriptOther
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			current = scriptCyrillic
+		case unicode.Is(unicode.Latin, r):
+			current = scriptLatin
+		case unicode.IsLetter(r):
+			return scriptOther
+		default:
+			continue // цифры и апострофы не влияют на алфавит
+		}
+		if script != scriptOther && script != current {
+			return scriptOther
+		}
+		script = current
+	}
+	return script
+}
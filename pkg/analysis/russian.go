@@ -0,0 +1,152 @@
+package analysis
+
+import "strings"
+
+// Окончания русского стеммера Snowball (https://snowballstem.org/algorithms/russian/stemmer.html).
+// Окончания группы 1 отсекаются, только если перед ними стоит "а" или "я".
+var (
+	ruPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+	ruPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	ruAdjective         = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruReflexive   = []string{"ся", "сь"}
+	ruVerb1       = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	ruVerb2       = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	}
+	ruNoun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	}
+	ruSuperlative     = []string{"ейш", "ейше"}
+	ruDerivational    = []string{"ост", "ость"}
+	ruGroup1Preceding = "ая" // буквы, после которых отсекаются окончания группы 1
+)
+
+// StemRussian отсекает окончание русского слова по алгоритму Snowball.
+// Слово должно быть в нижнем регистре, с ё, замененной на е.
+func StemRussian(word string) string {
+	w := []rune(word)
+	rv, r2 := russianRegions(w)
+	if rv >= len(w) {
+		return word
+	}
+
+	// Шаг 1: деепричастие, иначе возвратная частица, затем прилагательное (причастие), глагол или существительное
+	if end, ok := removeEnding(w, rv, ruPerfectiveGerund1, ruPerfectiveGerund2); ok {
+		w = w[:end]
+	} else {
+		if end, ok := removeEnding(w, rv, nil, ruReflexive); ok {
+			w = w[:end]
+		}
+		if end, ok := removeAdjectival(w, rv); ok {
+			w = w[:end]
+		} else if end, ok := removeEnding(w, rv, ruVerb1, ruVerb2); ok {
+			w = w[:end]
+		} else if end, ok := removeEnding(w, rv, nil, ruNoun); ok {
+			w = w[:end]
+		}
+	}
+
+	// Шаг 2: окончание "и"
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// Шаг 3: словообразовательное окончание в R2
+	if end, ok := removeEnding(w, max(rv, r2), nil, ruDerivational); ok {
+		w = w[:end]
+	}
+
+	// Шаг 4: "нн" -> "н", превосходная степень или мягкий знак
+	if end, ok := removeEnding(w, rv, nil, ruSuperlative); ok {
+		w = w[:end]
+	}
+	switch {
+	case hasSuffixAfter(w, rv, "нн"):
+		w = w[:len(w)-1]
+	case len(w) > rv && w[len(w)-1] == 'ь':
+		w = w[:len(w)-1]
+	}
+	return string(w)
+}
+
+// removeAdjectival находит окончание прилагательного, которому может предшествовать окончание причастия,
+// и возвращает длину слова без них
+func removeAdjectival(w []rune, rv int) (int, bool) {
+	end, ok := removeEnding(w, rv, nil, ruAdjective)
+	if !ok {
+		return 0, false
+	}
+	if participleEnd, ok := removeEnding(w[:end], rv, ruParticiple1, ruParticiple2); ok {
+		return participleEnd, true
+	}
+	return end, true
+}
+
+// removeEnding находит самое длинное окончание слова w в области, начинающейся с from, и возвращает длину слова без него.
+// Окончания afterA (группа 1) подходят, только если перед ними в той же области стоит "а" или "я",
+// окончания endings (группа 2) подходят всегда.
+func removeEnding(w []rune, from int, afterA []string, endings []string) (int, bool) {
+	best := -1
+	for _, ending := range endings {
+		if hasSuffixAfter(w, from, ending) {
+			best = max(best, len([]rune(ending)))
+		}
+	}
+	for _, ending := range afterA {
+		length := len([]rune(ending))
+		if hasSuffixAfter(w, from+1, ending) && strings.ContainsRune(ruGroup1Preceding, w[len(w)-length-1]) {
+			best = max(best, length)
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return len(w) - best, true
+}
+
+// hasSuffixAfter проверяет, что слово w оканчивается на suffix и suffix начинается не раньше позиции from
+func hasSuffixAfter(w []rune, from int, suffix string) bool {
+	s := []rune(suffix)
+	start := len(w) - len(s)
+	if start < from || start < 0 {
+		return false
+	}
+	for i, r := range s {
+		if w[start+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// russianRegions возвращает начало областей RV (после первой гласной) и R2 слова
+func russianRegions(w []rune) (rv int, r2 int) {
+	isVowel := func(r rune) bool { return strings.ContainsRune("аеиоуыэюя", r) }
+	rv = len(w)
+	for i, r := range w {
+		if isVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := regionAfterVowelConsonant(w, 0, isVowel)
+	r2 = regionAfterVowelConsonant(w, r1, isVowel)
+	return rv, r2
+}
+
+// regionAfterVowelConsonant возвращает позицию после первой согласной, следующей за гласной, начиная с from
+func regionAfterVowelConsonant(w []rune, from int, isVowel func(rune) bool) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
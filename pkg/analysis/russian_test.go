@@ -0,0 +1,128 @@
+package analysis
+
+import "testing"
+
+// Пары слово -> основа из эталонного словаря русского стеммера Snowball
+// (https://snowballstem.org/algorithms/russian/stemmer.html, voc.txt и output.txt)
+var russianStems = []struct {
+	word string
+	stem string
+}{
+	{"в", "в"},
+	{"вавиловка", "вавиловк"},
+	{"вагнер", "вагнер"},
+	{"вагона", "вагон"},
+	{"вагоне", "вагон"},
+	{"вагонов", "вагон"},
+	{"вагоном", "вагон"},
+	{"вагоны", "вагон"},
+	{"важная", "важн"},
+	{"важнее", "важн"},
+	{"важнейшие", "важн"},
+	{"важнейшими", "важн"},
+	{"важничал", "важнича"},
+	{"важно", "важн"},
+	{"важного", "важн"},
+	{"важной", "важн"},
+	{"важности", "важност"},
+	{"важностию", "важност"},
+	{"важностью", "важност"},
+	{"важную", "важн"},
+	{"важны", "важн"},
+	{"важные", "важн"},
+	{"важный", "важн"},
+	{"важным", "важн"},
+	{"важных", "важн"},
+	{"вазах", "ваз"},
+	{"вакса", "вакс"},
+	{"валандался", "валанда"},
+	{"валентина", "валентин"},
+	{"валерьяну", "валерьян"},
+	{"валетами", "валет"},
+	{"вали", "вал"},
+	{"валил", "вал"},
+	{"валился", "вал"},
+	{"валится", "вал"},
+	{"вальдшнепа", "вальдшнеп"},
+	{"вальсишку", "вальсишк"},
+	{"валяется", "валя"},
+	{"валялась", "валя"},
+	{"валялись", "валя"},
+	{"валять", "валя"},
+	{"валяются", "валя"},
+	// Деепричастия, причастия, превосходная степень
+	{"прочитавши", "прочита"},
+	{"умывшись", "ум"},
+	{"прочитанный", "прочита"},
+	{"бегущий", "бегущ"},
+	{"красивейший", "красив"},
+	// Формы слова "заметка"
+	{"заметка", "заметк"},
+	{"заметки", "заметк"},
+	{"заметками", "заметк"},
+}
+
+func TestStemRussian(t *testing.T) {
+	for _, tt := range russianStems {
+		if stem := StemRussian(tt.word); stem != tt.stem {
+			t.Errorf("StemRussian(%q) = %q, want %q", tt.word, stem, tt.stem)
+		}
+	}
+}
+
+// analyzeTerms возвращает термины текста после анализа
+func analyzeTerms(analyzer *Analyzer, text string) []string {
+	tokens := analyzer.Analyze(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
+
+// TestRussianAnalyzerMatchesWordForms проверяет, что разные формы слова, написанные в разном регистре
+// и с ё или е, дают один термин, то есть находят друг друга при поиске
+func TestRussianAnalyzerMatchesWordForms(t *testing.T) {
+	tests := []struct {
+		query    string
+		document string
+	}{
+		{"заметки", "заметка"},
+		{"ЗАМЕТКИ", "Заметка"},
+		{"заметками", "ЗаМеТкА"},
+		{"ёлки", "елка"},
+		{"Ёлки", "ЕЛКИ"},
+		{"зелёных", "Зеленый"},
+		{"заме́тки", "заметка"}, // ударение
+	}
+	for _, analyzer := range []struct {
+		name     string
+		analyzer *Analyzer
+	}{{"Russian", Russian()}, {"Multilingual", Multilingual()}} {
+		for _, tt := range tests {
+			query := analyzeTerms(analyzer.analyzer, tt.query)
+			document := analyzeTerms(analyzer.analyzer, tt.document)
+			if len(query) != 1 || len(document) != 1 || query[0] != document[0] {
+				t.Errorf("%s: %q -> %q and %q -> %q must be the same term", analyzer.name, tt.query, query, tt.document, document)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Ёжик", "ежик"},
+		{"ЁЛКА", "елка"},
+		{"Straße", "strasse"},
+		{"ΣΊΣΥΦΟΣ", "σίσυφοσ"},
+		{"заме́тка", "заметка"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.s); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
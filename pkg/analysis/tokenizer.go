@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// WordTokenizer выделяет слова из букв и цифр. Комбинирующие символы (например, ударения) считаются частью слова.
+func WordTokenizer(text string) []Token {
+	return tokenize(text, func(r rune, _ string, _ int) bool {
+		return isWordRune(r)
+	})
+}
+
+// EnglishTokenizer выделяет слова так же, как WordTokenizer, но оставляет внутри слова апострофы
+// ("don't", "o'clock", "John's"), чтобы стеммер мог отбросить притяжательное окончание
+func EnglishTokenizer(text string) []Token {
+	return tokenize(text, func(r rune, text string, i int) bool {
+		if isWordRune(r) {
+			return true
+		}
+		if r != '\'' && r != '’' {
+			return false
+		}
+		// Апостроф входит в слово, только если с обеих сторон от него буквы
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):])
+		return unicode.IsLetter(before) && unicode.IsLetter(after)
+	})
+}
+
+// tokenize выделяет в тексте непрерывные последовательности символов, для которых inWord возвращает true
+func tokenize(text string, inWord func(r rune, text string, i int) bool) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if inWord(r, text, i) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: text[start:i], Position: len(tokens), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: text[start:], Position: len(tokens), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// isWordRune проверяет, что символ может входить в слово
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,31 @@
+package analysis
+
+import "strings"
+
+// translitTable - транслитерация строчных букв кириллицы латиницей по распространенной паспортной схеме
+// (Иванов -> ivanov, Щукин -> shchukin, Юрий -> yuriy). Украинские буквы транслитерируются так же.
+var translitTable = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+}
+
+// Transliterate заменяет буквы кириллицы латиницей, чтобы "Иванов" и "Ivanov" давали один термин.
+// Термин должен быть в нижнем регистре. Остальные символы не изменяются.
+func Transliterate(term string) string {
+	if scriptOf(term) == scriptLatin {
+		return term
+	}
+	builder := strings.Builder{}
+	builder.Grow(len(term))
+	for _, r := range term {
+		if latin, ok := translitTable[r]; ok {
+			builder.WriteString(latin)
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
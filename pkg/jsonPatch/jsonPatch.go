@@ -0,0 +1,77 @@
+// Package jsonPatch применяет к JSON-документам изменения в форматах JSON Merge Patch (RFC 7396)
+// и JSON Patch (RFC 6902)
+package jsonPatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Типы содержимого запросов с изменениями
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch ошибка, возвращаемая для синтаксически неверного изменения или документа
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrUnprocessable ошибка, возвращаемая, если операцию JSON Patch нельзя применить к документу
+	// (например, путь не существует)
+	ErrUnprocessable = errors.New("patch cannot be applied")
+	// ErrTestFailed ошибка, возвращаемая, если не выполнено условие операции "test"
+	ErrTestFailed = errors.New("patch test failed")
+)
+
+// Merge применяет к документу doc изменение patch в формате JSON Merge Patch (RFC 7396):
+// поля объекта patch заменяют поля документа, вложенные объекты объединяются рекурсивно,
+// а поля со значением null удаляются. Если patch - не объект, он заменяет документ целиком.
+func Merge(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decode document: %s", ErrInvalidPatch, err)
+	}
+	patchValue, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decode merge patch: %s", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergeValue(target, patchValue))
+}
+
+// mergeValue возвращает результат применения patch к target по правилам RFC 7396
+func mergeValue(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// decode разбирает JSON, сохраняя числа в виде json.Number, чтобы не терять точность больших целых
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
@@ -0,0 +1,154 @@
+package jsonPatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// normalize возвращает JSON с отсортированными ключами объектов, чтобы сравнивать документы строками
+func normalize(t *testing.T, doc string) string {
+	t.Helper()
+	value, err := decode([]byte(doc))
+	if err != nil {
+		t.Fatalf("decode(%s): %v", doc, err)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Примеры из приложения A RFC 7396
+func TestMerge(t *testing.T) {
+	tests := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// Большие целые не теряют точность
+		{`{"id":9007199254740993}`, `{"n":1}`, `{"id":9007199254740993,"n":1}`},
+	}
+	for _, test := range tests {
+		result, err := Merge([]byte(test.doc), []byte(test.patch))
+		if err != nil || string(result) != normalize(t, test.want) {
+			t.Errorf("Merge(%s, %s) = %s, %v, want %s", test.doc, test.patch, result, err, test.want)
+		}
+	}
+}
+
+func TestMergeRejectsInvalidJSON(t *testing.T) {
+	tests := []struct{ doc, patch string }{
+		{`{"a":`, `{}`},
+		{`{}`, `{"a":1`},
+		{`{}`, `{"a":1} {"b":2}`},
+		{`{} []`, `{}`},
+	}
+	for _, test := range tests {
+		if _, err := Merge([]byte(test.doc), []byte(test.patch)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("Merge(%s, %s): %v, want ErrInvalidPatch", test.doc, test.patch, err)
+		}
+	}
+}
+
+// Примеры из приложения A RFC 6902
+func TestApply(t *testing.T) {
+	tests := []struct{ doc, patch, want string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"foo":1}`, `[{"op":"test","path":"/foo","value":1.0}]`, `{"foo":1}`},
+		{`{"foo":{"bar":[1]}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"add","path":"/baz/bar/-","value":2}]`,
+			`{"foo":{"bar":[1]},"baz":{"bar":[1,2]}}`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{`{"foo":"bar"}`, `[]`, `{"foo":"bar"}`},
+	}
+	for _, test := range tests {
+		result, err := Apply([]byte(test.doc), []byte(test.patch))
+		if err != nil || string(result) != normalize(t, test.want) {
+			t.Errorf("Apply(%s, %s) = %s, %v, want %s", test.doc, test.patch, result, err, test.want)
+		}
+	}
+}
+
+func TestApplyRejectsPatches(t *testing.T) {
+	tests := []struct {
+		doc, patch string
+		err        error
+	}{
+		// Синтаксически неверные изменения
+		{`{}`, `{"op":"add","path":"/a","value":1}`, ErrInvalidPatch},
+		{`{}`, `[{"op":"add","value":1}]`, ErrInvalidPatch},
+		{`{}`, `[{"op":"add","path":"/a"}]`, ErrInvalidPatch},
+		{`{}`, `[{"op":"add","path":"a","value":1}]`, ErrInvalidPatch},
+		{`{}`, `[{"op":"move","path":"/a"}]`, ErrInvalidPatch},
+		{`{}`, `[{"op":"delete","path":"/a"}]`, ErrInvalidPatch},
+		{`{"a":1`, `[]`, ErrInvalidPatch},
+		{`{}`, `[{"op":"add","path":"/a","value":1} 2]`, ErrInvalidPatch},
+
+		// Пути, которых нет в документе
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrUnprocessable},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrUnprocessable},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, ErrUnprocessable},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/foo/x","value":1}]`, ErrUnprocessable},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, ErrUnprocessable},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/1"}]`, ErrUnprocessable},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/-"}]`, ErrUnprocessable},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/01","value":1}]`, ErrUnprocessable},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-1","value":1}]`, ErrUnprocessable},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/99999999999999999999","value":1}]`, ErrUnprocessable},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, ErrUnprocessable},
+		{`{"foo":1}`, `[{"op":"copy","from":"/bar","path":"/baz"}]`, ErrUnprocessable},
+
+		// Невыполненное условие
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrTestFailed},
+		{`{"foo":[1,2]}`, `[{"op":"test","path":"/foo","value":[2,1]}]`, ErrTestFailed},
+		{`{"foo":"1"}`, `[{"op":"test","path":"/foo","value":1}]`, ErrTestFailed},
+	}
+	for _, test := range tests {
+		result, err := Apply([]byte(test.doc), []byte(test.patch))
+		if !errors.Is(err, test.err) || result != nil {
+			t.Errorf("Apply(%s, %s) = %s, %v, want %v", test.doc, test.patch, result, err, test.err)
+		}
+	}
+}
+
+// Изменение выполняется целиком или не выполняется: после невыполненного условия документ не меняется
+func TestApplyIsAtomic(t *testing.T) {
+	doc := []byte(`{"owner":1,"note":"text"}`)
+	patch := []byte(`[{"op":"replace","path":"/owner","value":2},{"op":"test","path":"/note","value":"other"}]`)
+	result, err := Apply(doc, patch)
+	if !errors.Is(err, ErrTestFailed) || result != nil {
+		t.Fatalf("Apply() = %s, %v, want ErrTestFailed", result, err)
+	}
+	if string(doc) != `{"owner":1,"note":"text"}` {
+		t.Fatalf("document changed to %s", doc)
+	}
+}
@@ -0,0 +1,316 @@
+package jsonPatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// operation - операция JSON Patch. Value равно nil, если поле "value" не указано.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply применяет к документу doc изменение patch в формате JSON Patch (RFC 6902) - массив операций
+// add, remove, replace, move, copy и test, выполняемых по порядку. Если хотя бы одна операция не выполнена,
+// документ не изменяется и возвращается ErrInvalidPatch, ErrUnprocessable или ErrTestFailed.
+func Apply(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decode document: %s", ErrInvalidPatch, err)
+	}
+	var operations []operation
+	err = json.Unmarshal(patch, &operations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: json patch must be an array of operations: %s", ErrInvalidPatch, err)
+	}
+
+	for i, op := range operations {
+		target, err = applyOperation(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+// applyOperation выполняет одну операцию над документом doc и возвращает измененный документ
+func applyOperation(doc any, op operation) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: missing 'path'", ErrInvalidPatch)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Значение и исходный путь операции
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: missing 'value'", ErrInvalidPatch)
+		}
+		value, err = decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid 'value': %s", ErrInvalidPatch, err)
+		}
+	}
+	var from []string
+	switch op.Op {
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: missing 'from'", ErrInvalidPatch)
+		}
+		from, err = parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "replace":
+		doc, _, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move":
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move %q into its own child %q", ErrUnprocessable, *op.From, *op.Path)
+		}
+		doc, value, err = remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		value, err = get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	case "test":
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, fmt.Errorf("%w: value at %q differs", ErrTestFailed, *op.Path)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown 'op': %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901) на составляющие: "/a~1b/0" -> ["a/b", "0"]
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with '/'", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// isProperPrefix проверяет, что путь prefix указывает на предка пути path
+func isProperPrefix(prefix, path []string) bool {
+	return len(prefix) < len(path) && reflect.DeepEqual(prefix, path[:len(prefix)])
+}
+
+// get возвращает значение документа по пути path
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q not found", ErrUnprocessable, token)
+			}
+			doc = value
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("%w: cannot find %q in a scalar value", ErrUnprocessable, token)
+		}
+	}
+	return doc, nil
+}
+
+// add добавляет value по пути path: заменяет член объекта или вставляет элемент массива ("-" - в конец).
+// Пустой путь заменяет документ целиком.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return modifyParent(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			index := len(node)
+			if token != "-" {
+				var err error
+				index, err = arrayIndex(token, len(node))
+				if err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: cannot add %q to a scalar value", ErrUnprocessable, token)
+		}
+	})
+}
+
+// remove удаляет значение по пути path и возвращает измененный документ и удаленное значение
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	var removed any
+	doc, err := modifyParent(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q not found", ErrUnprocessable, token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[index]
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: cannot remove %q from a scalar value", ErrUnprocessable, token)
+		}
+	})
+	return doc, removed, err
+}
+
+// modifyParent находит родителя значения по непустому пути path, заменяет его результатом change
+// и возвращает измененный документ. Замена нужна, так как вставка в массив создает новый срез.
+func modifyParent(doc any, path []string, change func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = modifyParent(child, path[1:], change)
+	if err != nil {
+		return nil, err
+	}
+	switch node := doc.(type) {
+	case map[string]any:
+		node[path[0]] = child
+	case []any:
+		index, _ := arrayIndex(path[0], len(node)-1)
+		node[index] = child
+	}
+	return doc, nil
+}
+
+// arrayIndex разбирает индекс массива: десятичное число без ведущих нулей не больше maxIndex
+func arrayIndex(token string, maxIndex int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrUnprocessable, token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index > maxIndex {
+		return 0, fmt.Errorf("%w: array index %q out of bounds", ErrUnprocessable, token)
+	}
+	return index, nil
+}
+
+// deepCopy возвращает копию значения, не разделяющую с ним объекты и массивы
+func deepCopy(value any) any {
+	switch node := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(node))
+		for key, child := range node {
+			result[key] = deepCopy(child)
+		}
+		return result
+	case []any:
+		result := make([]any, len(node))
+		for i, child := range node {
+			result[i] = deepCopy(child)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// equal сравнивает JSON-значения. Числа сравниваются по значению, поэтому 1 и 1.0 равны.
+func equal(a, b any) bool {
+	switch aNode := a.(type) {
+	case json.Number:
+		bNumber, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if aNode == bNumber {
+			return true
+		}
+		aFloat, aErr := aNode.Float64()
+		bFloat, bErr := bNumber.Float64()
+		return aErr == nil && bErr == nil && aFloat == bFloat
+	case map[string]any:
+		bNode, ok := b.(map[string]any)
+		if !ok || len(aNode) != len(bNode) {
+			return false
+		}
+		for key, aChild := range aNode {
+			bChild, ok := bNode[key]
+			if !ok || !equal(aChild, bChild) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bNode, ok := b.([]any)
+		if !ok || len(aNode) != len(bNode) {
+			return false
+		}
+		for i := range aNode {
+			if !equal(aNode[i], bNode[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import "strings"
+
+const (
+	DiffEqual  = "="
+	DiffDelete = "-"
+	DiffInsert = "+"
+)
+
+// MaxDiffEdits - наибольшее число изменений, которое ищет LineDiff. Время и память алгоритма Майерса растут
+// с числом изменений, поэтому для сильно различающихся текстов минимальная разница не вычисляется.
+const MaxDiffEdits = 1000
+
+// DiffOp - одна строка построчного сравнения текстов
+type DiffOp struct {
+	Op   string // DiffEqual, DiffDelete или DiffInsert
+	Line string
+}
+
+// LineDiff вычисляет построчную разницу между текстами a и b алгоритмом Майерса.
+// Если тексты отличаются не более чем в MaxDiffEdits строках, результат минимален по числу изменений,
+// иначе после общих начальных и конечных строк все строки a удаляются, а все строки b вставляются.
+// Время работы - O((n+m)·D), память - O(D²), где n и m - число строк текстов, D - число изменений.
+func LineDiff(a, b string) []DiffOp {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	// Общие начальные и конечные строки не участвуют в поиске изменений
+	prefix := 0
+	for prefix < len(aLines) && prefix < len(bLines) && aLines[prefix] == bLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(aLines)-prefix && suffix < len(bLines)-prefix &&
+		aLines[len(aLines)-1-suffix] == bLines[len(bLines)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]DiffOp, 0, max(len(aLines), len(bLines)))
+	for _, line := range aLines[:prefix] {
+		ops = append(ops, DiffOp{DiffEqual, line})
+	}
+	ops = append(ops, myersDiff(aLines[prefix:len(aLines)-suffix], bLines[prefix:len(bLines)-suffix])...)
+	for _, line := range aLines[len(aLines)-suffix:] {
+		ops = append(ops, DiffOp{DiffEqual, line})
+	}
+	return ops
+}
+
+// myersDiff вычисляет разницу между строками a и b алгоритмом Майерса, если она не больше MaxDiffEdits изменений,
+// иначе возвращает удаление всех строк a и вставку всех строк b
+func myersDiff(a, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, MaxDiffEdits)
+
+	// v[offset+k] - наибольшее x, достижимое на диагонали k = x-y за текущее число изменений.
+	// trace[d] - состояние v перед шагом d, по нему восстанавливается путь.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	trace := make([][]int, 0, limit+1)
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1 // удаление строки a
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // вставка строки b
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersPath(trace, a, b)
+			}
+		}
+	}
+
+	// Тексты отличаются больше чем в MaxDiffEdits строках
+	ops := make([]DiffOp, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, DiffOp{DiffDelete, line})
+	}
+	for _, line := range b {
+		ops = append(ops, DiffOp{DiffInsert, line})
+	}
+	return ops
+}
+
+// myersPath восстанавливает изменения по состояниям trace алгоритма Майерса, дошедшего до конца строк a и b
+// за len(trace)-1 изменений
+func myersPath(trace [][]int, a, b []string) []DiffOp {
+	x, y := len(a), len(b)
+	reversed := make([]DiffOp, 0, len(a)+len(b))
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d] хранит диагонали -d..d со сдвигом d
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffOp{DiffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffOp{DiffInsert, b[y-1]})
+			y--
+		} else {
+			reversed = append(reversed, DiffOp{DiffDelete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffOp{DiffEqual, a[x-1]})
+		x--
+		y--
+	}
+
+	ops := make([]DiffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// applyDiff восстанавливает исходный и новый тексты по разнице
+func applyDiff(ops []DiffOp) (a, b string) {
+	var aLines, bLines []string
+	for _, op := range ops {
+		if op.Op != DiffInsert {
+			aLines = append(aLines, op.Line)
+		}
+		if op.Op != DiffDelete {
+			bLines = append(bLines, op.Line)
+		}
+	}
+	return strings.Join(aLines, "\n"), strings.Join(bLines, "\n")
+}
+
+// countEdits возвращает число удаленных и вставленных строк
+func countEdits(ops []DiffOp) int {
+	edits := 0
+	for _, op := range ops {
+		if op.Op != DiffEqual {
+			edits++
+		}
+	}
+	return edits
+}
+
+// lcsLength возвращает длину наибольшей общей подпоследовательности строк a и b
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestLineDiff(t *testing.T) {
+	ops := LineDiff("a\nb\nc", "a\nc\nd")
+	want := []DiffOp{{DiffEqual, "a"}, {DiffDelete, "b"}, {DiffEqual, "c"}, {DiffInsert, "d"}}
+	if len(ops) != len(want) {
+		t.Fatalf("LineDiff = %v, want %v", ops, want)
+	}
+	for i := range ops {
+		if ops[i] != want[i] {
+			t.Fatalf("LineDiff = %v, want %v", ops, want)
+		}
+	}
+}
+
+func TestLineDiffIsMinimal(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		lines := make([]string, random.Intn(30))
+		for i := range lines {
+			lines[i] = strconv.Itoa(random.Intn(5))
+		}
+		return strings.Join(lines, "\n")
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := randomText(), randomText()
+		ops := LineDiff(a, b)
+		if gotA, gotB := applyDiff(ops); gotA != a || gotB != b {
+			t.Fatalf("LineDiff(%q, %q) = %v does not transform a into b", a, b, ops)
+		}
+		aLines, bLines := strings.Split(a, "\n"), strings.Split(b, "\n")
+		if want := len(aLines) + len(bLines) - 2*lcsLength(aLines, bLines); countEdits(ops) != want {
+			t.Fatalf("LineDiff(%q, %q) has %d edits, want %d", a, b, countEdits(ops), want)
+		}
+	}
+}
+
+func TestLineDiffOfLargeTexts(t *testing.T) {
+	// Тексты по 100000 строк без общих строк: таблица LCS заняла бы 10^10 ячеек
+	aLines, bLines := make([]string, 100000), make([]string, 100000)
+	for i := range aLines {
+		aLines[i] = "a" + strconv.Itoa(i)
+		bLines[i] = "b" + strconv.Itoa(i)
+	}
+	a, b := strings.Join(aLines, "\n"), strings.Join(bLines, "\n")
+
+	start := time.Now()
+	ops := LineDiff(a, b)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("LineDiff of large texts took %s", elapsed)
+	}
+	if gotA, gotB := applyDiff(ops); gotA != a || gotB != b {
+		t.Fatal("LineDiff of large texts does not transform a into b")
+	}
+
+	// Небольшое изменение большого текста находится точно
+	bLines = append([]string(nil), aLines...)
+	bLines[50000] = "изменено"
+	ops = LineDiff(a, strings.Join(bLines, "\n"))
+	if edits := countEdits(ops); edits != 2 {
+		t.Fatalf("LineDiff of one changed line has %d edits, want 2", edits)
+	}
+}
@@ -21,23 +21,21 @@ type WrappedError struct {
 	comment      string   // Комментарий к ошибке (что именно вызвало ошибку?)
 	err          error    // Ошибка, которая будет обернута
 	timestamp    string   // Время последнего обновления ошибки методом Specify()
-	logFile      *os.File // Указатель на файл для записи логов
+	logFile      *os.File // Указатель на файл для записи логов (открывается при первой записи ошибки)
+	logFileName  string   // Имя файла логов, пустое - ошибки в файл не записываются
 }
 
 // NewWrappedError создает новый экземпляр WrappedError с именем функции, но без комментария.
 // То есть уже известно, где ошибка может произойти, но что именно за ошибка еще неизвестно.
 func NewWrappedError(funcName string) *WrappedError {
-	return &WrappedError{funcName, "", nil, "[]", nil}
+	return &WrappedError{funcName, "", nil, "[]", nil, ""}
 }
 
-// NewWrappedErrorWithFile аналогична NewWrappedError, но с указателем на файл записи в логи.
-// Если файл не удалось открыть, то экземпляр не создается и возвращается nil, error.
-func NewWrappedErrorWithFile(funcName string) (*WrappedError, error) {
-	file, err := os.OpenFile(logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
-	}
-	return &WrappedError{funcName, "", nil, "[]", file}, nil
+// NewWrappedErrorWithFile аналогична NewWrappedError, но ошибки дополнительно записываются в файл логов.
+// Файл открывается только при записи первой ошибки, чтобы успешные запросы не обращались к нему:
+// их и так записывает журнал запросов. Ошибка открытия файла выводится в консоль при записи.
+func NewWrappedErrorWithFile(funcName string) *WrappedError {
+	return &WrappedError{funcName, "", nil, "[]", nil, logFileName}
 }
 
 // Specify обновляет экземпляр, если переданная ошибка не nil. Перезаписываются err и comment.
@@ -60,12 +58,12 @@ func (e *WrappedError) Error() string {
 	return fmt.Sprintf("'%s' in function '%s' invoked '%s'", e.comment, e.functionName, e.err.Error())
 }
 
-// LogError выводит ошибку в стандартный вывод и записывает ее в файл логов (если файл логов был открыт).
+// LogError выводит ошибку в стандартный вывод и записывает ее в файл логов (если он был задан).
 // Если ошибки нет, то ничего не делает.
 func (e *WrappedError) LogError() {
 	if e.err != nil {
 		log.Println(e.timestamp, errorTag, e.Error())
-		if e.logFile != nil {
+		if e.openLogFile() {
 			_, writeError := fmt.Fprintln(e.logFile, e.timestamp, errorTag, e.Error())
 			if writeError != nil {
 				log.Println("Failed to write log into opened file:", writeError)
@@ -74,21 +72,32 @@ func (e *WrappedError) LogError() {
 	}
 }
 
-// LogMsg выводит сообщение в стандартный вывод и записывает ее в файл логов (если файл логов был открыт).
-// Это сообщение не является ошибкой, но выводится в консоль и в файл логов.
+// LogMsg выводит сообщение в стандартный вывод.
+// Это сообщение не является ошибкой, поэтому в файл логов оно не записывается: каждый запрос
+// уже попадает в журнал запросов, и файл логов не должен повторять его.
 func (e *WrappedError) LogMsg(msg string) {
 	msgTimestamp := fmt.Sprintf("[%s]", time.Now().Format(time.RFC3339))
 	log.Println(msgTimestamp, messageTag, fmt.Sprintf("'%s' from function '%s'", msg, e.functionName))
-	if e.logFile != nil {
-		_, writeError := fmt.Fprintln(e.logFile, msgTimestamp, messageTag, fmt.Sprintf("'%s' from function '%s'", msg, e.functionName))
-		if writeError != nil {
-			log.Println("Failed to write log into opened file:", writeError)
+}
+
+// openLogFile открывает файл логов, если он задан и еще не открыт, и сообщает, можно ли в него писать.
+func (e *WrappedError) openLogFile() bool {
+	if e.logFile == nil && e.logFileName != "" {
+		file, err := os.OpenFile(e.logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Println("Failed to open log file:", err)
+			e.logFileName = ""
+			return false
 		}
+		e.logFile = file
 	}
+	return e.logFile != nil
 }
 
-// Close закрывает файл логов.
+// Close закрывает файл логов, если он был открыт.
 // Не возвращает ошибку даже если файл уже был закрыт.
 func (e *WrappedError) Close() {
-	e.logFile.Close()
+	if e.logFile != nil {
+		e.logFile.Close()
+	}
 }
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// chdirTemp переходит во временный каталог на время теста, чтобы файл логов создавался в нем
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestLogFileOnlyHoldsErrors(t *testing.T) {
+	chdirTemp(t)
+
+	wErr := NewWrappedErrorWithFile("handleGet()")
+	wErr.LogMsg("OK - get: {id: 1}")
+	wErr.LogError()
+	wErr.Close()
+	if _, err := os.Stat(logFileName); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("log file after a successful request: %v", err)
+	}
+
+	wErr = NewWrappedErrorWithFile("handleGet()")
+	wErr.LogMsg("note doesn't exist")
+	wErr.Specify(errors.New("disk is full"), "storage.Add()").LogError()
+	wErr.Close()
+	data, err := os.ReadFile(logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log := string(data); !strings.Contains(log, "disk is full") || strings.Contains(log, messageTag) {
+		t.Fatalf("log file = %q, want only the error", log)
+	}
+}